@@ -0,0 +1,357 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pirosb3/real_feed/clock"
+	"pirosb3/real_feed/controller"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// CLIENTS_RELOAD_INTERVAL is how often the clients file is checked for changes.
+	CLIENTS_RELOAD_INTERVAL = 10 * time.Second
+	// API_KEY_METADATA is the request metadata holding the API key. The key may also be sent as
+	// `authorization: Bearer <key>`.
+	API_KEY_METADATA       = "x-api-key"
+	AUTHORIZATION_METADATA = "authorization"
+	UNKNOWN_CLIENT         = "unknown"
+)
+
+// Reasons set in the ErrorInfo detail of rejected requests
+const (
+	REASON_UNAUTHENTICATED    = "UNAUTHENTICATED"
+	REASON_RATE_LIMITED       = "RATE_LIMITED"
+	REASON_AMOUNT_ABOVE_LIMIT = "AMOUNT_ABOVE_LIMIT"
+)
+
+// Results of requests in the clientRequests metric
+const (
+	RESULT_ALLOWED         = "allowed"
+	RESULT_UNAUTHENTICATED = "unauthenticated"
+	RESULT_RATE_LIMITED    = "rate_limited"
+	RESULT_AMOUNT_LIMITED  = "amount_limited"
+)
+
+// PUBLIC_METHOD_PREFIXES are served without authentication, so that health probes and tools
+// relying on reflection keep working.
+var PUBLIC_METHOD_PREFIXES = []string{"/grpc.health.v1.Health/", "/grpc.reflection.v1alpha.ServerReflection/"}
+
+// amountFields are the request fields, and their repeated versions, holding the amount of a quote.
+var amountFields = map[protoreflect.Name]bool{
+	"inAmount":  true,
+	"outAmount": true,
+	"amount":    true,
+	"amounts":   true,
+	"sizes":     true,
+}
+
+var clientRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name:      "clientRequests",
+	Help:      "Counts requests per client, method and whether they were allowed or rejected",
+	Namespace: "feed",
+}, []string{"client", "method", "result"})
+
+type clientKey struct{}
+
+// ClientFromContext returns the client a request was authenticated as.
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(clientKey{}).(*Client)
+	return client, ok
+}
+
+// Authenticator authenticates requests with an API key or a client certificate, and enforces the
+// limits of the client, as configured in a clients file that is reloaded when it changes.
+type Authenticator struct {
+	path          string
+	clock         clock.Clock
+	lock          sync.Mutex
+	byKey         map[string]*Client
+	byCertificate map[string]*Client
+	buckets       map[string]*tokenBucket
+	modTime       time.Time
+}
+
+// NewAuthenticator loads the clients in the file at `path`.
+func NewAuthenticator(path string) (*Authenticator, error) {
+	a := &Authenticator{
+		path:    path,
+		clock:   clock.NewReal(),
+		buckets: make(map[string]*tokenBucket),
+	}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetClock replaces the clock rate limits are measured with.
+func (a *Authenticator) SetClock(clock clock.Clock) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.clock = clock
+}
+
+// Reload reads the clients file again. The previous clients are kept if it is invalid. Clients
+// keep the requests left in their rate limit across reloads.
+func (a *Authenticator) Reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	file, err := LoadClientsFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	byKey := make(map[string]*Client)
+	byCertificate := make(map[string]*Client)
+	buckets := make(map[string]*tokenBucket)
+	for idx := range file.Clients {
+		client := &file.Clients[idx]
+		if client.APIKeySHA256 != "" {
+			byKey[strings.ToLower(client.APIKeySHA256)] = client
+		}
+		if client.CertificateName != "" {
+			byCertificate[client.CertificateName] = client
+		}
+		if client.RequestsPerSecond > 0 {
+			bucket, ok := a.buckets[client.Name]
+			if ok {
+				bucket.setLimits(client.RequestsPerSecond, client.burst())
+			} else {
+				bucket = newTokenBucket(client.RequestsPerSecond, client.burst())
+			}
+			buckets[client.Name] = bucket
+		}
+	}
+	a.byKey = byKey
+	a.byCertificate = byCertificate
+	a.buckets = buckets
+	a.modTime = info.ModTime()
+	return nil
+}
+
+func (a *Authenticator) changed() bool {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return false
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return !info.ModTime().Equal(a.modTime)
+}
+
+// Run reloads the clients file every time it changes, until `ctx` is cancelled.
+func (a *Authenticator) Run(ctx context.Context) {
+	ticker := time.NewTicker(CLIENTS_RELOAD_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !a.changed() {
+				continue
+			}
+			if err := a.Reload(); err != nil {
+				log.WithField("err", err.Error()).Errorln("Unable to reload clients, keeping the previous ones")
+				continue
+			}
+			log.WithField("file", a.path).Infoln("Reloaded clients")
+		}
+	}
+}
+
+func rejection(code codes.Code, reason string, message string, details ...protov1.Message) error {
+	st := status.New(code, message)
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: controller.ERROR_DOMAIN}
+	withDetails, err := st.WithDetails(append([]protov1.Message{info}, details...)...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+func apiKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if keys := md.Get(API_KEY_METADATA); len(keys) > 0 {
+		return keys[0]
+	}
+	for _, value := range md.Get(AUTHORIZATION_METADATA) {
+		if strings.HasPrefix(value, "Bearer ") {
+			return strings.TrimPrefix(value, "Bearer ")
+		}
+	}
+	return ""
+}
+
+// authenticate returns the client of the API key of the request, or else the client of the
+// verified client certificate.
+func (a *Authenticator) authenticate(ctx context.Context) (*Client, error) {
+	key := apiKey(ctx)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if key != "" {
+		if client, ok := a.byKey[HashAPIKey(key)]; ok {
+			return client, nil
+		}
+		return nil, rejection(codes.Unauthenticated, REASON_UNAUTHENTICATED, "API key is invalid")
+	}
+	if identity, ok := IdentityFromContext(ctx); ok {
+		if client, ok := a.byCertificate[identity.Name]; ok {
+			return client, nil
+		}
+		return nil, rejection(codes.Unauthenticated, REASON_UNAUTHENTICATED, "Client certificate '"+identity.Name+"' is not allowed")
+	}
+	return nil, rejection(codes.Unauthenticated, REASON_UNAUTHENTICATED, "An API key or a client certificate is required")
+}
+
+// allow takes a request from the rate limit of `client`.
+func (a *Authenticator) allow(client *Client) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	bucket, ok := a.buckets[client.Name]
+	if !ok {
+		return nil
+	}
+	allowed, retryAfter := bucket.take(a.clock.Now())
+	if allowed {
+		return nil
+	}
+	return rejection(codes.ResourceExhausted, REASON_RATE_LIMITED, "Rate limit of "+strconv.FormatFloat(client.RequestsPerSecond, 'f', -1, 64)+" requests per second exceeded",
+		&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retryAfter)})
+}
+
+// maxAmount returns the largest amount quoted by `message`, including in the messages it holds.
+func maxAmount(message protoreflect.Message) float64 {
+	max := 0.0
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsMap():
+		case field.Message() != nil && field.IsList():
+			for idx := 0; idx < value.List().Len(); idx++ {
+				max = math.Max(max, maxAmount(value.List().Get(idx).Message()))
+			}
+		case field.Message() != nil:
+			max = math.Max(max, maxAmount(value.Message()))
+		case amountFields[field.Name()] && field.IsList():
+			for idx := 0; idx < value.List().Len(); idx++ {
+				max = math.Max(max, amountOf(field, value.List().Get(idx)))
+			}
+		case amountFields[field.Name()]:
+			max = math.Max(max, amountOf(field, value))
+		}
+		return true
+	})
+	return max
+}
+
+func amountOf(field protoreflect.FieldDescriptor, value protoreflect.Value) float64 {
+	switch field.Kind() {
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value.Float()
+	case protoreflect.StringKind:
+		// Decimal strings of the v2 API, invalid ones are rejected by the service itself
+		parsed, _ := strconv.ParseFloat(value.String(), 64)
+		return parsed
+	}
+	return 0
+}
+
+// requestAmount returns the largest amount quoted by a request, 0 if it does not quote any.
+func requestAmount(req interface{}) float64 {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return 0
+	}
+	return maxAmount(message.ProtoReflect())
+}
+
+// checkAmount rejects requests quoting more than the limit of `client`. The limit applies to the
+// amount of the request, in whichever currency it is expressed.
+func checkAmount(client *Client, amount float64) error {
+	if client.MaxQuoteAmount > 0 && amount > client.MaxQuoteAmount {
+		return rejection(codes.PermissionDenied, REASON_AMOUNT_ABOVE_LIMIT, "Amount "+strconv.FormatFloat(amount, 'f', -1, 64)+" is above the limit of "+strconv.FormatFloat(client.MaxQuoteAmount, 'f', -1, 64))
+	}
+	return nil
+}
+
+func isPublic(method string) bool {
+	for _, prefix := range PUBLIC_METHOD_PREFIXES {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Admit authenticates a request for `method` quoting up to `amount`, and applies the limits of its
+// client. It returns the context of the request with its client, or a status error. Servers other
+// than gRPC build the context of their requests with IncomingContext.
+func (a *Authenticator) Admit(ctx context.Context, method string, amount float64) (context.Context, error) {
+	client, err := a.authenticate(ctx)
+	if err != nil {
+		clientRequestsCounter.WithLabelValues(UNKNOWN_CLIENT, method, RESULT_UNAUTHENTICATED).Inc()
+		return ctx, err
+	}
+	if err := a.allow(client); err != nil {
+		clientRequestsCounter.WithLabelValues(client.Name, method, RESULT_RATE_LIMITED).Inc()
+		return ctx, err
+	}
+	if err := checkAmount(client, amount); err != nil {
+		clientRequestsCounter.WithLabelValues(client.Name, method, RESULT_AMOUNT_LIMITED).Inc()
+		return ctx, err
+	}
+	clientRequestsCounter.WithLabelValues(client.Name, method, RESULT_ALLOWED).Inc()
+	return context.WithValue(ctx, clientKey{}, client), nil
+}
+
+// UnaryInterceptor rejects requests that are not authenticated, exceed the rate limit of their
+// client or quote more than it may. It must run after UnaryIdentityInterceptor for clients to
+// authenticate with certificates.
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isPublic(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.Admit(ctx, info.FullMethod, requestAmount(req))
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor is the UnaryInterceptor of streams, which are limited when they are opened.
+func (a *Authenticator) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isPublic(info.FullMethod) {
+		return handler(srv, stream)
+	}
+	ctx, err := a.Admit(stream.Context(), info.FullMethod, 0)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+}
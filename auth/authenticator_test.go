@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/clock"
+	"pirosb3/real_feed/rpc"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const BUY_BASE_METHOD = "/OrderbookService/BuyBase"
+
+func writeClients(t *testing.T, dir string, content string) string {
+	path := filepath.Join(dir, "clients.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAuthenticator(t *testing.T, content string) (*Authenticator, *clock.Manual, func()) {
+	dir, _ := os.MkdirTemp("", "clients")
+	a, err := NewAuthenticator(writeClients(t, dir, content))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Unexpected error %s", err)
+	}
+	manual := clock.NewManual(time.Unix(1600000000, 0))
+	a.SetClock(manual)
+	return a, manual, func() { os.RemoveAll(dir) }
+}
+
+func withKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(API_KEY_METADATA, key))
+}
+
+func call(a *Authenticator, ctx context.Context, method string, req interface{}) (*Client, error) {
+	var client *Client
+	_, err := a.UnaryInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		client, _ = ClientFromContext(ctx)
+		return nil, nil
+	})
+	return client, err
+}
+
+func reason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+func TestExampleClientsFileIsValid(t *testing.T) {
+	if _, err := LoadClientsFile("../clients.example.yaml"); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+}
+
+func TestAuthenticatesWithKeysAndCertificates(t *testing.T) {
+	a, _, cleanup := newTestAuthenticator(t, "clients:\n  - name: bot\n    apiKeySHA256: "+HashAPIKey("secret")+"\n  - name: dashboard\n    certificateName: dashboard.internal\n")
+	defer cleanup()
+
+	if client, err := call(a, withKey("secret"), BUY_BASE_METHOD, nil); err != nil || client.Name != "bot" {
+		t.Errorf("Expected the bot, got %v %v", client, err)
+	}
+	bearer := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AUTHORIZATION_METADATA, "Bearer secret"))
+	if client, err := call(a, bearer, BUY_BASE_METHOD, nil); err != nil || client.Name != "bot" {
+		t.Errorf("Expected the bot from a bearer token, got %v %v", client, err)
+	}
+	certificate := WithIdentity(context.Background(), &Identity{Name: "dashboard.internal"})
+	if client, err := call(a, certificate, BUY_BASE_METHOD, nil); err != nil || client.Name != "dashboard" {
+		t.Errorf("Expected the dashboard, got %v %v", client, err)
+	}
+	for _, ctx := range []context.Context{withKey("guess"), context.Background(), WithIdentity(context.Background(), &Identity{Name: "stranger"})} {
+		if _, err := call(a, ctx, BUY_BASE_METHOD, nil); status.Code(err) != codes.Unauthenticated || reason(err) != REASON_UNAUTHENTICATED {
+			t.Errorf("Expected the request to be unauthenticated, got %v", err)
+		}
+	}
+	if _, err := call(a, context.Background(), "/grpc.health.v1.Health/Check", nil); err != nil {
+		t.Errorf("Expected health checks not to require authentication, got %v", err)
+	}
+}
+
+func TestRateLimitIsReplenished(t *testing.T) {
+	a, manual, cleanup := newTestAuthenticator(t, "clients:\n  - name: bot\n    apiKeySHA256: "+HashAPIKey("secret")+"\n    requestsPerSecond: 2\n    burst: 2\n")
+	defer cleanup()
+
+	for idx := 0; idx < 2; idx++ {
+		if _, err := call(a, withKey("secret"), BUY_BASE_METHOD, nil); err != nil {
+			t.Fatalf("Expected the burst to be allowed, got %v", err)
+		}
+	}
+	_, err := call(a, withKey("secret"), BUY_BASE_METHOD, nil)
+	if status.Code(err) != codes.ResourceExhausted || reason(err) != REASON_RATE_LIMITED {
+		t.Errorf("Expected the rate limit to be exceeded, got %v", err)
+	}
+	manual.Advance(500 * time.Millisecond)
+	if _, err := call(a, withKey("secret"), BUY_BASE_METHOD, nil); err != nil {
+		t.Errorf("Expected a request to be allowed after waiting, got %v", err)
+	}
+
+	// Reloading keeps the requests left, so that changing the file does not reset limits
+	writeClients(t, filepath.Dir(a.path), "clients:\n  - name: bot\n    apiKeySHA256: "+HashAPIKey("secret")+"\n    requestsPerSecond: 1\n")
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if _, err := call(a, withKey("secret"), BUY_BASE_METHOD, nil); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected the rate limit to still be exceeded, got %v", err)
+	}
+}
+
+func TestQuoteAmountIsLimited(t *testing.T) {
+	a, _, cleanup := newTestAuthenticator(t, "clients:\n  - name: bot\n    apiKeySHA256: "+HashAPIKey("secret")+"\n    maxQuoteAmount: 10\n")
+	defer cleanup()
+
+	if _, err := call(a, withKey("secret"), BUY_BASE_METHOD, &rpc.PricingRequest{InAmount: 10}); err != nil {
+		t.Errorf("Expected an amount at the limit to be allowed, got %v", err)
+	}
+	_, err := call(a, withKey("secret"), BUY_BASE_METHOD, &rpc.PricingRequest{InAmount: 10.5})
+	if status.Code(err) != codes.PermissionDenied || reason(err) != REASON_AMOUNT_ABOVE_LIMIT {
+		t.Errorf("Expected the amount to be rejected, got %v", err)
+	}
+	batch := &rpcv2.BatchQuoteRequest{Quotes: []*rpcv2.QuoteRequest{{Amount: "1"}, {Amount: "25.5"}}}
+	if _, err := call(a, withKey("secret"), "/v2.OrderbookService/BatchQuote", batch); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected the amounts of a batch to be checked, got %v", err)
+	}
+	impact := &rpc.ImpactCurveRequest{Amounts: []float64{1, 50}}
+	if _, err := call(a, withKey("secret"), "/OrderbookService/GetImpactCurve", impact); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected repeated amounts to be checked, got %v", err)
+	}
+}
+
+func TestInvalidClientsFileIsRejected(t *testing.T) {
+	a, _, cleanup := newTestAuthenticator(t, "clients:\n  - name: bot\n    apiKeySHA256: "+HashAPIKey("secret")+"\n")
+	defer cleanup()
+
+	writeClients(t, filepath.Dir(a.path), "clients:\n  - name: bot\n    apiKeySHA256: not-a-hash\n  - name: bot\n")
+	if err := a.Reload(); err == nil {
+		t.Errorf("Expected an invalid file to be rejected")
+	}
+	if _, err := call(a, withKey("secret"), BUY_BASE_METHOD, nil); err != nil {
+		t.Errorf("Expected the previous clients to be kept, got %v", err)
+	}
+}
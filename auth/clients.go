@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Client is a caller allowed to use the service, with its limits.
+type Client struct {
+	Name string `yaml:"name"`
+	// APIKeySHA256 is the hex encoded SHA-256 of the API key of the client, so that the file
+	// does not hold the keys themselves.
+	APIKeySHA256 string `yaml:"apiKeySHA256,omitempty"`
+	// CertificateName authenticates clients presenting a verified certificate with this name,
+	// when the server uses mutual TLS.
+	CertificateName string `yaml:"certificateName,omitempty"`
+	// RequestsPerSecond is the sustained rate of requests, zero disables rate limiting.
+	RequestsPerSecond float64 `yaml:"requestsPerSecond,omitempty"`
+	// Burst is how many requests may be made at once, defaults to RequestsPerSecond.
+	Burst int `yaml:"burst,omitempty"`
+	// MaxQuoteAmount is the largest amount a single quote may be for, zero disables the limit.
+	MaxQuoteAmount float64 `yaml:"maxQuoteAmount,omitempty"`
+}
+
+// ClientsFile is the file clients are configured in.
+type ClientsFile struct {
+	Clients []Client `yaml:"clients"`
+}
+
+// HashAPIKey returns the value of APIKeySHA256 for `apiKey`.
+func HashAPIKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// burst returns the size of the token bucket of the client.
+func (c *Client) burst() float64 {
+	if c.Burst > 0 {
+		return float64(c.Burst)
+	}
+	if c.RequestsPerSecond < 1 {
+		return 1
+	}
+	return c.RequestsPerSecond
+}
+
+// Validate returns an error listing every invalid client.
+func (f *ClientsFile) Validate() error {
+	var problems []string
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	certificates := make(map[string]bool)
+	for idx, client := range f.Clients {
+		prefix := fmt.Sprintf("clients[%d]: ", idx)
+		if client.Name == "" {
+			problems = append(problems, prefix+"name is required")
+		} else if names[client.Name] {
+			problems = append(problems, prefix+"name '"+client.Name+"' is used twice")
+		}
+		names[client.Name] = true
+		if client.APIKeySHA256 == "" && client.CertificateName == "" {
+			problems = append(problems, prefix+"apiKeySHA256 or certificateName is required")
+		}
+		if client.APIKeySHA256 != "" {
+			if decoded, err := hex.DecodeString(client.APIKeySHA256); err != nil || len(decoded) != sha256.Size {
+				problems = append(problems, prefix+"apiKeySHA256 must be a hex encoded SHA-256")
+			} else if keys[strings.ToLower(client.APIKeySHA256)] {
+				problems = append(problems, prefix+"apiKeySHA256 is used twice")
+			}
+			keys[strings.ToLower(client.APIKeySHA256)] = true
+		}
+		if client.CertificateName != "" {
+			if certificates[client.CertificateName] {
+				problems = append(problems, prefix+"certificateName '"+client.CertificateName+"' is used twice")
+			}
+			certificates[client.CertificateName] = true
+		}
+		if client.RequestsPerSecond < 0 || client.Burst < 0 || client.MaxQuoteAmount < 0 {
+			problems = append(problems, prefix+"limits cannot be negative")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("Clients are invalid: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// LoadClientsFile reads and validates the clients in the YAML file at `path`. Unknown settings
+// are rejected, as they are most likely typos.
+func LoadClientsFile(path string) (*ClientsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &ClientsFile{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", path, err.Error())
+	}
+	return file, file.Validate()
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// API_KEY_QUERY_PARAMETER carries the API key of browsers, which cannot set headers when opening
+// a websocket. Headers are preferred, as URLs tend to be logged.
+const API_KEY_QUERY_PARAMETER = "apiKey"
+
+// IncomingContext returns the context of an HTTP request carrying its credentials the way those
+// of a gRPC request are: the API key as request metadata, and the identity of the verified client
+// certificate, if there is one.
+func IncomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	if key := r.Header.Get(API_KEY_METADATA); key != "" {
+		md.Set(API_KEY_METADATA, key)
+	} else if key := r.URL.Query().Get(API_KEY_QUERY_PARAMETER); key != "" {
+		md.Set(API_KEY_METADATA, key)
+	}
+	if authorization := r.Header.Get(AUTHORIZATION_METADATA); authorization != "" {
+		md.Set(AUTHORIZATION_METADATA, authorization)
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		ctx = WithIdentity(ctx, identityOf(r.TLS.VerifiedChains[0][0]))
+	}
+	return ctx
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestHTTPServersUseClientCertificates(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "tls")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil)
+	certFile, keyFile, caFile := writeFiles(t, dir, newTestCert(t, "feed.local", ca), ca)
+	reloader, err := NewCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := &http.Server{TLSConfig: reloader.TLSConfig(), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(IncomingContext(r))
+		if identity == nil {
+			http.Error(w, "No identity", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Proto + " " + identity.Name))
+	})}
+	go server.ServeTLS(lis, "", "")
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "feed.local",
+		Certificates: []tls.Certificate{newTestCert(t, "dashboard", ca).keyPair(t)},
+	}}}
+	response, err := client.Get("https://" + lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	// Websockets are upgraded from HTTP/1.1, which must still be negotiated
+	if string(body) != "HTTP/1.1 dashboard" {
+		t.Errorf("Unexpected response %q", body)
+	}
+}
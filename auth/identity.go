@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Identity is the client a request was made by.
+type Identity struct {
+	// Name is the common name of the client certificate, or its first DNS or URI name when the
+	// common name is empty.
+	Name string
+	// Fingerprint is the hex encoded SHA-256 of the client certificate.
+	Fingerprint string
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of `ctx` carrying `identity`.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of the client of a request, if it is known.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+func identityOf(certificate *x509.Certificate) *Identity {
+	fingerprint := sha256.Sum256(certificate.Raw)
+	identity := &Identity{
+		Name:        certificate.Subject.CommonName,
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	if identity.Name == "" && len(certificate.DNSNames) > 0 {
+		identity.Name = certificate.DNSNames[0]
+	}
+	if identity.Name == "" && len(certificate.URIs) > 0 {
+		identity.Name = certificate.URIs[0].String()
+	}
+	return identity
+}
+
+// peerIdentity returns the identity of the verified client certificate of the connection of `ctx`.
+func peerIdentity(ctx context.Context) (*Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return identityOf(tlsInfo.State.VerifiedChains[0][0]), true
+}
+
+// withPeerIdentity adds the identity of the client certificate to `ctx`, if there is one.
+func withPeerIdentity(ctx context.Context) context.Context {
+	if identity, ok := peerIdentity(ctx); ok {
+		return WithIdentity(ctx, identity)
+	}
+	return ctx
+}
+
+func logRequest(ctx context.Context, method string, err error) {
+	entry := log.WithField("method", method).WithField("code", status.Code(err).String())
+	if identity, ok := IdentityFromContext(ctx); ok {
+		entry = entry.WithField("client", identity.Name)
+	}
+	entry.Debugln("Served request")
+}
+
+// UnaryIdentityInterceptor adds the identity of the client certificate to the context of
+// requests, and logs them with it.
+func UnaryIdentityInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = withPeerIdentity(ctx)
+	response, err := handler(ctx, req)
+	logRequest(ctx, info.FullMethod, err)
+	return response, err
+}
+
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamIdentityInterceptor is the UnaryIdentityInterceptor of streams.
+func StreamIdentityInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := withPeerIdentity(stream.Context())
+	err := handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+	logRequest(ctx, info.FullMethod, err)
+	return err
+}
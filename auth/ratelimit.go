@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket allows `burst` requests at once, refilled at `rate` requests per second.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket, which starts refilling once the first token is taken.
+func newTokenBucket(rate float64, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// setLimits changes the rate and the burst, keeping the tokens already available.
+func (b *tokenBucket) setLimits(rate float64, burst float64) {
+	b.rate = rate
+	b.burst = burst
+	b.tokens = math.Min(b.tokens, burst)
+}
+
+// take removes a token if one is available. Otherwise it returns false and how long until the
+// next token is available.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	if b.last.IsZero() {
+		b.last = now
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TLS_RELOAD_INTERVAL is how often certificate files are checked for changes.
+const TLS_RELOAD_INTERVAL = 30 * time.Second
+
+// CertReloader serves a certificate, and optionally the CAs client certificates are verified
+// against, that are reloaded from disk when the files change. Connections already established
+// keep the certificate they were opened with.
+type CertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	lock         sync.RWMutex
+	certificate  *tls.Certificate
+	clientCAs    *x509.CertPool
+	modTimes     map[string]time.Time
+}
+
+// NewCertReloader loads the certificate in `certFile` and `keyFile`. Clients must present a
+// certificate signed by a CA of `clientCAFile`, unless it is empty.
+func NewCertReloader(certFile string, keyFile string, clientCAFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+// Reload reads the files again. The previous certificate is kept if any of them is invalid.
+func (r *CertReloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("Unable to load certificate %s: %s", r.certFile, err.Error())
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		data, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return errors.New("No certificate found in " + r.clientCAFile)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+// changed returns true if any of the files was modified since it was last loaded.
+func (r *CertReloader) changed() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// Files are often replaced by removing and recreating them, they are checked again later
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run reloads the files every time they change, until `ctx` is cancelled.
+func (r *CertReloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(TLS_RELOAD_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.WithField("err", err.Error()).Errorln("Unable to reload TLS certificates, keeping the previous ones")
+				continue
+			}
+			log.WithField("cert", r.certFile).Infoln("Reloaded TLS certificates")
+		}
+	}
+}
+
+// TLSConfig returns a server configuration always using the latest certificates.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.lock.RLock()
+			defer r.lock.RUnlock()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate},
+				// gRPC requires HTTP/2 to be negotiated, websockets are upgraded from HTTP/1.1
+				NextProtos: []string{"h2", "http/1.1"},
+			}
+			if r.clientCAs != nil {
+				config.ClientCAs = r.clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}
+}
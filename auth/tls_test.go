@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type testCert struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) keyPair(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+func writeFiles(t *testing.T, dir string, server *testCert, ca *testCert) (string, string, string) {
+	certFile, keyFile, caFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem")
+	os.WriteFile(certFile, server.certPEM, 0600)
+	os.WriteFile(keyFile, server.keyPEM, 0600)
+	os.WriteFile(caFile, ca.certPEM, 0600)
+	return certFile, keyFile, caFile
+}
+
+// handshake connects a client presenting `clientCert`, if any, and returns the certificate the
+// server presented.
+func handshake(serverConfig *tls.Config, ca *testCert, clientCert *testCert) (*x509.Certificate, error) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		server := tls.Server(serverConn, serverConfig)
+		server.Handshake()
+		server.Close()
+	}()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	config := &tls.Config{RootCAs: roots, ServerName: "feed.local", NextProtos: []string{"h2"}}
+	if clientCert != nil {
+		pair, _ := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+		config.Certificates = []tls.Certificate{pair}
+	}
+	client := tls.Client(clientConn, config)
+	if err := client.Handshake(); err != nil {
+		return nil, err
+	}
+	// TLS 1.3 reports a rejected client certificate on the first read
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err != nil && !isTimeoutOrEOF(err) {
+		return nil, err
+	}
+	return client.ConnectionState().PeerCertificates[0], nil
+}
+
+func isTimeoutOrEOF(err error) bool {
+	netErr, ok := err.(net.Error)
+	return (ok && netErr.Timeout()) || err.Error() == "EOF"
+}
+
+func TestCertReloaderRequiresClientCertificates(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "tls")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil)
+	certFile, keyFile, caFile := writeFiles(t, dir, newTestCert(t, "feed.local", ca), ca)
+
+	reloader, err := NewCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if _, err := handshake(reloader.TLSConfig(), ca, newTestCert(t, "bot", ca)); err != nil {
+		t.Errorf("Expected a client signed by the CA to connect, got %s", err)
+	}
+	if _, err := handshake(reloader.TLSConfig(), ca, nil); err == nil {
+		t.Errorf("Expected a client without a certificate to be rejected")
+	}
+	if _, err := handshake(reloader.TLSConfig(), ca, newTestCert(t, "stranger", newTestCert(t, "other-ca", nil))); err == nil {
+		t.Errorf("Expected a client signed by another CA to be rejected")
+	}
+}
+
+func TestCertReloaderKeepsPreviousCertificateOnError(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "tls")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil)
+	first := newTestCert(t, "feed.local", ca)
+	certFile, keyFile, _ := writeFiles(t, dir, first, ca)
+	reloader, err := NewCertReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	second := newTestCert(t, "feed.local", ca)
+	writeFiles(t, dir, second, ca)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if served, err := handshake(reloader.TLSConfig(), ca, nil); err != nil || !served.Equal(second.certificate) {
+		t.Errorf("Expected the reloaded certificate to be served, got %v", err)
+	}
+
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	if err := reloader.Reload(); err == nil {
+		t.Errorf("Expected an invalid key to fail reloading")
+	}
+	if served, err := handshake(reloader.TLSConfig(), ca, nil); err != nil || !served.Equal(second.certificate) {
+		t.Errorf("Expected the previous certificate to be kept, got %v", err)
+	}
+}
+
+func TestIdentityInterceptorUsesClientCertificate(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	client := newTestCert(t, "pricing-bot", ca)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{client.certificate, ca.certificate}},
+	}}})
+
+	var identity *Identity
+	UnaryIdentityInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/OrderbookService/BuyBase"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		identity, _ = IdentityFromContext(ctx)
+		return nil, nil
+	})
+	if identity == nil || identity.Name != "pricing-bot" || len(identity.Fingerprint) != 64 {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+
+	UnaryIdentityInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/OrderbookService/BuyBase"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		identity, _ = IdentityFromContext(ctx)
+		return nil, nil
+	})
+	if identity != nil {
+		t.Errorf("Expected no identity without a client certificate, got %+v", identity)
+	}
+}
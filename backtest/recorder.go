@@ -0,0 +1,42 @@
+package backtest
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Frame is a raw websocket frame, alongside the time it was received.
+type Frame struct {
+	// Time is in unix nanoseconds.
+	Time int64           `json:"time"`
+	Data json.RawMessage `json:"frame"`
+}
+
+// Recorder writes websocket frames to a recording, one JSON encoded Frame per line.
+type Recorder struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewRecorder creates a recorder writing to `w`.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Record appends a frame to the recording. Frames that cannot be written are logged and skipped.
+func (r *Recorder) Record(receivedAt time.Time, frame []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !json.Valid(frame) {
+		log.Warningln("Skipped recording a frame that is not valid JSON")
+		return
+	}
+	if err := r.encoder.Encode(&Frame{Time: receivedAt.UnixNano(), Data: frame}); err != nil {
+		log.WithField("err", err.Error()).Errorln("Unable to record frame")
+	}
+}
@@ -0,0 +1,214 @@
+package backtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"pirosb3/real_feed/clock"
+	"pirosb3/real_feed/datasource"
+	"pirosb3/real_feed/feed"
+	"pirosb3/real_feed/paper"
+)
+
+// BACKTEST_ACCOUNT is the paper trading account orders are placed from.
+const BACKTEST_ACCOUNT = "backtest"
+
+// MAX_FRAME_SIZE is the largest frame a recording may contain, level2 snapshots are large.
+const MAX_FRAME_SIZE = 64 * 1024 * 1024
+
+// Strategy is implemented by the quoting logic under test. OnUpdate is called after every change
+// to the book, and an error aborts the backtest.
+type Strategy interface {
+	OnUpdate(session *Session) error
+}
+
+type QuoteRecord struct {
+	Time      time.Time
+	Operation string
+	Amount    float64
+	Result    float64
+	Error     string `json:",omitempty"`
+}
+
+type RejectedOrder struct {
+	Time       time.Time
+	Type       string
+	Side       string
+	Size       float64
+	LimitPrice float64
+	Error      string
+}
+
+// Report is the outcome of a backtest. Replaying the same recording with the same strategy always
+// produces the same report.
+type Report struct {
+	ProductID      string
+	Start          time.Time
+	End            time.Time
+	Frames         int
+	InvalidFrames  int
+	Updates        int
+	Quotes         []QuoteRecord
+	Orders         []*paper.Order
+	RejectedOrders []RejectedOrder
+	Account        *paper.AccountSummary
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Session is handed to the strategy on every update. All times are simulated, taken from the
+// recording.
+type Session struct {
+	clock  *clock.Manual
+	book   *feed.OrderbookFeed
+	engine *paper.Engine
+	report *Report
+}
+
+// Now returns the simulated time of the update being processed.
+func (s *Session) Now() time.Time {
+	return s.clock.Now()
+}
+
+// Book returns the replayed orderbook. Quotes made directly on it are not part of the report.
+func (s *Session) Book() *feed.OrderbookFeed {
+	return s.book
+}
+
+// Quote runs one of the quote operations against the replayed book and records it in the report.
+func (s *Session) Quote(operation string, amount float64) (float64, error) {
+	result, _, err := s.book.Quote(operation, amount)
+	record := QuoteRecord{Time: s.clock.Now(), Operation: operation, Amount: amount, Result: result}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	s.report.Quotes = append(s.report.Quotes, record)
+	return result, err
+}
+
+// PlaceOrder simulates an order against the replayed book. Rejected orders are recorded in the report.
+func (s *Session) PlaceOrder(orderType string, side string, size float64, limitPrice float64) (*paper.Order, error) {
+	order, err := s.engine.PlaceOrder(BACKTEST_ACCOUNT, orderType, side, size, limitPrice)
+	if err != nil {
+		s.report.RejectedOrders = append(s.report.RejectedOrders, RejectedOrder{
+			Time:       s.clock.Now(),
+			Type:       orderType,
+			Side:       side,
+			Size:       size,
+			LimitPrice: limitPrice,
+			Error:      err.Error(),
+		})
+	}
+	return order, err
+}
+
+// CancelOrder cancels a resting limit order.
+func (s *Session) CancelOrder(orderID string) (*paper.Order, error) {
+	return s.engine.CancelOrder(BACKTEST_ACCOUNT, orderID)
+}
+
+// OpenOrders returns the resting limit orders, oldest first.
+func (s *Session) OpenOrders() []*paper.Order {
+	return s.engine.Orders(BACKTEST_ACCOUNT, true)
+}
+
+// Account returns the balances and P&L of the backtest account.
+func (s *Session) Account() (*paper.AccountSummary, error) {
+	return s.engine.GetAccount(BACKTEST_ACCOUNT)
+}
+
+// Run replays a recording through a fresh orderbook, calling `strategy` after every update. Time
+// only advances with the recording, so staleness checks and order timestamps are deterministic.
+// Every frame is replayed at the time it was recorded at, rather than at its exchange time, as
+// snapshots do not carry one.
+// The backtest account is funded with `base` and `quote` once the first snapshot is replayed.
+func Run(recording io.Reader, productID string, strategy Strategy, base float64, quote float64) (*Report, error) {
+	session := &Session{
+		clock:  clock.NewManual(time.Time{}),
+		book:   feed.NewOrderbookFeed(productID),
+		report: &Report{ProductID: productID},
+	}
+	session.engine = paper.NewEngine(productID, session.book)
+	session.book.SetClock(session.clock)
+	session.engine.SetClock(session.clock)
+
+	funded := false
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_FRAME_SIZE)
+	for scanner.Scan() {
+		session.report.Frames++
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			session.report.InvalidFrames++
+			continue
+		}
+		msg, err := datasource.DecodeMessage(frame.Data)
+		if err != nil {
+			session.report.InvalidFrames++
+			continue
+		}
+		now := time.Unix(0, frame.Time).UTC()
+		session.clock.Set(now)
+		if session.report.Start.IsZero() {
+			session.report.Start = now
+		}
+		session.report.End = now
+
+		switch typedMsg := msg.(type) {
+		case *feed.L2SnapshotMessage:
+			if typedMsg.ProductID != productID {
+				continue
+			}
+			bids, asks := typedMsg.Updates()
+			session.book.SetSnapshot(now.Unix(), bids, asks)
+			if !funded {
+				if err := session.engine.CreateAccount(BACKTEST_ACCOUNT, base, quote); err != nil {
+					return session.report, err
+				}
+				funded = true
+			}
+		case *feed.L2UpdateMessage:
+			if typedMsg.ProductID != productID || !funded {
+				continue
+			}
+			bids, asks := typedMsg.Updates()
+			session.book.WriteUpdate(now.Unix(), bids, asks)
+		case *feed.MatchMessage:
+			if typedMsg.ProductID == productID && funded {
+				session.engine.OnTrade(typedMsg.Trade())
+			}
+			continue
+		default:
+			continue
+		}
+
+		session.report.Updates++
+		session.engine.OnBookUpdate()
+		if err := strategy.OnUpdate(session); err != nil {
+			return session.report, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return session.report, err
+	}
+
+	session.report.Orders = session.engine.Orders(BACKTEST_ACCOUNT, false)
+	if funded {
+		account, err := session.engine.GetAccount(BACKTEST_ACCOUNT)
+		if err != nil {
+			return session.report, err
+		}
+		session.report.Account = account
+	}
+	return session.report, nil
+}
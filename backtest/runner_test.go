@@ -0,0 +1,86 @@
+package backtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/feed"
+	"pirosb3/real_feed/paper"
+)
+
+type testStrategy struct {
+	placed bool
+}
+
+func (ts *testStrategy) OnUpdate(session *Session) error {
+	session.Quote(feed.BUY_BASE, 1)
+	if !ts.placed {
+		ts.placed = true
+		session.PlaceOrder(paper.ORDER_MARKET, feed.BUY, 1, 0)
+		session.PlaceOrder(paper.ORDER_LIMIT, feed.SELL, 1, 103)
+	}
+	return nil
+}
+
+func makeRecording() []byte {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	var buffer bytes.Buffer
+	recorder := NewRecorder(&buffer)
+	recorder.Record(start, []byte(`{"type":"snapshot","product_id":"ETH-USD","bids":[["99","1"],["98","2"]],"asks":[["101","1"],["102","2"]]}`))
+	recorder.Record(start.Add(time.Second), []byte(`{"type":"l2update","product_id":"ETH-USD","time":"2020-10-01T12:00:01.000000Z","changes":[["buy","100","1"]]}`))
+	recorder.Record(start.Add(2*time.Second), []byte(`{"type":"match","trade_id":1,"sequence":1,"maker_order_id":"a","taker_order_id":"b","time":"2020-10-01T12:00:02.000000Z","product_id":"ETH-USD","size":"2","price":"104","side":"sell"}`))
+	recorder.Record(start.Add(3*time.Second), []byte(`{"type":"l2update","product_id":"BTC-USD","time":"2020-10-01T12:00:03.000000Z","changes":[["buy","100","1"]]}`))
+	recorder.Record(start.Add(4*time.Second), []byte(`{"type":"l2update","product_id":"ETH-USD","time":"2020-10-01T12:00:04.000000Z","changes":[["buy","abc","1"]]}`))
+	// Received long after its exchange time, which must not make the book stale on replay
+	recorder.Record(start.Add(20*time.Second), []byte(`{"type":"l2update","product_id":"ETH-USD","time":"2020-10-01T12:00:05.000000Z","changes":[["sell","101.5","1"]]}`))
+	return buffer.Bytes()
+}
+
+func TestBacktestIsDeterministic(t *testing.T) {
+	recording := makeRecording()
+
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		report, err := Run(bytes.NewReader(recording), "ETH-USD", &testStrategy{}, 0, 1000)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var buffer bytes.Buffer
+		report.WriteJSON(&buffer)
+		outputs = append(outputs, buffer.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("Expected replaying the same recording to produce identical reports")
+	}
+}
+
+func TestBacktestReport(t *testing.T) {
+	report, err := Run(bytes.NewReader(makeRecording()), "ETH-USD", &testStrategy{}, 0, 1000)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.Frames != 6 || report.InvalidFrames != 1 || report.Updates != 3 {
+		t.Errorf("Unexpected frame counts %d %d %d", report.Frames, report.InvalidFrames, report.Updates)
+	}
+	if len(report.Quotes) != 3 {
+		t.Fatalf("Expected a quote per update, got %d", len(report.Quotes))
+	}
+	if report.Quotes[0].Result != 101 || report.Quotes[0].Error != "" {
+		t.Errorf("Unexpected first quote %+v", report.Quotes[0])
+	}
+	if report.Quotes[2].Error != "" {
+		t.Errorf("Expected updates to be replayed at the time they were recorded, got %+v", report.Quotes[2])
+	}
+
+	// The market buy filled at 101, and the trade at 104 filled the resting sell at 103
+	if len(report.Orders) != 2 || report.Orders[0].AveragePrice() != 101 || report.Orders[1].Status != paper.STATUS_FILLED {
+		t.Fatalf("Unexpected orders %+v", report.Orders)
+	}
+	if report.Orders[1].Fills[0].Time != report.Start.Add(2*time.Second) {
+		t.Errorf("Expected fills to be timestamped in simulated time, got %s", report.Orders[1].Fills[0].Time)
+	}
+	if report.Account.RealizedPnL != 2 || report.Account.Quote != 1002 {
+		t.Errorf("Unexpected account %+v", report.Account)
+	}
+}
@@ -0,0 +1,243 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules ticks. Components take a Clock rather than calling the
+// time package directly, so that tests and replays can drive time explicitly.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker delivers ticks on Chan() at a fixed interval until stopped.
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// Timer delivers a single tick on Chan() once its duration elapsed. Unlike After, it can be
+// stopped and reset, so that loops waiting on it do not leave a timer behind every iteration.
+type Timer interface {
+	Chan() <-chan time.Time
+	Stop()
+	// Reset discards any pending tick and restarts the timer with duration `d`.
+	Reset(d time.Duration)
+}
+
+type realClock struct{}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+// NewReal returns a clock backed by the system time.
+func NewReal() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+func (rt *realTicker) Chan() <-chan time.Time {
+	return rt.ticker.C
+}
+
+func (rt *realTicker) Stop() {
+	rt.ticker.Stop()
+}
+
+func (rt *realTimer) Chan() <-chan time.Time {
+	return rt.timer.C
+}
+
+func (rt *realTimer) Stop() {
+	rt.timer.Stop()
+}
+
+func (rt *realTimer) Reset(d time.Duration) {
+	if !rt.timer.Stop() {
+		select {
+		case <-rt.timer.C:
+		default:
+		}
+	}
+	rt.timer.Reset(d)
+}
+
+type manualTicker struct {
+	clock  *Manual
+	c      chan (time.Time)
+	period time.Duration
+	next   time.Time
+}
+
+type manualTimer struct {
+	clock    *Manual
+	c        chan (time.Time)
+	deadline time.Time
+}
+
+// Manual is a clock that only moves when told to. Tickers and timers fire as the clock is moved
+// past their deadline. Like the system tickers, ticks are dropped if the previous one was not
+// received yet.
+type Manual struct {
+	lock    sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+	waiters []*manualTimer
+}
+
+// NewManual creates a manual clock set to `start`.
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+func (m *Manual) Now() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.now
+}
+
+func (m *Manual) After(d time.Duration) <-chan time.Time {
+	return m.NewTimer(d).Chan()
+}
+
+func (m *Manual) NewTimer(d time.Duration) Timer {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	timer := &manualTimer{clock: m, c: make(chan (time.Time), 1)}
+	m.start(timer, d)
+	return timer
+}
+
+// start schedules `timer` to fire after `d`, or fires it right away if `d` is not positive. The
+// lock must be held.
+func (m *Manual) start(timer *manualTimer, d time.Duration) {
+	timer.deadline = m.now.Add(d)
+	if d <= 0 {
+		timer.c <- m.now
+		return
+	}
+	m.waiters = append(m.waiters, timer)
+}
+
+// remove unschedules `timer`. The lock must be held.
+func (m *Manual) remove(timer *manualTimer) {
+	waiters := m.waiters[:0]
+	for _, waiter := range m.waiters {
+		if waiter != timer {
+			waiters = append(waiters, waiter)
+		}
+	}
+	m.waiters = waiters
+}
+
+func (m *Manual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ticker := &manualTicker{clock: m, c: make(chan (time.Time), 1), period: d, next: m.now.Add(d)}
+	m.tickers = append(m.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward by `d`.
+func (m *Manual) Advance(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set moves the clock to `now`, firing every ticker and timer whose deadline was reached. The
+// clock may be moved backwards, in which case nothing fires.
+func (m *Manual) Set(now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.now = now
+	waiters := m.waiters[:0]
+	for _, waiter := range m.waiters {
+		if now.Before(waiter.deadline) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		waiter.c <- now
+	}
+	m.waiters = waiters
+
+	for _, ticker := range m.tickers {
+		if now.Before(ticker.next) {
+			continue
+		}
+		select {
+		case ticker.c <- ticker.next:
+		default:
+		}
+		// Ticks of the periods skipped over are dropped
+		missed := now.Sub(ticker.next) / ticker.period
+		ticker.next = ticker.next.Add((missed + 1) * ticker.period)
+	}
+}
+
+func (mt *manualTicker) Chan() <-chan time.Time {
+	return mt.c
+}
+
+func (mt *manualTicker) Stop() {
+	mt.clock.lock.Lock()
+	defer mt.clock.lock.Unlock()
+
+	tickers := mt.clock.tickers[:0]
+	for _, ticker := range mt.clock.tickers {
+		if ticker != mt {
+			tickers = append(tickers, ticker)
+		}
+	}
+	mt.clock.tickers = tickers
+}
+
+func (mt *manualTimer) Chan() <-chan time.Time {
+	return mt.c
+}
+
+func (mt *manualTimer) Stop() {
+	mt.clock.lock.Lock()
+	defer mt.clock.lock.Unlock()
+	mt.clock.remove(mt)
+}
+
+func (mt *manualTimer) Reset(d time.Duration) {
+	mt.clock.lock.Lock()
+	defer mt.clock.lock.Unlock()
+
+	mt.clock.remove(mt)
+	select {
+	case <-mt.c:
+	default:
+	}
+	mt.clock.start(mt, d)
+}
@@ -0,0 +1,112 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClockFiresTickersAndTimers(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewManual(start)
+	ticker := clock.NewTicker(2 * time.Second)
+	timer := clock.After(5 * time.Second)
+
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.Chan():
+		t.Error("Ticker fired before its interval elapsed")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case tick := <-ticker.Chan():
+		if !tick.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("Unexpected tick %s", tick)
+		}
+	default:
+		t.Error("Ticker did not fire")
+	}
+
+	// Ticks that are not received are dropped, rather than queued
+	clock.Advance(10 * time.Second)
+	<-ticker.Chan()
+	select {
+	case <-ticker.Chan():
+		t.Error("Expected a single pending tick")
+	default:
+	}
+
+	select {
+	case fired := <-timer:
+		if !fired.Equal(start.Add(12 * time.Second)) {
+			t.Errorf("Unexpected timer %s", fired)
+		}
+	default:
+		t.Error("Timer did not fire")
+	}
+
+	ticker.Stop()
+	clock.Advance(10 * time.Second)
+	select {
+	case <-ticker.Chan():
+		t.Error("Stopped ticker fired")
+	default:
+	}
+	if !clock.Now().Equal(start.Add(22 * time.Second)) {
+		t.Errorf("Unexpected time %s", clock.Now())
+	}
+}
+
+func TestManualTickerSkipsMissedPeriods(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewManual(start)
+	ticker := clock.NewTicker(time.Nanosecond)
+	defer ticker.Stop()
+
+	// Catching up is not proportional to the number of periods elapsed
+	clock.Advance(24 * time.Hour)
+	if tick := <-ticker.Chan(); !tick.Equal(start.Add(time.Nanosecond)) {
+		t.Errorf("Unexpected tick %s", tick)
+	}
+	clock.Advance(time.Nanosecond)
+	if tick := <-ticker.Chan(); !tick.Equal(start.Add(24*time.Hour + time.Nanosecond)) {
+		t.Errorf("Unexpected tick %s", tick)
+	}
+}
+
+func TestManualTimersCanBeStoppedAndReset(t *testing.T) {
+	clock := NewManual(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC))
+	timer := clock.NewTimer(5 * time.Second)
+	for idx := 0; idx < 10; idx++ {
+		clock.Advance(time.Second)
+		timer.Reset(5 * time.Second)
+	}
+	if len(clock.waiters) != 1 {
+		t.Errorf("Expected resetting to reuse the timer, got %d waiters", len(clock.waiters))
+	}
+	clock.Advance(4 * time.Second)
+	select {
+	case <-timer.Chan():
+		t.Error("Timer fired before its reset deadline")
+	default:
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-timer.Chan():
+	default:
+		t.Error("Timer did not fire")
+	}
+
+	timer.Reset(time.Second)
+	timer.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-timer.Chan():
+		t.Error("Stopped timer fired")
+	default:
+	}
+	if len(clock.waiters) != 0 {
+		t.Errorf("Expected stopped timers to be removed, got %d waiters", len(clock.waiters))
+	}
+}
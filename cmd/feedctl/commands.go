@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"pirosb3/real_feed/auth"
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/rpc"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type cli struct {
+	client   rpc.OrderbookServiceClient
+	quotes   rpcv2.OrderbookServiceClient
+	out      io.Writer
+	json     bool
+	apiKey   string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	minArgs int
+	maxArgs int
+	run     func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = []command{
+	{"quote", "quote PRODUCT buy-base|buy-quote|sell-base|sell-quote AMOUNT", "Price a market order against the live book", 3, 3, runQuote},
+	{"ticker", "ticker PRODUCT", "Show the best bid and ask", 1, 1, runTicker},
+	{"depth", "depth PRODUCT [LEVELS]", "Show the top levels of the book", 1, 2, runDepth},
+	{"watch", "watch PRODUCT", "Show the ticker every time the book changes", 1, 1, runWatch},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// call returns a context for a single request, carrying the API key if there is one. Failures are
+// requested as status errors, so that they carry a code and a reason.
+func (c *cli) call(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = metadata.AppendToOutgoingContext(ctx, controller.ERROR_MODE_METADATA, controller.STATUS_ERROR_MODE)
+	if c.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, auth.API_KEY_METADATA, c.apiKey)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// errorMessage describes a failed request with its status code and reason.
+func errorMessage(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err.Error()
+	}
+	message := fmt.Sprintf("%s: %s", st.Code(), st.Message())
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			message += " (" + info.GetReason() + ")"
+		}
+	}
+	return message
+}
+
+// checkAmount rejects amounts which are not positive decimals. They are sent to the server as
+// given, so that they are priced without losing precision.
+func checkAmount(value string) error {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("Amount '%s' must be a positive number", value)
+	}
+	return nil
+}
+
+type quoteOperation struct {
+	side       rpcv2.Side
+	amountType rpcv2.AmountType
+}
+
+var quoteOperations = map[string]quoteOperation{
+	"buy-base":   {rpcv2.Side_BUY, rpcv2.AmountType_BASE},
+	"buy-quote":  {rpcv2.Side_BUY, rpcv2.AmountType_QUOTE},
+	"sell-base":  {rpcv2.Side_SELL, rpcv2.AmountType_BASE},
+	"sell-quote": {rpcv2.Side_SELL, rpcv2.AmountType_QUOTE},
+}
+
+func runQuote(ctx context.Context, c *cli, args []string) error {
+	product, operation, amount := args[0], strings.ToLower(args[1]), args[2]
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	op, ok := quoteOperations[operation]
+	if !ok {
+		return fmt.Errorf("Unsupported operation '%s'", args[1])
+	}
+
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	response, err := c.quotes.Quote(ctx, &rpcv2.QuoteRequest{
+		Product:    product,
+		Side:       op.side,
+		AmountType: op.amountType,
+		Amount:     amount,
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(c.out, response)
+	}
+	return printTable(c.out, []string{"PRODUCT", "OPERATION", "IN", "OUT", "PRICE", "LAST UPDATED"}, [][]string{{
+		response.GetProduct(),
+		operation,
+		response.GetAmount(),
+		response.GetCounterAmount(),
+		response.GetPrice(),
+		formatSeconds(response.GetMetadata().GetLastUpdated()),
+	}})
+}
+
+func tickerRow(ticker *rpc.TickerResponse) []string {
+	return []string{
+		formatFloat(ticker.GetBestBid()),
+		formatFloat(ticker.GetBestBidSize()),
+		formatFloat(ticker.GetBestAsk()),
+		formatFloat(ticker.GetBestAskSize()),
+		strconv.FormatFloat(ticker.GetSpreadBps(), 'f', 2, 64),
+		formatFloat(ticker.GetLastTradePrice()),
+		strconv.FormatUint(ticker.GetBookVersion(), 10),
+	}
+}
+
+var tickerHeader = []string{"BID", "BID SIZE", "ASK", "ASK SIZE", "SPREAD BPS", "LAST TRADE", "VERSION"}
+
+func (c *cli) ticker(ctx context.Context, product string) (*rpc.TickerResponse, error) {
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	return c.client.GetTicker(ctx, &rpc.TickerRequest{Product: product})
+}
+
+func runTicker(ctx context.Context, c *cli, args []string) error {
+	ticker, err := c.ticker(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(c.out, ticker)
+	}
+	return printTable(c.out, tickerHeader, [][]string{tickerRow(ticker)})
+}
+
+func runDepth(ctx context.Context, c *cli, args []string) error {
+	levels := 0
+	if len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("Levels '%s' must be a positive integer", args[1])
+		}
+		levels = parsed
+	}
+
+	ctx, cancel := c.call(ctx)
+	defer cancel()
+	depth, err := c.client.GetDepth(ctx, &rpc.DepthRequest{Product: args[0], Levels: int32(levels)})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return printJSON(c.out, depth)
+	}
+	// Printed as a ladder: asks from the worst to the best, then bids from the best to the worst
+	rows := make([][]string, 0, len(depth.GetAsks())+len(depth.GetBids()))
+	for idx := len(depth.GetAsks()) - 1; idx >= 0; idx-- {
+		ask := depth.GetAsks()[idx]
+		rows = append(rows, []string{"ask", formatFloat(ask.GetPrice()), formatFloat(ask.GetSize())})
+	}
+	for _, bid := range depth.GetBids() {
+		rows = append(rows, []string{"bid", formatFloat(bid.GetPrice()), formatFloat(bid.GetSize())})
+	}
+	return printTable(c.out, []string{"SIDE", "PRICE", "SIZE"}, rows)
+}
+
+// runWatch polls the ticker every interval and prints it whenever the book version changed, until
+// the command is interrupted.
+func runWatch(ctx context.Context, c *cli, args []string) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	lastVersion := uint64(0)
+	printedHeader := false
+	for {
+		response, err := c.ticker(ctx, args[0])
+		if err != nil && ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if response.GetBookVersion() != lastVersion {
+			lastVersion = response.GetBookVersion()
+			if c.json {
+				err = printJSONLine(c.out, response)
+			} else {
+				row := append([]string{time.Now().Format("15:04:05")}, tickerRow(response)...)
+				header := []string(nil)
+				if !printedHeader {
+					header = append([]string{"TIME"}, tickerHeader...)
+					printedHeader = true
+				}
+				err = printTable(c.out, header, [][]string{row})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Command feedctl queries a running feed over gRPC. It is meant for on-call engineers inspecting
+// an instance:
+//
+//	feedctl quote BTC-USD buy-base 2.5
+//	feedctl -output json depth BTC-USD 5
+//	feedctl watch BTC-USD
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"pirosb3/real_feed/rpc"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	DEFAULT_ADDR     = "localhost:8000"
+	DEFAULT_TIMEOUT  = 5 * time.Second
+	DEFAULT_INTERVAL = time.Second
+	OUTPUT_TABLE     = "table"
+	OUTPUT_JSON      = "json"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan (os.Signal), 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command in `args` and returns the exit code: 0 on success, 1 if the command
+// failed and 2 if it was misused.
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("feedctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", envOr("FEEDCTL_ADDR", DEFAULT_ADDR), "Address of the gRPC server, defaults to $FEEDCTL_ADDR")
+	output := flags.String("output", OUTPUT_TABLE, "Output format, table or json")
+	timeout := flags.Duration("timeout", DEFAULT_TIMEOUT, "Timeout of every request")
+	interval := flags.Duration("interval", DEFAULT_INTERVAL, "Polling interval of watch")
+	apiKey := flags.String("api-key", os.Getenv("FEEDCTL_API_KEY"), "API key sent with every request, defaults to $FEEDCTL_API_KEY")
+	useTLS := flags.Bool("tls", false, "Connect using TLS, implied by the other TLS flags")
+	caFile := flags.String("ca-file", "", "CAs the server certificate is verified against, defaults to the system ones")
+	certFile := flags.String("cert-file", "", "Client certificate, for servers using mutual TLS")
+	keyFile := flags.String("key-file", "", "Private key of the client certificate")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: feedctl [flags] <command> [arguments]\n\nCommands:")
+		for _, cmd := range commands {
+			fmt.Fprintf(stderr, "  %-44s %s\n", cmd.usage, cmd.summary)
+		}
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *output != OUTPUT_TABLE && *output != OUTPUT_JSON {
+		fmt.Fprintf(stderr, "feedctl: unsupported output '%s'\n", *output)
+		return 2
+	}
+	cmd, ok := findCommand(flags.Arg(0))
+	if !ok {
+		flags.Usage()
+		return 2
+	}
+	cmdArgs := flags.Args()[1:]
+	if len(cmdArgs) < cmd.minArgs || len(cmdArgs) > cmd.maxArgs {
+		fmt.Fprintf(stderr, "Usage: feedctl %s\n", cmd.usage)
+		return 2
+	}
+
+	transport := grpc.WithInsecure()
+	if *useTLS || *caFile != "" || *certFile != "" || *keyFile != "" {
+		config, err := clientTLSConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "feedctl: %s\n", err)
+			return 2
+		}
+		transport = grpc.WithTransportCredentials(credentials.NewTLS(config))
+	}
+	conn, err := grpc.DialContext(ctx, *addr, transport)
+	if err != nil {
+		fmt.Fprintf(stderr, "feedctl: %s\n", err)
+		return 1
+	}
+	defer conn.Close()
+	c := &cli{
+		client:   rpc.NewOrderbookServiceClient(conn),
+		quotes:   rpcv2.NewOrderbookServiceClient(conn),
+		out:      stdout,
+		json:     *output == OUTPUT_JSON,
+		apiKey:   *apiKey,
+		timeout:  *timeout,
+		interval: *interval,
+	}
+	if err := cmd.run(ctx, c, cmdArgs); err != nil {
+		fmt.Fprintf(stderr, "feedctl: %s\n", errorMessage(err))
+		return 1
+	}
+	return 0
+}
+
+func clientTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errors.New("No certificate found in " + caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("-cert-file and -key-file must be set together")
+	}
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
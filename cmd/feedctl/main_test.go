@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/feed"
+	"pirosb3/real_feed/rpc"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestCLI(t *testing.T, asJSON bool) (*cli, *bytes.Buffer, func()) {
+	fc := controller.NewFeedController(context.Background(), "ETH-DAI")
+	fc.Orderbook().SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "333.2", Size: "0.5"},
+		&feed.Update{Price: "320", Size: "0.5"},
+	}, []*feed.Update{
+		&feed.Update{Price: "335.12", Size: "0.5"},
+	})
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	rpc.RegisterOrderbookServiceServer(server, *controller.NewOrderbookGrpcController(fc, "ETH-DAI"))
+	rpcv2.RegisterOrderbookServiceServer(server, *controller.NewOrderbookGrpcControllerV2(fc, "ETH-DAI"))
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("Unable to dial: %s", err)
+	}
+	out := &bytes.Buffer{}
+	c := &cli{client: rpc.NewOrderbookServiceClient(conn), quotes: rpcv2.NewOrderbookServiceClient(conn), out: out, json: asJSON, timeout: DEFAULT_TIMEOUT, interval: DEFAULT_INTERVAL}
+	return c, out, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestQuoteTable(t *testing.T) {
+	c, out, stop := newTestCLI(t, false)
+	defer stop()
+	if err := runQuote(context.Background(), c, []string{"ETH-DAI", "sell-base", "0.6"}); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "PRODUCT") || !strings.Contains(lines[1], "198.6") {
+		t.Errorf("Unexpected table\n%s", out.String())
+	}
+}
+
+func TestDepthJSON(t *testing.T) {
+	c, out, stop := newTestCLI(t, true)
+	defer stop()
+	if err := runDepth(context.Background(), c, []string{"ETH-DAI", "1"}); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	depth := make(map[string]interface{})
+	if err := json.Unmarshal(out.Bytes(), &depth); err != nil {
+		t.Fatalf("Expected JSON, got %s", out.String())
+	}
+	if bids := depth["bids"].([]interface{}); len(bids) != 1 || bids[0].(map[string]interface{})["price"].(float64) != 333.2 {
+		t.Errorf("Unexpected bids %v", depth["bids"])
+	}
+}
+
+func TestErrorsIncludeReason(t *testing.T) {
+	c, _, stop := newTestCLI(t, false)
+	defer stop()
+	err := runQuote(context.Background(), c, []string{"ETH-DAI", "sell-base", "5"})
+	if err == nil || !strings.Contains(errorMessage(err), controller.REASON_INSUFFICIENT_LIQUIDITY) {
+		t.Errorf("Expected insufficient liquidity, got %v", err)
+	}
+	if err := runQuote(context.Background(), c, []string{"ETH-DAI", "hold", "5"}); err == nil {
+		t.Errorf("Expected an unsupported operation")
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	if code := run(context.Background(), []string{"quote", "ETH-DAI"}, &bytes.Buffer{}, stderr); code != 2 {
+		t.Errorf("Expected a usage error, got %d", code)
+	}
+	if code := run(context.Background(), []string{"-output", "yaml", "ticker", "ETH-DAI"}, &bytes.Buffer{}, stderr); code != 2 {
+		t.Errorf("Expected an unsupported output, got %d", code)
+	}
+}
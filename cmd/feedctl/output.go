@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// TABLE_COLUMN_WIDTH is the minimum width of a column, which keeps the rows printed by watch
+// aligned even though every row is printed on its own.
+const TABLE_COLUMN_WIDTH = 12
+
+// printTable prints `rows` aligned in columns, under `header` unless it is nil.
+func printTable(out io.Writer, header []string, rows [][]string) error {
+	writer := tabwriter.NewWriter(out, TABLE_COLUMN_WIDTH, 0, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(writer, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
+
+// printJSON prints `message` using the protobuf JSON mapping, including fields with zero values.
+func printJSON(out io.Writer, message proto.Message) error {
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+// printJSONLine prints `message` on a single line, for streams of messages.
+func printJSONLine(out io.Writer, message proto.Message) error {
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatSeconds(epoch int64) string {
+	if epoch <= 0 {
+		return "-"
+	}
+	return time.Unix(epoch, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,377 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/datasource"
+	"pirosb3/real_feed/feed"
+	"pirosb3/real_feed/history"
+
+	"gopkg.in/yaml.v2"
+)
+
+var marketPattern = regexp.MustCompile(`^[A-Z0-9]+-[A-Z0-9]+$`)
+
+// MarketConfig holds the settings that can be overridden per market. Zero values in an override
+// inherit the top-level setting.
+type MarketConfig struct {
+	StaleBookSecs      int64 `yaml:"staleBookSecs,omitempty" json:"staleBookSecs,omitempty"`
+	HeartbeatTTLSecs   int64 `yaml:"heartbeatTTLSecs,omitempty" json:"heartbeatTTLSecs,omitempty"`
+	ChannelBufferSize  int   `yaml:"channelBufferSize,omitempty" json:"channelBufferSize,omitempty"`
+	ReportIntervalSecs int64 `yaml:"reportIntervalSecs,omitempty" json:"reportIntervalSecs,omitempty"`
+	// Level is 2 for the aggregated book, or 3 for the order-by-order book.
+	Level int `yaml:"level,omitempty" json:"level,omitempty"`
+}
+
+// merge returns the settings of `mc`, with those set in `override` replaced.
+func (mc MarketConfig) merge(override MarketConfig) MarketConfig {
+	if override.StaleBookSecs != 0 {
+		mc.StaleBookSecs = override.StaleBookSecs
+	}
+	if override.HeartbeatTTLSecs != 0 {
+		mc.HeartbeatTTLSecs = override.HeartbeatTTLSecs
+	}
+	if override.ChannelBufferSize != 0 {
+		mc.ChannelBufferSize = override.ChannelBufferSize
+	}
+	if override.ReportIntervalSecs != 0 {
+		mc.ReportIntervalSecs = override.ReportIntervalSecs
+	}
+	if override.Level != 0 {
+		mc.Level = override.Level
+	}
+	return mc
+}
+
+func (mc MarketConfig) validate(name string) []string {
+	var problems []string
+	if mc.StaleBookSecs <= 0 {
+		problems = append(problems, name+"staleBookSecs must be positive")
+	}
+	if mc.HeartbeatTTLSecs <= 0 {
+		problems = append(problems, name+"heartbeatTTLSecs must be positive")
+	}
+	if mc.ChannelBufferSize <= 0 {
+		problems = append(problems, name+"channelBufferSize must be positive")
+	}
+	if mc.ReportIntervalSecs <= 0 {
+		problems = append(problems, name+"reportIntervalSecs must be positive")
+	}
+	if mc.Level != 2 && mc.Level != 3 {
+		problems = append(problems, name+"level must be 2 or 3")
+	}
+	return problems
+}
+
+// Config is the configuration of the service. It is built from defaults, then a YAML or JSON
+// file, then environment variables, then flags, each overriding the previous one.
+type Config struct {
+	Market      string `yaml:"market" json:"market"`
+	GRPCAddr    string `yaml:"grpcAddr" json:"grpcAddr"`
+	MetricsAddr string `yaml:"metricsAddr" json:"metricsAddr"`
+	// GatewayAddr is the address of the HTTP/JSON gateway, which is disabled when empty.
+	GatewayAddr string `yaml:"gatewayAddr,omitempty" json:"gatewayAddr,omitempty"`
+	// StreamAddr is the address of the websocket server for browsers, which is disabled when empty.
+	StreamAddr   string `yaml:"streamAddr,omitempty" json:"streamAddr,omitempty"`
+	WebsocketURL string `yaml:"websocketURL" json:"websocketURL"`
+
+	// TLSCertFile and TLSKeyFile enable TLS on the gRPC server, the gateway and the websocket
+	// server. Clients must also present a certificate signed by a CA of TLSClientCAFile when it is
+	// set. The files are reloaded when they change.
+	TLSCertFile     string `yaml:"tlsCertFile,omitempty" json:"tlsCertFile,omitempty"`
+	TLSKeyFile      string `yaml:"tlsKeyFile,omitempty" json:"tlsKeyFile,omitempty"`
+	TLSClientCAFile string `yaml:"tlsClientCAFile,omitempty" json:"tlsClientCAFile,omitempty"`
+	// ClientsFile lists the clients allowed to call the gRPC server, the gateway and the websocket
+	// server, with their rate limits and quotas. Authentication is disabled when it is empty. The file is reloaded when it changes.
+	ClientsFile string `yaml:"clientsFile,omitempty" json:"clientsFile,omitempty"`
+
+	MarketConfig `yaml:",inline"`
+	Markets      map[string]MarketConfig `yaml:"markets,omitempty" json:"markets,omitempty"`
+
+	History         bool   `yaml:"history" json:"history"`
+	SnapshotDir     string `yaml:"snapshotDir,omitempty" json:"snapshotDir,omitempty"`
+	SnapshotArchive bool   `yaml:"snapshotArchive" json:"snapshotArchive"`
+	RecordFile      string `yaml:"recordFile,omitempty" json:"recordFile,omitempty"`
+	PaperTrading    bool   `yaml:"paperTrading" json:"paperTrading"`
+
+	// HistoryRetentionSecs is how far back the history reaches, unless HistoryMaxUpdates is hit
+	// first, in which case the oldest history is dropped earlier.
+	HistoryRetentionSecs int64 `yaml:"historyRetentionSecs" json:"historyRetentionSecs"`
+	HistoryMaxUpdates    int64 `yaml:"historyMaxUpdates" json:"historyMaxUpdates"`
+
+	// ShutdownTimeoutSecs bounds how long draining RPCs and stopping the feed may take on SIGTERM.
+	ShutdownTimeoutSecs int64 `yaml:"shutdownTimeoutSecs" json:"shutdownTimeoutSecs"`
+
+	// PrintConfig prints the effective configuration instead of starting the service.
+	PrintConfig bool `yaml:"-" json:"-"`
+}
+
+// Default returns the configuration used when nothing is overridden. The market has no default.
+func Default() *Config {
+	return &Config{
+		GRPCAddr:             ":8000",
+		MetricsAddr:          ":2112",
+		WebsocketURL:         datasource.COINBASE_WEBSOCKET_URL,
+		ShutdownTimeoutSecs:  30,
+		HistoryRetentionSecs: int64(history.DEFAULT_RETENTION / time.Second),
+		HistoryMaxUpdates:    history.DEFAULT_MAX_UPDATES,
+		MarketConfig: MarketConfig{
+			StaleBookSecs:      feed.TIMEOUT_STALE_BOOK,
+			HeartbeatTTLSecs:   datasource.HEARTBEAT_TTL_SECONDS,
+			ChannelBufferSize:  controller.CHANNEL_BUFFER_SIZE,
+			ReportIntervalSecs: controller.ORDERBOOK_REPORT_TICKER_SECS,
+			Level:              2,
+		},
+	}
+}
+
+// Effective returns the settings of the configured market, with its overrides applied.
+func (c *Config) Effective() MarketConfig {
+	return c.MarketConfig.merge(c.Markets[c.Market])
+}
+
+// ControllerSettings returns the feed controller settings for the configured market.
+func (c *Config) ControllerSettings() controller.Settings {
+	effective := c.Effective()
+	return controller.Settings{
+		StaleBookSecs:     effective.StaleBookSecs,
+		HeartbeatTTL:      time.Duration(effective.HeartbeatTTLSecs) * time.Second,
+		ChannelBufferSize: effective.ChannelBufferSize,
+		ReportInterval:    time.Duration(effective.ReportIntervalSecs) * time.Second,
+		WebsocketURL:      c.WebsocketURL,
+	}
+}
+
+// Validate returns an error listing every invalid setting.
+func (c *Config) Validate() error {
+	var problems []string
+	if !marketPattern.MatchString(c.Market) {
+		problems = append(problems, fmt.Sprintf("market '%s' must look like BASE-QUOTE", c.Market))
+	}
+	if _, _, err := net.SplitHostPort(c.GRPCAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("grpcAddr '%s' is invalid", c.GRPCAddr))
+	}
+	if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("metricsAddr '%s' is invalid", c.MetricsAddr))
+	}
+	if c.GatewayAddr != "" {
+		if _, _, err := net.SplitHostPort(c.GatewayAddr); err != nil {
+			problems = append(problems, fmt.Sprintf("gatewayAddr '%s' is invalid", c.GatewayAddr))
+		}
+	}
+	if c.StreamAddr != "" {
+		if _, _, err := net.SplitHostPort(c.StreamAddr); err != nil {
+			problems = append(problems, fmt.Sprintf("streamAddr '%s' is invalid", c.StreamAddr))
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		problems = append(problems, "tlsCertFile and tlsKeyFile must be set together")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		problems = append(problems, "tlsClientCAFile requires tlsCertFile and tlsKeyFile")
+	}
+	if !strings.HasPrefix(c.WebsocketURL, "ws://") && !strings.HasPrefix(c.WebsocketURL, "wss://") {
+		problems = append(problems, fmt.Sprintf("websocketURL '%s' must be a ws:// or wss:// URL", c.WebsocketURL))
+	}
+	if c.ShutdownTimeoutSecs <= 0 {
+		problems = append(problems, "shutdownTimeoutSecs must be positive")
+	}
+	if c.HistoryRetentionSecs <= 0 || c.HistoryMaxUpdates <= 0 {
+		problems = append(problems, "historyRetentionSecs and historyMaxUpdates must be positive")
+	}
+	problems = append(problems, c.MarketConfig.validate("")...)
+
+	markets := make([]string, 0, len(c.Markets))
+	for market := range c.Markets {
+		markets = append(markets, market)
+	}
+	sort.Strings(markets)
+	for _, market := range markets {
+		if !marketPattern.MatchString(market) {
+			problems = append(problems, fmt.Sprintf("markets: '%s' must look like BASE-QUOTE", market))
+		}
+		override := c.Markets[market]
+		if override.StaleBookSecs < 0 || override.HeartbeatTTLSecs < 0 || override.ChannelBufferSize < 0 || override.ReportIntervalSecs < 0 {
+			problems = append(problems, fmt.Sprintf("markets.%s: overrides cannot be negative", market))
+		}
+		problems = append(problems, c.MarketConfig.merge(override).validate("markets."+market+".")...)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Configuration is invalid: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Print writes the configuration as YAML.
+func (c *Config) Print(w io.Writer) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// loadFile reads a configuration file over `c`. Files ending in .json are read as JSON, anything
+// else as YAML. Unknown settings are rejected, as they are most likely typos.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		err = yaml.UnmarshalStrict(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// option is a setting that can be set from an environment variable and a flag.
+type option struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+	// boolean options are flags which may be passed without a value, such as --history
+	boolean bool
+}
+
+func stringOption(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func intOption(field func(c *Config) *int64) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func boolOption(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+var options = []option{
+	{"market", "MARKET", "Coinbase Pro product to serve, for example ETH-USD", stringOption(func(c *Config) *string { return &c.Market }), false},
+	{"grpc-addr", "GRPC_ADDR", "Address the gRPC server listens on", stringOption(func(c *Config) *string { return &c.GRPCAddr }), false},
+	{"metrics-addr", "METRICS_ADDR", "Address the metrics server listens on", stringOption(func(c *Config) *string { return &c.MetricsAddr }), false},
+	{"gateway-addr", "GATEWAY_ADDR", "Address the HTTP/JSON gateway listens on, disabled when empty", stringOption(func(c *Config) *string { return &c.GatewayAddr }), false},
+	{"stream-addr", "STREAM_ADDR", "Address the websocket server for browsers listens on, disabled when empty", stringOption(func(c *Config) *string { return &c.StreamAddr }), false},
+	{"websocket-url", "WEBSOCKET_URL", "Coinbase Pro websocket endpoint", stringOption(func(c *Config) *string { return &c.WebsocketURL }), false},
+	{"tls-cert-file", "TLS_CERT_FILE", "Certificate of the gRPC, gateway and websocket servers, enables TLS", stringOption(func(c *Config) *string { return &c.TLSCertFile }), false},
+	{"tls-key-file", "TLS_KEY_FILE", "Private key of the server certificate", stringOption(func(c *Config) *string { return &c.TLSKeyFile }), false},
+	{"tls-client-ca-file", "TLS_CLIENT_CA_FILE", "CAs client certificates must be signed by, enables mutual TLS", stringOption(func(c *Config) *string { return &c.TLSClientCAFile }), false},
+	{"clients-file", "CLIENTS_FILE", "File of the clients allowed to call the gRPC server, enables authentication", stringOption(func(c *Config) *string { return &c.ClientsFile }), false},
+	{"stale-book-secs", "STALE_BOOK_SECS", "Seconds without updates before the book is stale", intOption(func(c *Config) *int64 { return &c.StaleBookSecs }), false},
+	{"heartbeat-ttl-secs", "HEARTBEAT_TTL_SECS", "Seconds of websocket silence before reconnecting", intOption(func(c *Config) *int64 { return &c.HeartbeatTTLSecs }), false},
+	{"channel-buffer-size", "CHANNEL_BUFFER_SIZE", "Size of the internal message buffers", func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		c.ChannelBufferSize = parsed
+		return err
+	}, false},
+	{"report-interval-secs", "REPORT_INTERVAL_SECS", "Seconds between orderbook metric reports", intOption(func(c *Config) *int64 { return &c.ReportIntervalSecs }), false},
+	{"level", "LEVEL", "2 for the aggregated book, 3 for the order-by-order book", func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		c.Level = parsed
+		return err
+	}, false},
+	{"history", "HISTORY", "Record the book history for point-in-time quotes", boolOption(func(c *Config) *bool { return &c.History }), true},
+	{"history-retention-secs", "HISTORY_RETENTION_SECS", "Seconds of book history kept for point-in-time quotes", intOption(func(c *Config) *int64 { return &c.HistoryRetentionSecs }), false},
+	{"history-max-updates", "HISTORY_MAX_UPDATES", "Book updates kept in the history, the oldest are dropped first even within the retention", intOption(func(c *Config) *int64 { return &c.HistoryMaxUpdates }), false},
+	{"snapshot-dir", "SNAPSHOT_DIR", "Directory orderbook snapshots are persisted to", stringOption(func(c *Config) *string { return &c.SnapshotDir }), false},
+	{"snapshot-archive", "SNAPSHOT_ARCHIVE", "Keep every persisted snapshot", boolOption(func(c *Config) *bool { return &c.SnapshotArchive }), true},
+	{"record-file", "RECORD_FILE", "File raw websocket frames are recorded to", stringOption(func(c *Config) *string { return &c.RecordFile }), false},
+	{"paper-trading", "PAPER_TRADING", "Serve the paper trading service", boolOption(func(c *Config) *bool { return &c.PaperTrading }), true},
+	{"shutdown-timeout-secs", "SHUTDOWN_TIMEOUT_SECS", "Seconds allowed to drain RPCs and stop the feed on shutdown", intOption(func(c *Config) *int64 { return &c.ShutdownTimeoutSecs }), false},
+}
+
+// Load builds the configuration from `args` and the environment, and validates it. The file is
+// taken from the --config flag or the CONFIG_FILE variable. flag.ErrHelp is returned when help
+// was requested.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	flags := flag.NewFlagSet("real_feed", flag.ContinueOnError)
+	configFile := flags.String("config", getenv("CONFIG_FILE"), "YAML or JSON configuration file")
+	flags.BoolVar(&c.PrintConfig, "print-config", false, "Print the effective configuration and exit")
+	values := make(map[string]*string, len(options))
+	booleans := make(map[string]*bool)
+	for _, opt := range options {
+		usage := fmt.Sprintf("%s (env %s)", opt.usage, opt.env)
+		if opt.boolean {
+			booleans[opt.flag] = new(bool)
+			flags.BoolVar(booleans[opt.flag], opt.flag, false, usage)
+			continue
+		}
+		values[opt.flag] = flags.String(opt.flag, "", usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := c.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, opt := range options {
+		if value := getenv(opt.env); value != "" {
+			if err := opt.set(c, value); err != nil {
+				return nil, fmt.Errorf("Invalid value '%s' for %s: %s", value, opt.env, err.Error())
+			}
+		}
+	}
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		if value, ok := booleans[f.Name]; ok {
+			values[f.Name] = new(string)
+			*values[f.Name] = strconv.FormatBool(*value)
+		}
+		for _, opt := range options {
+			if opt.flag == f.Name && flagErr == nil {
+				if err := opt.set(c, *values[f.Name]); err != nil {
+					flagErr = fmt.Errorf("Invalid value '%s' for --%s: %s", *values[f.Name], f.Name, err.Error())
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+	return c, c.Validate()
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+func envFrom(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+market: ETH-USD
+grpcAddr: ":9000"
+staleBookSecs: 10
+markets:
+  BTC-USD:
+    staleBookSecs: 3
+    level: 3
+`)
+	env := envFrom(map[string]string{"GRPC_ADDR": ":9100", "MARKET": "BTC-USD", "HISTORY": "true"})
+	cfg, err := Load([]string{"--config", path, "--grpc-addr", ":9200"}, env)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cfg.GRPCAddr != ":9200" || cfg.Market != "BTC-USD" || !cfg.History || cfg.MetricsAddr != ":2112" {
+		t.Errorf("Unexpected configuration %+v", cfg)
+	}
+
+	// BTC-USD overrides the stale timeout and level, and inherits everything else
+	effective := cfg.Effective()
+	if effective.StaleBookSecs != 3 || effective.Level != 3 || effective.HeartbeatTTLSecs != 4 {
+		t.Errorf("Unexpected market configuration %+v", effective)
+	}
+	settings := cfg.ControllerSettings()
+	if settings.StaleBookSecs != 3 || settings.HeartbeatTTL != 4*time.Second || settings.ChannelBufferSize != 20 {
+		t.Errorf("Unexpected controller settings %+v", settings)
+	}
+
+	cfg, _ = Load([]string{"--config", path}, envFrom(nil))
+	if cfg.Effective().StaleBookSecs != 10 {
+		t.Errorf("Expected ETH-USD to use the top-level stale timeout, got %d", cfg.Effective().StaleBookSecs)
+	}
+}
+
+func TestLoadBooleanFlags(t *testing.T) {
+	cfg, err := Load([]string{"--history", "--paper-trading", "--market", "BTC-USD"}, envFrom(map[string]string{"SNAPSHOT_ARCHIVE": "true"}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !cfg.History || !cfg.PaperTrading || cfg.Market != "BTC-USD" || !cfg.SnapshotArchive {
+		t.Errorf("Expected flags without a value to enable their option, got %+v", cfg)
+	}
+	if cfg.HistoryRetentionSecs != 3600 || cfg.HistoryMaxUpdates != 500000 {
+		t.Errorf("Expected the default history limits, got %d and %d", cfg.HistoryRetentionSecs, cfg.HistoryMaxUpdates)
+	}
+	cfg, err = Load([]string{"--history=false"}, envFrom(map[string]string{"HISTORY": "true", "MARKET": "BTC-USD"}))
+	if err != nil || cfg.History {
+		t.Errorf("Expected the flag to override the environment, got %v %+v", err, cfg)
+	}
+}
+
+func TestLoadJSONAndPrint(t *testing.T) {
+	path := writeFile(t, "config.json", `{"market": "ETH-USD", "reportIntervalSecs": 7}`)
+	cfg, err := Load([]string{"--config", path, "--print-config"}, envFrom(nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !cfg.PrintConfig || cfg.ReportIntervalSecs != 7 {
+		t.Errorf("Unexpected configuration %+v", cfg)
+	}
+	var buffer bytes.Buffer
+	cfg.Print(&buffer)
+	if !strings.Contains(buffer.String(), "reportIntervalSecs: 7") || !strings.Contains(buffer.String(), "market: ETH-USD") {
+		t.Errorf("Unexpected output %s", buffer.String())
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	if _, err := Load(nil, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "market") {
+		t.Errorf("Expected the market to be required, got %v", err)
+	}
+	_, err := Load([]string{"--market", "ETH-USD", "--stale-book-secs", "0", "--websocket-url", "http://example.com", "--shutdown-timeout-secs", "0"}, envFrom(nil))
+	if err == nil || !strings.Contains(err.Error(), "staleBookSecs") || !strings.Contains(err.Error(), "websocketURL") || !strings.Contains(err.Error(), "shutdownTimeoutSecs") {
+		t.Errorf("Expected every problem to be reported, got %v", err)
+	}
+	if _, err := Load([]string{"--market", "ETH-USD", "--history-max-updates", "0"}, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "historyMaxUpdates") {
+		t.Errorf("Expected the history to need a maximum number of updates, got %v", err)
+	}
+	if _, err := Load(nil, envFrom(map[string]string{"MARKET": "ETH-USD", "LEVEL": "two"})); err == nil {
+		t.Error("Expected an invalid environment variable to be rejected")
+	}
+	if _, err := Load([]string{"--market", "ETH-USD", "--tls-cert-file", "server.pem"}, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "tlsKeyFile") {
+		t.Errorf("Expected a certificate without a key to be rejected, got %v", err)
+	}
+
+	path := writeFile(t, "config.yaml", "market: ETH-USD\nstaleBookSec: 3\n")
+	if _, err := Load([]string{"--config", path}, envFrom(nil)); err == nil {
+		t.Error("Expected unknown settings to be rejected")
+	}
+	path = writeFile(t, "config.yaml", "market: ETH-USD\nmarkets:\n  BTC-USD:\n    level: 4\n")
+	if _, err := Load([]string{"--config", path}, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "markets.BTC-USD.level") {
+		t.Errorf("Expected invalid overrides to be rejected, got %v", err)
+	}
+}
@@ -4,8 +4,12 @@ package controller
 import (
 	"context"
 	"errors"
+	"pirosb3/real_feed/clock"
 	"pirosb3/real_feed/datasource"
 	"pirosb3/real_feed/feed"
+	"pirosb3/real_feed/history"
+	"pirosb3/real_feed/persistence"
+	"strconv"
 	"sync"
 	"time"
 
@@ -19,6 +23,17 @@ import (
 const ORDERBOOK_REPORT_TICKER_SECS = 2
 const CHANNEL_BUFFER_SIZE = 20
 const TS_LAYOUT = "2006-01-02T15:04:05.000000Z"
+const TRADE_TAPE_MAX_TRADES = 10000
+const MAX_CANDLES = 1000
+const SNAPSHOT_REQUEST_INTERVAL_SECS = 5
+const PERSISTENCE_INTERVAL_SECS = 10
+const FIRM_QUOTE_TTL_SECS = 10
+
+// TRADE_TAPE_WINDOWS are the rolling windows reported for volume and VWAP.
+var TRADE_TAPE_WINDOWS = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// CANDLE_INTERVALS are the intervals OHLCV bars are built for.
+var CANDLE_INTERVALS = []time.Duration{time.Second, time.Minute, 5 * time.Minute, time.Hour}
 
 func DateStringToUnixEpoch(timestamp string) (int64, error) {
 	t, err := time.Parse(TS_LAYOUT, timestamp)
@@ -39,37 +54,260 @@ var (
 		Help:      "Orderbook Depth",
 		Namespace: "feed",
 	}, []string{"uuid", "market", "side"})
+	pricingProm = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "pricing",
+		Help:      "Orderbook visualization for 50 ETH",
+		Namespace: "feed",
+	}, []string{"uuid", "market"})
+	depthNotionalGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "depthNotional",
+		Help:      "Cumulative quote notional resting within a distance of mid, in basis points",
+		Namespace: "feed",
+	}, []string{"uuid", "market", "side", "bps"})
+	imbalanceGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "imbalance",
+		Help:      "Bid/ask notional imbalance within a distance of mid, from -1 (asks only) to 1 (bids only)",
+		Namespace: "feed",
+	}, []string{"uuid", "market", "bps"})
+	slippageGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "slippageBps",
+		Help:      "Slippage from mid, in basis points, of a market order of a given base size",
+		Namespace: "feed",
+	}, []string{"uuid", "market", "side", "size"})
+	tradesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "trades",
+		Help:      "Counts trades received from the matches channel",
+		Namespace: "feed",
+	}, []string{"uuid", "market", "side"})
+	lastTradePriceGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "lastTradePrice",
+		Help:      "Price of the most recent trade",
+		Namespace: "feed",
+	}, []string{"uuid", "market"})
+	tradeVolumeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "tradeVolume",
+		Help:      "Rolling traded volume in base currency, by aggressor side",
+		Namespace: "feed",
+	}, []string{"uuid", "market", "window", "side"})
+	snapshotRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "snapshotRequests",
+		Help:      "Counts fresh snapshots requested because the orderbook failed its integrity checks",
+		Namespace: "feed",
+	}, []string{"uuid", "market"})
+	vwapGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "vwap",
+		Help:      "Rolling volume weighted average price",
+		Namespace: "feed",
+	}, []string{"uuid", "market", "window"})
 )
 
 type FeedController struct {
 	orderbook *feed.OrderbookFeed
+	level3    *feed.OrderbookL3Feed
+	trades    *feed.TradeTape
+	candles   *feed.CandleBuilder
+	events    *eventBroadcaster
 	websocket *datasource.CoinbaseProWebsocket
 	ctx       context.Context
 	startLock sync.Mutex
 	stopFn    context.CancelFunc
 	started   bool
-	outChan   chan (map[string]interface{})
+	wg        sync.WaitGroup
+	outChan   chan (feed.WebsocketMessage)
 	inChan    chan (interface{})
 	product   string
 	uuid      string
+	settings  Settings
+
+	l3SnapshotChan chan (*feed.L3SnapshotMessage)
+	fetchingL3     bool
+
+	lastSnapshotRequest time.Time
+
+	history        *history.Store
+	recorder       datasource.FrameRecorder
+	clock          clock.Clock
+	store          persistence.SnapshotStore
+	lastPersisted  uint64
+	persistenceMux sync.Mutex
+}
+
+// Settings tune a feed controller, its orderbook and its websocket.
+type Settings struct {
+	StaleBookSecs     int64
+	HeartbeatTTL      time.Duration
+	ChannelBufferSize int
+	ReportInterval    time.Duration
+	WebsocketURL      string
+}
+
+// DefaultSettings returns the settings used by NewFeedController.
+func DefaultSettings() Settings {
+	return Settings{
+		StaleBookSecs:     feed.TIMEOUT_STALE_BOOK,
+		HeartbeatTTL:      datasource.HEARTBEAT_TTL_SECONDS * time.Second,
+		ChannelBufferSize: CHANNEL_BUFFER_SIZE,
+		ReportInterval:    ORDERBOOK_REPORT_TICKER_SECS * time.Second,
+		WebsocketURL:      datasource.COINBASE_WEBSOCKET_URL,
+	}
 }
 
 func NewFeedController(
 	ctx context.Context,
 	product string,
+) *FeedController {
+	return NewFeedControllerWithSettings(ctx, product, DefaultSettings())
+}
+
+// NewFeedControllerWithSettings creates a feed controller tuned with `settings`.
+func NewFeedControllerWithSettings(
+	ctx context.Context,
+	product string,
+	settings Settings,
 ) *FeedController {
 	aUUID, _ := uuid.NewUUID()
 	orderbook := feed.NewOrderbookFeed(product)
+	orderbook.SetStaleTimeout(settings.StaleBookSecs)
 	newContext, stopFn := context.WithCancel(ctx)
 	return &FeedController{
+		settings:  settings,
 		uuid:      aUUID.String(),
 		orderbook: orderbook,
+		trades:    feed.NewTradeTape(product, TRADE_TAPE_WINDOWS, TRADE_TAPE_MAX_TRADES),
+		candles:   feed.NewCandleBuilder(product, CANDLE_INTERVALS, MAX_CANDLES),
+		events:    newEventBroadcaster(settings.ChannelBufferSize),
 		stopFn:    stopFn,
 		ctx:       newContext,
 		started:   false,
-		outChan:   make(chan (map[string]interface{}), CHANNEL_BUFFER_SIZE),
-		inChan:    make(chan (interface{}), CHANNEL_BUFFER_SIZE),
+		outChan:   make(chan (feed.WebsocketMessage), settings.ChannelBufferSize),
+		inChan:    make(chan (interface{}), settings.ChannelBufferSize),
 		product:   product,
+		clock:     clock.NewReal(),
+
+		l3SnapshotChan: make(chan (*feed.L3SnapshotMessage)),
+	}
+}
+
+// EnableLevelThree switches the controller to the order-by-order "full" channel. The level 2 book
+// used for quoting is then derived from the level 3 book. It must be called before `.Start()`.
+func (fc *FeedController) EnableLevelThree() error {
+	fc.startLock.Lock()
+	defer fc.startLock.Unlock()
+
+	if fc.started {
+		return errors.New("Level 3 must be enabled before the Feed Controller is started")
+	}
+	fc.level3 = feed.NewOrderbookL3Feed(fc.product)
+	return nil
+}
+
+// EnablePersistence periodically saves the orderbook to `store`, and restores the latest saved
+// snapshot as a provisional book on start. It must be called before `.Start()`.
+func (fc *FeedController) EnablePersistence(store persistence.SnapshotStore) error {
+	fc.startLock.Lock()
+	defer fc.startLock.Unlock()
+
+	if fc.started {
+		return errors.New("Persistence must be enabled before the Feed Controller is started")
+	}
+	fc.store = store
+	return nil
+}
+
+// EnableHistory records every change to the orderbook in `store`, which allows quoting the book
+// as it was at a past instant. Books reconstructed from it use the stale timeout of the controller.
+// It must be called before `.Start()`.
+func (fc *FeedController) EnableHistory(store *history.Store) error {
+	fc.startLock.Lock()
+	defer fc.startLock.Unlock()
+
+	if fc.started {
+		return errors.New("History must be enabled before the Feed Controller is started")
+	}
+	store.SetStaleTimeout(fc.settings.StaleBookSecs)
+	fc.history = store
+	return nil
+}
+
+// EnableRecording passes every raw websocket frame to `recorder`, which allows replaying the feed
+// offline. It must be called before `.Start()`.
+func (fc *FeedController) EnableRecording(recorder datasource.FrameRecorder) error {
+	fc.startLock.Lock()
+	defer fc.startLock.Unlock()
+
+	if fc.started {
+		return errors.New("Recording must be enabled before the Feed Controller is started")
+	}
+	fc.recorder = recorder
+	return nil
+}
+
+// SetClock replaces the clock used by the controller, its orderbook and its websocket. It must be
+// called before `.Start()`.
+func (fc *FeedController) SetClock(clock clock.Clock) error {
+	fc.startLock.Lock()
+	defer fc.startLock.Unlock()
+
+	if fc.started {
+		return errors.New("Clock must be set before the Feed Controller is started")
+	}
+	fc.clock = clock
+	fc.orderbook.SetClock(clock)
+	return nil
+}
+
+func (fc *FeedController) restoreOrderbook() {
+	snapshot, err := fc.store.Load(fc.product)
+	if err != nil {
+		log.WithField("err", err.Error()).Errorln("Unable to load persisted orderbook")
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+	if fc.orderbook.RestoreSnapshot(snapshot) {
+		fc.lastPersisted = snapshot.Version
+		log.WithField("version", snapshot.Version).WithField("timestamp", snapshot.Timestamp).Infoln("Restored provisional orderbook")
+	}
+}
+
+// Flush saves the orderbook if it changed since it was last persisted. Provisional or invalid
+// books are never persisted.
+func (fc *FeedController) Flush() error {
+	if fc.store == nil {
+		return nil
+	}
+	fc.persistenceMux.Lock()
+	defer fc.persistenceMux.Unlock()
+
+	if fc.orderbook.IsProvisional() || !fc.orderbook.IsValid() || fc.orderbook.GetVersion() == fc.lastPersisted {
+		return nil
+	}
+	snapshot, err := fc.snapshot()
+	if err != nil {
+		return err
+	}
+	if err := fc.store.Save(snapshot); err != nil {
+		return err
+	}
+	fc.lastPersisted = snapshot.Version
+	return nil
+}
+
+func (fc *FeedController) runPersistence() {
+	defer fc.wg.Done()
+	timer := fc.clock.NewTicker(PERSISTENCE_INTERVAL_SECS * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-fc.ctx.Done():
+			log.Warning("Orderbook persistence shutdown")
+			return
+		case <-timer.Chan():
+			if err := fc.Flush(); err != nil {
+				log.WithField("err", err.Error()).Errorln("Unable to persist orderbook")
+			}
+		}
 	}
 }
 
@@ -82,87 +320,270 @@ func (fc *FeedController) Start() error {
 
 	fc.started = true
 	fc.websocket = datasource.NewCoinbaseProWebsocket(fc.ctx, fc.product, fc.outChan, fc.inChan)
+	if fc.level3 != nil {
+		fc.websocket.SetChannels("full", "heartbeat")
+	}
+	if fc.recorder != nil {
+		fc.websocket.SetRecorder(fc.recorder)
+	}
+	fc.websocket.SetClock(fc.clock)
+	fc.websocket.SetURL(fc.settings.WebsocketURL)
+	fc.websocket.SetHeartbeatTTL(fc.settings.HeartbeatTTL)
 	fc.websocket.Start()
 
+	if fc.store != nil {
+		fc.restoreOrderbook()
+		fc.wg.Add(1)
+		go fc.runPersistence()
+	}
+	fc.wg.Add(2)
 	go fc.runOrderbookReporter()
 	go fc.runLoop()
 	return nil
 }
 
 func (fc *FeedController) runOrderbookReporter() {
-	timer := time.NewTicker(ORDERBOOK_REPORT_TICKER_SECS * time.Second)
+	defer fc.wg.Done()
+	timer := fc.clock.NewTicker(fc.settings.ReportInterval)
+	defer timer.Stop()
 	for {
 		select {
 		case <-fc.ctx.Done():
 			log.Warning("Orderbook reporter shutdown")
 			return
-		case <-timer.C:
+		case <-timer.Chan():
 			fc.orderbook.CleanUpOrderbook()
 
 			bids, asks := fc.orderbook.GetBookCount()
 			orderbookDepthGauge.WithLabelValues(fc.uuid, fc.product, "bids").Set(float64(bids))
 			orderbookDepthGauge.WithLabelValues(fc.uuid, fc.product, "asks").Set(float64(asks))
+			fc.reportTrades()
+			fc.reportLiquidity()
 		}
 	}
 }
 
+func (fc *FeedController) reportTrades() {
+	if lastPrice, ok := fc.trades.LastPrice(); ok {
+		lastTradePriceGauge.WithLabelValues(fc.uuid, fc.product).Set(lastPrice)
+	}
+	for _, stats := range fc.trades.AllStats(fc.clock.Now()) {
+		window := stats.Window.String()
+		tradeVolumeGauge.WithLabelValues(fc.uuid, fc.product, window, "buy").Set(stats.BuyVolume)
+		tradeVolumeGauge.WithLabelValues(fc.uuid, fc.product, window, "sell").Set(stats.SellVolume)
+		vwapGauge.WithLabelValues(fc.uuid, fc.product, window).Set(stats.VWAP)
+	}
+}
+
+func (fc *FeedController) reportLiquidity() {
+	profile, err := fc.orderbook.LiquidityProfile(feed.DEFAULT_DEPTH_BANDS_BPS, feed.DEFAULT_SLIPPAGE_SIZES)
+	if err != nil {
+		return
+	}
+	for _, band := range profile.Bands {
+		bps := strconv.FormatFloat(band.Bps, 'f', -1, 64)
+		depthNotionalGauge.WithLabelValues(fc.uuid, fc.product, "bids", bps).Set(band.BidNotional)
+		depthNotionalGauge.WithLabelValues(fc.uuid, fc.product, "asks", bps).Set(band.AskNotional)
+		imbalanceGauge.WithLabelValues(fc.uuid, fc.product, bps).Set(band.Imbalance)
+	}
+	for _, point := range profile.Slippage {
+		size := strconv.FormatFloat(point.Size, 'f', -1, 64)
+		if point.BuyFilled {
+			slippageGauge.WithLabelValues(fc.uuid, fc.product, "buy", size).Set(point.BuySlippageBps)
+		}
+		if point.SellFilled {
+			slippageGauge.WithLabelValues(fc.uuid, fc.product, "sell", size).Set(point.SellSlippageBps)
+		}
+		if point.Size == 50 && point.BuyFilled {
+			pricingProm.WithLabelValues(fc.uuid, fc.product).Set(point.BuyPrice)
+		}
+	}
+}
+
+func (fc *FeedController) publish(event *FeedEvent) {
+	event.Product = fc.product
+	if dropped := fc.events.publish(event); dropped > 0 {
+		droppedEventsCounter.WithLabelValues(fc.uuid, fc.product).Add(float64(dropped))
+	}
+}
+
+// fetchLevelThreeSnapshot downloads a level 3 snapshot in the background. The result is handed
+// back to the event loop so that the books are only ever mutated from a single goroutine.
+func (fc *FeedController) fetchLevelThreeSnapshot() {
+	if fc.fetchingL3 {
+		return
+	}
+	fc.fetchingL3 = true
+	fc.wg.Add(1)
+	go func() {
+		defer fc.wg.Done()
+		snapshot, err := datasource.FetchLevelThreeSnapshot(fc.ctx, fc.product)
+		if err != nil {
+			log.WithField("err", err.Error()).Errorln("Unable to fetch level 3 snapshot")
+		}
+		select {
+		case fc.l3SnapshotChan <- snapshot:
+		case <-fc.ctx.Done():
+		}
+	}()
+}
+
+func (fc *FeedController) setLevelThreeSnapshot(snapshot *feed.L3SnapshotMessage) {
+	fc.fetchingL3 = false
+	if snapshot == nil {
+		return
+	}
+	now := fc.clock.Now()
+	if err := fc.level3.SetSnapshot(snapshot, now); err != nil {
+		log.WithField("err", err.Error()).Warningln("Level 3 snapshot could not be replayed")
+		return
+	}
+	bids, asks := fc.level3.LevelTwo()
+	fc.setSnapshot(now, bids, asks)
+	log.WithField("numBids", len(bids)).WithField("numAsks", len(asks)).WithField("sequence", snapshot.Sequence).Infoln("Set new level 3 snapshot")
+}
+
+func (fc *FeedController) applyLevelThree(msg feed.WebsocketMessage, timestamp time.Time) {
+	bids, asks, err := fc.level3.ApplyMessage(msg)
+	if err != nil {
+		if err != feed.ErrNotSynced {
+			log.WithField("err", err.Error()).Warningln("Level 3 book must be re-synced")
+		}
+		fc.fetchLevelThreeSnapshot()
+		return
+	}
+	if len(bids) == 0 && len(asks) == 0 {
+		return
+	}
+	fc.writeUpdate(timestamp, bids, asks)
+}
+
+// setSnapshot replaces the orderbook and starts a new keyframe in the history store.
+func (fc *FeedController) setSnapshot(receivedAt time.Time, bids []*feed.Update, asks []*feed.Update) {
+	if !fc.orderbook.SetSnapshot(receivedAt.Unix(), bids, asks) {
+		return
+	}
+	if fc.history != nil {
+		fc.recordKeyframe()
+	}
+	fc.publish(&FeedEvent{Type: EVENT_BOOK_UPDATE, Epoch: receivedAt.Unix()})
+	fc.recordMid(receivedAt)
+	fc.healOrderbook()
+}
+
+// writeUpdate applies an incremental update to the orderbook and records it in the history store.
+func (fc *FeedController) writeUpdate(updateTime time.Time, bids []*feed.Update, asks []*feed.Update) {
+	if !fc.orderbook.WriteUpdate(updateTime.Unix(), bids, asks) {
+		return
+	}
+	if fc.history != nil {
+		// History is indexed by the time changes were received, like keyframes, as snapshots do
+		// not carry an exchange time
+		receivedAt := fc.clock.Now()
+		if fc.history.NeedsKeyframe(fc.product, receivedAt) {
+			fc.recordKeyframe()
+		} else {
+			fc.history.RecordUpdate(fc.product, receivedAt, updateTime.Unix(), bids, asks)
+		}
+	}
+	fc.publish(&FeedEvent{Type: EVENT_BOOK_UPDATE, Epoch: updateTime.Unix()})
+	fc.recordMid(updateTime)
+	fc.healOrderbook()
+}
+
+func (fc *FeedController) recordKeyframe() {
+	snapshot, err := fc.snapshot()
+	if err != nil {
+		return
+	}
+	fc.history.RecordSnapshot(snapshot)
+}
+
+// snapshot returns a snapshot of the orderbook, carrying the exchange sequence when the book is
+// built from the level 3 feed.
+func (fc *FeedController) snapshot() (*feed.BookSnapshot, error) {
+	snapshot, err := fc.orderbook.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if fc.level3 != nil {
+		snapshot.Sequence = fc.level3.GetSequence()
+	}
+	return snapshot, nil
+}
+
+// healOrderbook requests a fresh snapshot if the last update left the orderbook invalid. Requests
+// are spaced out by SNAPSHOT_REQUEST_INTERVAL_SECS to avoid hammering the exchange.
+func (fc *FeedController) healOrderbook() {
+	if fc.orderbook.IsValid() {
+		return
+	}
+	if fc.clock.Now().Sub(fc.lastSnapshotRequest) < SNAPSHOT_REQUEST_INTERVAL_SECS*time.Second {
+		return
+	}
+	fc.lastSnapshotRequest = fc.clock.Now()
+	snapshotRequestsCounter.WithLabelValues(fc.uuid, fc.product).Inc()
+	log.WithField("err", fc.orderbook.IntegrityError().Error()).Warningln("Requesting a fresh snapshot")
+	if fc.level3 != nil {
+		fc.level3.Resync()
+		fc.fetchLevelThreeSnapshot()
+		return
+	}
+	fc.websocket.RequestSnapshot()
+}
+
+func (fc *FeedController) publishCandles(closedCandles []feed.Candle) {
+	for idx := range closedCandles {
+		candle := closedCandles[idx]
+		fc.publish(&FeedEvent{Type: EVENT_CANDLE, Epoch: candle.Start.Unix(), Candle: &candle})
+	}
+}
+
+func (fc *FeedController) recordMid(at time.Time) {
+	if mid, ok := fc.orderbook.MidPrice(); ok && fc.orderbook.IsValid() {
+		fc.publishCandles(fc.candles.AddMid(at, mid))
+	}
+}
+
 func (fc *FeedController) runLoop() {
+	defer fc.wg.Done()
 	for {
 		select {
 		case <-fc.ctx.Done():
 			log.Warning("Feed controller event loop shut down")
 			return
-		case wsType := <-fc.outChan:
-			switch wsType["type"].(string) {
-			case "snapshot":
-				bidsInterface := wsType["bids"].([]interface{})
-				bids := make([]*feed.Update, len(bidsInterface))
-				for idx, bidsEl := range bidsInterface {
-					bids[idx] = &feed.Update{
-						Price: bidsEl.([]interface{})[0].(string),
-						Size:  bidsEl.([]interface{})[1].(string),
-					}
-				}
-
-				asksInterface := wsType["asks"].([]interface{})
-				asks := make([]*feed.Update, len(asksInterface))
-				for idx, asksEl := range asksInterface {
-					asks[idx] = &feed.Update{
-						Price: asksEl.([]interface{})[0].(string),
-						Size:  asksEl.([]interface{})[1].(string),
-					}
-				}
-				fc.orderbook.SetSnapshot(time.Now().Unix(), bids, asks)
+		case snapshot := <-fc.l3SnapshotChan:
+			fc.setLevelThreeSnapshot(snapshot)
+		case msg := <-fc.outChan:
+			switch typedMsg := msg.(type) {
+			case *feed.L2SnapshotMessage:
+				bids, asks := typedMsg.Updates()
+				fc.setSnapshot(fc.clock.Now(), bids, asks)
 				log.WithField("numBids", len(bids)).WithField("numAsks", len(asks)).Infoln("Set new snapshot")
-			case "l2update":
-				timestamp, err := DateStringToUnixEpoch(wsType["time"].(string))
-				if err != nil {
-					log.WithField("timestamp", wsType["time"].(string)).Errorln("Incorrect date format found.")
-					continue
+			case *feed.L2UpdateMessage:
+				bids, asks := typedMsg.Updates()
+				fc.writeUpdate(typedMsg.Time, bids, asks)
+			case *feed.FullChannelMessage:
+				if fc.level3 != nil {
+					fc.applyLevelThree(typedMsg, typedMsg.Time)
 				}
-
-				var bids []*feed.Update
-				var asks []*feed.Update
-				changes := wsType["changes"].([]interface{})
-				for _, change := range changes {
-					changeEl := change.([]interface{})
-					update := &feed.Update{
-						Price: changeEl[1].(string),
-						Size:  changeEl[2].(string),
-					}
-					switch changeEl[0] {
-					case "buy":
-						bids = append(bids, update)
-					case "sell":
-						asks = append(asks, update)
-					}
+			case *feed.MatchMessage:
+				if fc.level3 != nil {
+					fc.applyLevelThree(typedMsg, typedMsg.Time)
+				}
+				trade := typedMsg.Trade()
+				if fc.trades.AddTrade(trade) {
+					tradesCounter.WithLabelValues(fc.uuid, fc.product, trade.Side).Inc()
+					fc.publish(&FeedEvent{Type: EVENT_TRADE, Epoch: trade.Time.Unix(), Trade: trade})
+					fc.publishCandles(fc.candles.AddTrade(trade))
 				}
-				fc.orderbook.WriteUpdate(timestamp, bids, asks)
-			case "heartbeat":
+			case *feed.HeartbeatMessage:
 				heartbeatTicker.WithLabelValues(fc.uuid, fc.product).Inc()
-			case "subscriptions":
+			case *feed.SubscriptionsMessage:
+			case *feed.ErrorMessage:
+				log.WithField("message", typedMsg.Message).WithField("reason", typedMsg.Reason).Errorln("Received an error from the websocket")
 			default:
-				log.WithField("messageType", wsType["type"].(string)).Warningln("Received an unexpected message")
+				log.WithField("messageType", msg.GetType()).Warningln("Received an unexpected message")
 			}
 		}
 	}
@@ -174,6 +595,118 @@ func (fc *FeedController) Stop() {
 	}
 }
 
+// Shutdown stops the controller, ends every subscription and waits for the goroutines of the
+// controller and its websocket to exit, before flushing the orderbook to the persistence store.
+// It stops waiting when `ctx` is done, but the orderbook is flushed regardless.
+func (fc *FeedController) Shutdown(ctx context.Context) error {
+	fc.startLock.Lock()
+	started := fc.started
+	fc.startLock.Unlock()
+
+	fc.Stop()
+	fc.events.close()
+
+	var err error
+	if started {
+		done := make(chan (struct{}))
+		go func() {
+			fc.wg.Wait()
+			fc.websocket.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if flushErr := fc.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// CloseSubscriptions ends every subscription, and closes new ones immediately, while the feed
+// keeps running. Streams served from subscriptions end, so that RPCs can drain against a live book
+// before the controller is shut down.
+func (fc *FeedController) CloseSubscriptions() {
+	fc.events.close()
+}
+
+// Orderbook returns the live orderbook maintained by the controller.
+func (fc *FeedController) Orderbook() *feed.OrderbookFeed {
+	return fc.orderbook
+}
+
+// Subscribe returns a channel receiving every event processed by the controller, and a function
+// to cancel the subscription. Events are dropped if the channel is not drained quickly enough.
+func (fc *FeedController) Subscribe() (<-chan (*FeedEvent), func()) {
+	return fc.events.subscribe()
+}
+
+// RecentTrades returns up to `limit` of the most recent trades, newest first.
+func (fc *FeedController) RecentTrades(limit int) []*feed.Trade {
+	return fc.trades.RecentTrades(limit)
+}
+
+// TradeStats returns the last trade price and rolling statistics for every configured window.
+func (fc *FeedController) TradeStats() (float64, []feed.TradeStats) {
+	lastPrice, _ := fc.trades.LastPrice()
+	return lastPrice, fc.trades.AllStats(fc.clock.Now())
+}
+
+// Depth returns the best `levels` levels of each side of the book.
+func (fc *FeedController) Depth(levels int) (*feed.Depth, error) {
+	return fc.orderbook.Depth(levels)
+}
+
+// LiquidityProfile returns depth within each of `bandsBps` of mid and the slippage of each of `sizes`.
+func (fc *FeedController) LiquidityProfile(bandsBps []float64, sizes []float64) (*feed.LiquidityProfile, error) {
+	return fc.orderbook.LiquidityProfile(bandsBps, sizes)
+}
+
+// RequestQuote returns a firm quote, reserving the liquidity it consumes for `ttl`. The default
+// TTL is used when `ttl` is zero.
+func (fc *FeedController) RequestQuote(operation string, amount float64, ttl time.Duration) (*feed.FirmQuote, error) {
+	if ttl == 0 {
+		ttl = FIRM_QUOTE_TTL_SECS * time.Second
+	}
+	return fc.orderbook.RequestQuote(operation, amount, ttl)
+}
+
+// AcceptQuote accepts a firm quote if the live book still supports its price within `toleranceBps`.
+func (fc *FeedController) AcceptQuote(id string, toleranceBps float64) (*feed.FirmQuote, error) {
+	return fc.orderbook.AcceptQuote(id, toleranceBps)
+}
+
+// ImpactCurve returns the average price and impact of market orders of each of `amounts` on `side`.
+func (fc *FeedController) ImpactCurve(side string, amounts []float64) (*feed.ImpactCurve, error) {
+	return fc.orderbook.ImpactCurve(side, amounts)
+}
+
+// Candles returns up to `limit` OHLCV bars for an interval, oldest first, including the bar
+// still being built.
+func (fc *FeedController) Candles(interval time.Duration, limit int) ([]feed.Candle, error) {
+	return fc.candles.Candles(interval, limit, true)
+}
+
+// QueuePosition estimates the queue position of a resting order. Level 3 must be enabled.
+func (fc *FeedController) QueuePosition(orderID string) (*feed.QueuePosition, error) {
+	if fc.level3 == nil {
+		return nil, errors.New("Level 3 is not enabled on this Feed Controller")
+	}
+	return fc.level3.QueuePosition(orderID, fc.clock.Now())
+}
+
+// QuoteAt runs one of the quote operations against the orderbook as it was at `at`. History must
+// be enabled.
+func (fc *FeedController) QuoteAt(at time.Time, operation string, amount float64) (float64, int64, error) {
+	if fc.history == nil {
+		return -1, -1, errors.New("History is not enabled on this Feed Controller")
+	}
+	return fc.history.QuoteAt(fc.product, at, operation, amount)
+}
+
 func (fc *FeedController) BuyQuote(amount float64) (float64, int64, error) {
 	return fc.orderbook.BuyQuote(amount)
 }
@@ -185,4 +718,16 @@ func (fc *FeedController) BuyBase(amount float64) (float64, int64, error) {
 }
 func (fc *FeedController) SellBase(amount float64) (float64, int64, error) {
 	return fc.orderbook.SellBase(amount)
+}
+func (fc *FeedController) BuyBaseExactOut(amount float64, opts feed.QuoteOptions) (float64, int64, error) {
+	return fc.orderbook.BuyBaseExactOut(amount, opts)
+}
+func (fc *FeedController) BuyQuoteExactOut(amount float64, opts feed.QuoteOptions) (float64, int64, error) {
+	return fc.orderbook.BuyQuoteExactOut(amount, opts)
+}
+func (fc *FeedController) SellBaseExactOut(amount float64, opts feed.QuoteOptions) (float64, int64, error) {
+	return fc.orderbook.SellBaseExactOut(amount, opts)
+}
+func (fc *FeedController) SellQuoteExactOut(amount float64, opts feed.QuoteOptions) (float64, int64, error) {
+	return fc.orderbook.SellQuoteExactOut(amount, opts)
 }
\ No newline at end of file
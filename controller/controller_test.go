@@ -1,6 +1,9 @@
 package controller
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestDateParsingWorks(t *testing.T) {
 	dateString := "2020-10-11T20:50:02.941691Z"
@@ -10,3 +13,63 @@ func TestDateParsingWorks(t *testing.T) {
 		t.Errorf("Expected %d but got %d", expectedResult, result)
 	}
 }
+
+func TestBroadcasterDropsForSlowSubscribers(t *testing.T) {
+	broadcaster := newEventBroadcaster(1)
+	fast, cancelFast := broadcaster.subscribe()
+	_, cancelSlow := broadcaster.subscribe()
+	defer cancelSlow()
+
+	if dropped := broadcaster.publish(&FeedEvent{Type: EVENT_TRADE}); dropped != 0 {
+		t.Errorf("Expected no drops, got %d", dropped)
+	}
+	<-fast
+	if dropped := broadcaster.publish(&FeedEvent{Type: EVENT_TRADE}); dropped != 1 {
+		t.Errorf("Expected the slow subscriber to drop, got %d", dropped)
+	}
+
+	cancelFast()
+	cancelFast()
+	if _, ok := <-fast; !ok {
+		t.Error("Expected the buffered event to still be readable")
+	}
+	if _, ok := <-fast; ok {
+		t.Error("Expected the channel to be closed after cancel")
+	}
+}
+
+func TestShutdownEndsSubscriptions(t *testing.T) {
+	fc := NewFeedController(context.Background(), "ETH-USD")
+	events, cancel := fc.Subscribe()
+	defer cancel()
+
+	if err := fc.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if _, ok := <-events; ok {
+		t.Error("Expected the subscription to be closed by the shutdown")
+	}
+	late, _ := fc.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("Expected subscriptions after the shutdown to be closed")
+	}
+}
+
+func TestCloseSubscriptionsKeepsTheFeedRunning(t *testing.T) {
+	ctx, cancelFeed := context.WithCancel(context.Background())
+	defer cancelFeed()
+	fc := NewFeedController(ctx, "ETH-USD")
+	events, cancel := fc.Subscribe()
+	defer cancel()
+
+	fc.CloseSubscriptions()
+	if _, ok := <-events; ok {
+		t.Error("Expected the subscription to be closed")
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected the feed to keep running")
+	}
+	if err := fc.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected a clean shutdown after closing subscriptions, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"pirosb3/real_feed/feed"
+	"pirosb3/real_feed/history"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ERROR_MODE_METADATA is the request metadata key clients set to STATUS_ERROR_MODE to receive
+	// failures as gRPC status errors. Other clients keep reading them from the `error` field of
+	// the response.
+	ERROR_MODE_METADATA = "x-error-mode"
+	STATUS_ERROR_MODE   = "status"
+	ERROR_DOMAIN        = "real_feed"
+)
+
+// Reasons set in the ErrorInfo detail of status errors
+const (
+	REASON_UNKNOWN_PRODUCT        = "UNKNOWN_PRODUCT"
+	REASON_NO_SNAPSHOT            = "NO_SNAPSHOT"
+	REASON_STALE_BOOK             = "STALE_BOOK"
+	REASON_INTEGRITY              = "INTEGRITY_CHECK_FAILED"
+	REASON_INSUFFICIENT_LIQUIDITY = "INSUFFICIENT_LIQUIDITY"
+	REASON_INVALID_AMOUNT         = "INVALID_AMOUNT"
+	REASON_QUOTE_NOT_FOUND        = "QUOTE_NOT_FOUND"
+	REASON_QUOTE_EXPIRED          = "QUOTE_EXPIRED"
+	REASON_QUOTE_MOVED            = "QUOTE_MOVED"
+	REASON_LIMIT_PRICE_REACHED    = "LIMIT_PRICE_REACHED"
+	REASON_MAX_IN_EXCEEDED        = "MAX_IN_EXCEEDED"
+	REASON_NO_HISTORY             = "NO_HISTORY"
+)
+
+// wantsStatusErrors returns true if the client asked for failures as gRPC status errors.
+func wantsStatusErrors(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, mode := range md.Get(ERROR_MODE_METADATA) {
+		if mode == STATUS_ERROR_MODE {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcError returns the status error of `err` for clients that asked for status errors, and nil
+// for the others, which read the failure from the `error` field of the response instead.
+func rpcError(ctx context.Context, err error) error {
+	if !wantsStatusErrors(ctx) {
+		return nil
+	}
+	return toStatus(err).Err()
+}
+
+// toStatus maps an error of the feed to a gRPC status. The status carries an ErrorInfo detail
+// with the reason of the failure, followed by further details where the error has them.
+func toStatus(err error) *status.Status {
+	code, reason := codes.Unknown, ""
+	var infoMetadata map[string]string
+	var details []proto.Message
+
+	var liquidityErr *feed.LiquidityError
+	var productErr *feed.ProductError
+	var integrityErr *feed.IntegrityError
+	switch {
+	case errors.As(err, &productErr):
+		code, reason = codes.NotFound, REASON_UNKNOWN_PRODUCT
+		details = append(details, &errdetails.ResourceInfo{
+			ResourceType: "product",
+			ResourceName: productErr.Requested,
+			Description:  "This service serves " + productErr.Served,
+		})
+	case errors.Is(err, feed.ErrUnknownProduct):
+		code, reason = codes.NotFound, REASON_UNKNOWN_PRODUCT
+	case errors.Is(err, feed.ErrNoSnapshot):
+		code, reason = codes.Unavailable, REASON_NO_SNAPSHOT
+	case errors.Is(err, feed.ErrStale):
+		code, reason = codes.Unavailable, REASON_STALE_BOOK
+	case errors.As(err, &integrityErr):
+		code, reason = codes.Unavailable, REASON_INTEGRITY
+	case errors.As(err, &liquidityErr):
+		code, reason = codes.FailedPrecondition, REASON_INSUFFICIENT_LIQUIDITY
+		infoMetadata = map[string]string{
+			"requested": strconv.FormatFloat(liquidityErr.Requested, 'f', -1, 64),
+			"available": strconv.FormatFloat(liquidityErr.Available, 'f', -1, 64),
+		}
+	case errors.Is(err, feed.ErrInsufficientLiquidity):
+		code, reason = codes.FailedPrecondition, REASON_INSUFFICIENT_LIQUIDITY
+	case errors.Is(err, feed.ErrInvalidAmount):
+		code, reason = codes.InvalidArgument, REASON_INVALID_AMOUNT
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "amount", Description: "Amount must be positive"}},
+		})
+	case errors.Is(err, feed.ErrQuoteNotFound):
+		code, reason = codes.NotFound, REASON_QUOTE_NOT_FOUND
+	case errors.Is(err, feed.ErrQuoteExpired):
+		code, reason = codes.FailedPrecondition, REASON_QUOTE_EXPIRED
+	case errors.Is(err, feed.ErrQuoteMoved):
+		code, reason = codes.Aborted, REASON_QUOTE_MOVED
+	case errors.Is(err, feed.ErrLimitPriceReached):
+		code, reason = codes.FailedPrecondition, REASON_LIMIT_PRICE_REACHED
+	case errors.Is(err, feed.ErrMaxInExceeded):
+		code, reason = codes.FailedPrecondition, REASON_MAX_IN_EXCEEDED
+	case errors.Is(err, history.ErrNoHistory):
+		code, reason = codes.NotFound, REASON_NO_HISTORY
+	case errors.Is(err, feed.ErrUnsupportedInterval):
+		code = codes.InvalidArgument
+	}
+
+	st := status.New(code, err.Error())
+	if reason == "" {
+		return st
+	}
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: ERROR_DOMAIN, Metadata: infoMetadata}
+	withDetails, detailsErr := st.WithDetails(append([]proto.Message{info}, details...)...)
+	if detailsErr != nil {
+		return st
+	}
+	return withDetails
+}
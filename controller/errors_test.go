@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pirosb3/real_feed/feed"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestStatusCodes(t *testing.T) {
+	cases := map[error]codes.Code{
+		feed.ErrStale:                    codes.Unavailable,
+		feed.ErrNoSnapshot:               codes.Unavailable,
+		feed.ErrInvalidAmount:            codes.InvalidArgument,
+		feed.ErrQuoteMoved:               codes.Aborted,
+		&feed.ProductError{}:             codes.NotFound,
+		&feed.LiquidityError{}:           codes.FailedPrecondition,
+		errors.New("Something happened"): codes.Unknown,
+	}
+	for err, code := range cases {
+		st := toStatus(err)
+		if st.Code() != code {
+			t.Errorf("Expected %s for '%v', got %s", code, err, st.Code())
+		}
+		if st.Message() != err.Error() {
+			t.Errorf("Expected the message to be kept, got %s", st.Message())
+		}
+	}
+}
+
+func TestLiquidityStatusDetails(t *testing.T) {
+	st := toStatus(&feed.LiquidityError{Requested: 2, Available: 1.5})
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("Expected a single detail, got %d", len(details))
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok {
+		t.Fatalf("Expected an ErrorInfo detail, got %T", details[0])
+	}
+	if info.Reason != REASON_INSUFFICIENT_LIQUIDITY || info.Metadata["available"] != "1.5" || info.Metadata["requested"] != "2" {
+		t.Errorf("Unexpected detail %v", info)
+	}
+}
+
+func TestLegacyClientsReadTheErrorField(t *testing.T) {
+	if err := rpcError(context.Background(), feed.ErrStale); err != nil {
+		t.Errorf("Expected no status error for legacy clients, got %v", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ERROR_MODE_METADATA, STATUS_ERROR_MODE))
+	if err := rpcError(ctx, feed.ErrStale); err == nil {
+		t.Error("Expected a status error for clients that asked for them")
+	}
+}
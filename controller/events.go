@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"pirosb3/real_feed/feed"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	EVENT_BOOK_UPDATE = "BOOK_UPDATE"
+	EVENT_TRADE       = "TRADE"
+	EVENT_CANDLE      = "CANDLE"
+)
+
+var droppedEventsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name:      "droppedEvents",
+	Help:      "Counts feed events dropped because a subscriber was too slow",
+	Namespace: "feed",
+}, []string{"uuid", "market"})
+
+// FeedEvent is published to subscribers every time the feed controller processes an update.
+type FeedEvent struct {
+	Type    string
+	Product string
+	Epoch   int64
+	Trade   *feed.Trade
+	Candle  *feed.Candle
+}
+
+// eventBroadcaster fans out events to every subscriber without blocking the publisher. Slow
+// subscribers miss events rather than holding up the feed.
+type eventBroadcaster struct {
+	lock        sync.Mutex
+	nextID      int
+	subscribers map[int]chan (*FeedEvent)
+	bufferSize  int
+	closed      bool
+}
+
+func newEventBroadcaster(bufferSize int) *eventBroadcaster {
+	return &eventBroadcaster{
+		subscribers: make(map[int]chan (*FeedEvent)),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (eb *eventBroadcaster) subscribe() (<-chan (*FeedEvent), func()) {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+
+	id := eb.nextID
+	eb.nextID++
+	subscriber := make(chan (*FeedEvent), eb.bufferSize)
+	if eb.closed {
+		close(subscriber)
+		return subscriber, func() {}
+	}
+	eb.subscribers[id] = subscriber
+
+	return subscriber, func() {
+		eb.lock.Lock()
+		defer eb.lock.Unlock()
+		if _, ok := eb.subscribers[id]; ok {
+			delete(eb.subscribers, id)
+			close(subscriber)
+		}
+	}
+}
+
+// close ends every subscription. Subscribing afterwards returns a closed channel.
+func (eb *eventBroadcaster) close() {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+
+	eb.closed = true
+	for id, subscriber := range eb.subscribers {
+		delete(eb.subscribers, id)
+		close(subscriber)
+	}
+}
+
+// publish returns the number of subscribers that could not keep up and dropped the event.
+func (eb *eventBroadcaster) publish(event *FeedEvent) int {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+
+	dropped := 0
+	for _, subscriber := range eb.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
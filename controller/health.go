@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const HEALTH_CHECK_INTERVAL = time.Second
+
+// ErrDisconnected is returned by Health while the controller has no open websocket connection.
+var ErrDisconnected = errors.New("Websocket is not connected")
+
+var readyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name:      "ready",
+	Help:      "Is 1 while the feed can serve quotes, 0 otherwise",
+	Namespace: "feed",
+}, []string{"market"})
+
+// Health returns nil if the controller can serve quotes: the book received a snapshot, was
+// updated within the staleness window and passes its integrity checks, and the websocket is
+// connected. Otherwise the error describes the first failing check.
+func (fc *FeedController) Health() error {
+	if err := fc.orderbook.Ready(); err != nil {
+		return err
+	}
+	fc.startLock.Lock()
+	defer fc.startLock.Unlock()
+	if fc.websocket == nil || !fc.websocket.IsConnected() {
+		return ErrDisconnected
+	}
+	return nil
+}
+
+// HealthChecker publishes the health of a feed controller through the standard gRPC health
+// service, under the product name, the names of `services` and the empty name of the server,
+// and through HTTP liveness and readiness handlers.
+type HealthChecker struct {
+	feedController *FeedController
+	product        string
+	services       []string
+	server         *health.Server
+	lock           sync.Mutex
+	lastErr        error
+	checked        bool
+	stopped        bool
+}
+
+// NewHealthChecker creates a health checker for `feedController`. Every service starts as not
+// serving, statuses are only updated once `.Run()` is called.
+func NewHealthChecker(feedController *FeedController, product string, services ...string) *HealthChecker {
+	hc := &HealthChecker{
+		feedController: feedController,
+		product:        product,
+		services:       services,
+		server:         health.NewServer(),
+	}
+	hc.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return hc
+}
+
+// Server returns the gRPC health service to register on the gRPC server.
+func (hc *HealthChecker) Server() *health.Server {
+	return hc.server
+}
+
+// Run checks the health of the feed every HEALTH_CHECK_INTERVAL until `ctx` is cancelled, after
+// which every service is reported as not serving.
+func (hc *HealthChecker) Run(ctx context.Context) {
+	timer := hc.feedController.clock.NewTicker(HEALTH_CHECK_INTERVAL)
+	defer timer.Stop()
+	for {
+		hc.check()
+		select {
+		case <-ctx.Done():
+			hc.Shutdown()
+			return
+		case <-timer.Chan():
+		}
+	}
+}
+
+// Shutdown reports every service as not serving, so that clients move to other instances
+// before the server stops.
+func (hc *HealthChecker) Shutdown() {
+	hc.lock.Lock()
+	hc.stopped = true
+	hc.lock.Unlock()
+	hc.server.Shutdown()
+	readyGauge.WithLabelValues(hc.product).Set(0)
+}
+
+func (hc *HealthChecker) setStatus(servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	hc.server.SetServingStatus("", servingStatus)
+	hc.server.SetServingStatus(hc.product, servingStatus)
+	for _, service := range hc.services {
+		hc.server.SetServingStatus(service, servingStatus)
+	}
+}
+
+func (hc *HealthChecker) check() {
+	err := hc.feedController.Health()
+
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+	if hc.stopped {
+		return
+	}
+	changed := !hc.checked || (err == nil) != (hc.lastErr == nil)
+	hc.checked = true
+	hc.lastErr = err
+	if !changed {
+		return
+	}
+	if err != nil {
+		log.WithField("market", hc.product).WithField("err", err.Error()).Warningln("Feed is not ready")
+		hc.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+		readyGauge.WithLabelValues(hc.product).Set(0)
+		return
+	}
+	log.WithField("market", hc.product).Infoln("Feed is ready")
+	hc.setStatus(healthpb.HealthCheckResponse_SERVING)
+	readyGauge.WithLabelValues(hc.product).Set(1)
+}
+
+// Healthz is the liveness handler: it succeeds until the checker is shut down.
+func (hc *HealthChecker) Healthz(w http.ResponseWriter, r *http.Request) {
+	hc.lock.Lock()
+	stopped := hc.stopped
+	hc.lock.Unlock()
+	if stopped {
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// Readyz is the readiness handler: it succeeds while the feed can serve quotes, and otherwise
+// describes why it cannot.
+func (hc *HealthChecker) Readyz(w http.ResponseWriter, r *http.Request) {
+	hc.lock.Lock()
+	stopped := hc.stopped
+	hc.lock.Unlock()
+	if stopped {
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if err := hc.feedController.Health(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
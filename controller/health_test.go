@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/feed"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, hc *HealthChecker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	response, err := hc.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Unexpected error for '%s': %s", service, err)
+	}
+	return response.Status
+}
+
+func TestHealthReportsBookState(t *testing.T) {
+	fc := NewFeedController(context.Background(), "ETH-DAI")
+	if err := fc.Health(); err != feed.ErrNoSnapshot {
+		t.Errorf("Expected no snapshot, got %v", err)
+	}
+	fc.Orderbook().SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "333.2", Size: "0.5"},
+	}, []*feed.Update{
+		&feed.Update{Price: "335.12", Size: "0.5"},
+	})
+	// The controller was never started, so there is no websocket connection
+	if err := fc.Health(); err != ErrDisconnected {
+		t.Errorf("Expected a disconnected websocket, got %v", err)
+	}
+}
+
+func TestHealthCheckerServesStatuses(t *testing.T) {
+	fc := NewFeedController(context.Background(), "ETH-DAI")
+	hc := NewHealthChecker(fc, "ETH-DAI", "OrderbookService")
+	hc.check()
+	for _, service := range []string{"", "ETH-DAI", "OrderbookService"} {
+		if status := servingStatus(t, hc, service); status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("Expected '%s' not to be serving, got %s", service, status)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	hc.Readyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), feed.ErrNoSnapshot.Error()) {
+		t.Errorf("Unexpected readiness %d %s", recorder.Code, recorder.Body.String())
+	}
+	recorder = httptest.NewRecorder()
+	hc.Healthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected to be live, got %d", recorder.Code)
+	}
+
+	hc.Shutdown()
+	recorder = httptest.NewRecorder()
+	hc.Healthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not to be live after shutting down, got %d", recorder.Code)
+	}
+}
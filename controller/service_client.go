@@ -2,8 +2,9 @@ package controller
 
 import (
 	"context"
-	"fmt"
+	"time"
 
+	"pirosb3/real_feed/feed"
 	"pirosb3/real_feed/rpc"
 )
 
@@ -20,18 +21,15 @@ func NewOrderbookGrpcController(feedController *FeedController, product string)
 	}
 }
 
-func (ob *OrderbookGrpcController) handleResponse(response float64, lastUpdated int64, err error, productRequested string) (*rpc.PricingResponse, error) {
-	if ob.product != productRequested {
-		return &rpc.PricingResponse{
-			Product: ob.product,
-			Error:   fmt.Sprintf("Requested quote for feed '%s', but service is serving feed '%s'", productRequested, ob.product),
-		}, nil
+func (ob *OrderbookGrpcController) handleResponse(ctx context.Context, response float64, lastUpdated int64, err error, productRequested string) (*rpc.PricingResponse, error) {
+	if mismatch := ob.checkProduct(productRequested); mismatch != nil {
+		err = mismatch
 	}
 	if err != nil {
 		return &rpc.PricingResponse{
 			Product: ob.product,
 			Error:   err.Error(),
-		}, nil
+		}, rpcError(ctx, err)
 	}
 	return &rpc.PricingResponse{
 		Product:     ob.product,
@@ -42,22 +40,432 @@ func (ob *OrderbookGrpcController) handleResponse(response float64, lastUpdated
 
 func (ob OrderbookGrpcController) BuyBase(ctx context.Context, in *rpc.PricingRequest) (*rpc.PricingResponse, error) {
 	response, lastUpdated, err := ob.feedController.BuyBase(float64(in.GetInAmount()))
-	return ob.handleResponse(response, lastUpdated, err, in.GetProduct())
+	return ob.handleResponse(ctx, response, lastUpdated, err, in.GetProduct())
 }
 
 func (ob OrderbookGrpcController) BuyQuote(ctx context.Context, in *rpc.PricingRequest) (*rpc.PricingResponse, error) {
 	response, lastUpdated, err := ob.feedController.BuyQuote(float64(in.GetInAmount()))
-	return ob.handleResponse(response, lastUpdated, err, in.GetProduct())
+	return ob.handleResponse(ctx, response, lastUpdated, err, in.GetProduct())
 }
 
 func (ob OrderbookGrpcController) SellBase(ctx context.Context, in *rpc.PricingRequest) (*rpc.PricingResponse, error) {
 	response, lastUpdated, err := ob.feedController.SellBase(float64(in.GetInAmount()))
-	return ob.handleResponse(response, lastUpdated, err, in.GetProduct())
+	return ob.handleResponse(ctx, response, lastUpdated, err, in.GetProduct())
 }
 
 func (ob OrderbookGrpcController) SellQuote(ctx context.Context, in *rpc.PricingRequest) (*rpc.PricingResponse, error) {
 	response, lastUpdated, err := ob.feedController.SellQuote(float64(in.GetInAmount()))
-	return ob.handleResponse(response, lastUpdated, err, in.GetProduct())
+	return ob.handleResponse(ctx, response, lastUpdated, err, in.GetProduct())
+}
+
+func (ob OrderbookGrpcController) QuoteAt(ctx context.Context, in *rpc.HistoricalPricingRequest) (*rpc.PricingResponse, error) {
+	at := time.Unix(0, in.GetTimestamp()*int64(time.Millisecond))
+	response, lastUpdated, err := ob.feedController.QuoteAt(at, in.GetOperation(), in.GetInAmount())
+	return ob.handleResponse(ctx, response, lastUpdated, err, in.GetProduct())
+}
+
+func (ob *OrderbookGrpcController) handleExactOutResponse(ctx context.Context, response float64, lastUpdated int64, err error, productRequested string) (*rpc.ExactOutResponse, error) {
+	if mismatch := ob.checkProduct(productRequested); mismatch != nil {
+		err = mismatch
+	}
+	if err != nil {
+		return &rpc.ExactOutResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+	return &rpc.ExactOutResponse{
+		Product:     ob.product,
+		LastUpdated: lastUpdated,
+		InAmount:    response,
+	}, nil
+}
+
+func exactOutOptions(in *rpc.ExactOutRequest) feed.QuoteOptions {
+	return feed.QuoteOptions{
+		FeeBps:     in.GetFeeBps(),
+		LimitPrice: in.GetLimitPrice(),
+		MaxIn:      in.GetMaxInAmount(),
+	}
+}
+
+func (ob OrderbookGrpcController) BuyBaseExactOut(ctx context.Context, in *rpc.ExactOutRequest) (*rpc.ExactOutResponse, error) {
+	response, lastUpdated, err := ob.feedController.BuyBaseExactOut(in.GetOutAmount(), exactOutOptions(in))
+	return ob.handleExactOutResponse(ctx, response, lastUpdated, err, in.GetProduct())
+}
+
+func (ob OrderbookGrpcController) BuyQuoteExactOut(ctx context.Context, in *rpc.ExactOutRequest) (*rpc.ExactOutResponse, error) {
+	response, lastUpdated, err := ob.feedController.BuyQuoteExactOut(in.GetOutAmount(), exactOutOptions(in))
+	return ob.handleExactOutResponse(ctx, response, lastUpdated, err, in.GetProduct())
+}
+
+func (ob OrderbookGrpcController) SellBaseExactOut(ctx context.Context, in *rpc.ExactOutRequest) (*rpc.ExactOutResponse, error) {
+	response, lastUpdated, err := ob.feedController.SellBaseExactOut(in.GetOutAmount(), exactOutOptions(in))
+	return ob.handleExactOutResponse(ctx, response, lastUpdated, err, in.GetProduct())
+}
+
+func (ob OrderbookGrpcController) SellQuoteExactOut(ctx context.Context, in *rpc.ExactOutRequest) (*rpc.ExactOutResponse, error) {
+	response, lastUpdated, err := ob.feedController.SellQuoteExactOut(in.GetOutAmount(), exactOutOptions(in))
+	return ob.handleExactOutResponse(ctx, response, lastUpdated, err, in.GetProduct())
+}
+
+func (ob OrderbookGrpcController) RequestQuote(ctx context.Context, in *rpc.FirmQuoteRequest) (*rpc.FirmQuoteResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.FirmQuoteResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	ttl := time.Duration(in.GetTtlMillis()) * time.Millisecond
+	quote, err := ob.feedController.RequestQuote(in.GetOperation(), in.GetInAmount(), ttl)
+	if err != nil {
+		return &rpc.FirmQuoteResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+	return &rpc.FirmQuoteResponse{
+		Product:     ob.product,
+		QuoteId:     quote.ID,
+		Operation:   quote.Operation,
+		InAmount:    quote.InAmount,
+		OutAmount:   quote.OutAmount,
+		Price:       quote.Price,
+		ExpiresAt:   quote.ExpiresAt.UnixNano() / 1e6,
+		LastUpdated: quote.LastUpdated,
+	}, nil
+}
+
+func (ob OrderbookGrpcController) AcceptQuote(ctx context.Context, in *rpc.AcceptQuoteRequest) (*rpc.AcceptQuoteResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.AcceptQuoteResponse{
+			Product: ob.product,
+			QuoteId: in.GetQuoteId(),
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	quote, err := ob.feedController.AcceptQuote(in.GetQuoteId(), in.GetToleranceBps())
+	response := &rpc.AcceptQuoteResponse{
+		Product:  ob.product,
+		QuoteId:  in.GetQuoteId(),
+		Accepted: err == nil,
+	}
+	if quote != nil {
+		response.OutAmount = quote.OutAmount
+		response.Price = quote.Price
+	}
+	if err != nil {
+		response.Error = err.Error()
+		return response, rpcError(ctx, err)
+	}
+	return response, nil
+}
+
+func toRPCTrade(trade *feed.Trade) *rpc.Trade {
+	return &rpc.Trade{
+		TradeId:   trade.TradeID,
+		Price:     trade.Price,
+		Size:      trade.Size,
+		Side:      trade.Side,
+		Timestamp: trade.Time.UnixNano() / 1e6,
+	}
+}
+
+// checkProduct returns an error if `productRequested` is not the product served.
+func (ob *OrderbookGrpcController) checkProduct(productRequested string) error {
+	if ob.product != productRequested {
+		return &feed.ProductError{Requested: productRequested, Served: ob.product}
+	}
+	return nil
+}
+
+func (ob OrderbookGrpcController) GetRecentTrades(ctx context.Context, in *rpc.TradesRequest) (*rpc.TradesResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.TradesResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	trades := ob.feedController.RecentTrades(int(in.GetLimit()))
+	lastPrice, stats := ob.feedController.TradeStats()
+	response := &rpc.TradesResponse{
+		Product:   ob.product,
+		Trades:    make([]*rpc.Trade, len(trades)),
+		LastPrice: lastPrice,
+		Stats:     make([]*rpc.TradeWindowStats, len(stats)),
+	}
+	for idx, trade := range trades {
+		response.Trades[idx] = toRPCTrade(trade)
+	}
+	for idx, windowStats := range stats {
+		response.Stats[idx] = &rpc.TradeWindowStats{
+			WindowSeconds: int64(windowStats.Window.Seconds()),
+			Count:         int32(windowStats.Count),
+			Volume:        windowStats.Volume,
+			BuyVolume:     windowStats.BuyVolume,
+			SellVolume:    windowStats.SellVolume,
+			Vwap:          windowStats.VWAP,
+		}
+	}
+	return response, nil
+}
+
+func (ob OrderbookGrpcController) StreamTrades(in *rpc.TradesRequest, stream rpc.OrderbookService_StreamTradesServer) error {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return toStatus(err).Err()
+	}
+
+	events, cancel := ob.feedController.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.Type != EVENT_TRADE {
+				continue
+			}
+			if err := stream.Send(toRPCTrade(event.Trade)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toRPCCandle(candle *feed.Candle) *rpc.Candle {
+	return &rpc.Candle{
+		IntervalSeconds: int64(candle.Interval.Seconds()),
+		Start:           candle.Start.UnixNano() / 1e6,
+		Open:            candle.Open,
+		High:            candle.High,
+		Low:             candle.Low,
+		Close:           candle.Close,
+		Volume:          candle.Volume,
+		TradeCount:      int32(candle.TradeCount),
+		Source:          candle.Source,
+	}
+}
+
+func (ob OrderbookGrpcController) GetCandles(ctx context.Context, in *rpc.CandlesRequest) (*rpc.CandlesResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.CandlesResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	candles, err := ob.feedController.Candles(time.Duration(in.GetIntervalSeconds())*time.Second, int(in.GetLimit()))
+	if err != nil {
+		return &rpc.CandlesResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+	response := &rpc.CandlesResponse{
+		Product: ob.product,
+		Candles: make([]*rpc.Candle, len(candles)),
+	}
+	for idx := range candles {
+		response.Candles[idx] = toRPCCandle(&candles[idx])
+	}
+	return response, nil
+}
+
+// StreamCandles sends every candle of the requested interval as soon as it closes.
+func (ob OrderbookGrpcController) StreamCandles(in *rpc.CandlesRequest, stream rpc.OrderbookService_StreamCandlesServer) error {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return toStatus(err).Err()
+	}
+	interval := time.Duration(in.GetIntervalSeconds()) * time.Second
+	if _, err := ob.feedController.Candles(interval, 1); err != nil {
+		return toStatus(err).Err()
+	}
+
+	events, cancel := ob.feedController.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.Type != EVENT_CANDLE || event.Candle.Interval != interval {
+				continue
+			}
+			if err := stream.Send(toRPCCandle(event.Candle)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (ob OrderbookGrpcController) GetLiquidityProfile(ctx context.Context, in *rpc.LiquidityRequest) (*rpc.LiquidityProfileResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.LiquidityProfileResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	bandsBps, sizes := in.GetBandsBps(), in.GetSizes()
+	if len(bandsBps) == 0 {
+		bandsBps = feed.DEFAULT_DEPTH_BANDS_BPS
+	}
+	if len(sizes) == 0 {
+		sizes = feed.DEFAULT_SLIPPAGE_SIZES
+	}
+	profile, err := ob.feedController.LiquidityProfile(bandsBps, sizes)
+	if err != nil {
+		return &rpc.LiquidityProfileResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	response := &rpc.LiquidityProfileResponse{
+		Product:     ob.product,
+		BestBid:     profile.BestBid,
+		BestAsk:     profile.BestAsk,
+		Mid:         profile.Mid,
+		SpreadBps:   profile.SpreadBps,
+		Bands:       make([]*rpc.DepthBand, len(profile.Bands)),
+		Slippage:    make([]*rpc.SlippagePoint, len(profile.Slippage)),
+		LastUpdated: profile.LastUpdated,
+	}
+	for idx, band := range profile.Bands {
+		response.Bands[idx] = &rpc.DepthBand{
+			Bps:         band.Bps,
+			BidSize:     band.BidSize,
+			AskSize:     band.AskSize,
+			BidNotional: band.BidNotional,
+			AskNotional: band.AskNotional,
+			Imbalance:   band.Imbalance,
+		}
+	}
+	for idx, point := range profile.Slippage {
+		response.Slippage[idx] = &rpc.SlippagePoint{
+			Size:            point.Size,
+			BuyFilled:       point.BuyFilled,
+			BuyPrice:        point.BuyPrice,
+			BuySlippageBps:  point.BuySlippageBps,
+			SellFilled:      point.SellFilled,
+			SellPrice:       point.SellPrice,
+			SellSlippageBps: point.SellSlippageBps,
+		}
+	}
+	return response, nil
+}
+
+func (ob OrderbookGrpcController) GetImpactCurve(ctx context.Context, in *rpc.ImpactCurveRequest) (*rpc.ImpactCurveResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.ImpactCurveResponse{
+			Product: ob.product,
+			Side:    in.GetSide(),
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	curve, err := ob.feedController.ImpactCurve(in.GetSide(), in.GetAmounts())
+	if err != nil {
+		return &rpc.ImpactCurveResponse{
+			Product: ob.product,
+			Side:    in.GetSide(),
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+	response := &rpc.ImpactCurveResponse{
+		Product:     ob.product,
+		Side:        curve.Side,
+		Mid:         curve.Mid,
+		Points:      make([]*rpc.ImpactPoint, len(curve.Points)),
+		LastUpdated: curve.LastUpdated,
+		BookVersion: curve.Version,
+	}
+	for idx, point := range curve.Points {
+		response.Points[idx] = &rpc.ImpactPoint{
+			Amount:       point.Amount,
+			Filled:       point.Filled,
+			Notional:     point.Notional,
+			AveragePrice: point.AveragePrice,
+			ImpactBps:    point.ImpactBps,
+		}
+	}
+	return response, nil
+}
+
+func toRPCLevels(levels []feed.DepthLevel) []*rpc.PriceLevel {
+	result := make([]*rpc.PriceLevel, len(levels))
+	for idx, level := range levels {
+		result[idx] = &rpc.PriceLevel{Price: level.Price, Size: level.Size}
+	}
+	return result
+}
+
+func (ob OrderbookGrpcController) GetTicker(ctx context.Context, in *rpc.TickerRequest) (*rpc.TickerResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.TickerResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	depth, err := ob.feedController.Depth(1)
+	if err == nil && (len(depth.Bids) == 0 || len(depth.Asks) == 0) {
+		err = feed.ErrInsufficientLiquidity
+	}
+	if err != nil {
+		return &rpc.TickerResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+	bestBid, bestAsk := depth.Bids[0], depth.Asks[0]
+	mid := (bestBid.Price + bestAsk.Price) / 2
+	lastPrice, _ := ob.feedController.TradeStats()
+	return &rpc.TickerResponse{
+		Product:        ob.product,
+		BestBid:        bestBid.Price,
+		BestBidSize:    bestBid.Size,
+		BestAsk:        bestAsk.Price,
+		BestAskSize:    bestAsk.Size,
+		Mid:            mid,
+		SpreadBps:      (bestAsk.Price - bestBid.Price) / mid * 10000,
+		LastTradePrice: lastPrice,
+		LastUpdated:    depth.LastUpdated,
+		BookVersion:    depth.Version,
+	}, nil
+}
+
+func (ob OrderbookGrpcController) GetDepth(ctx context.Context, in *rpc.DepthRequest) (*rpc.DepthResponse, error) {
+	if err := ob.checkProduct(in.GetProduct()); err != nil {
+		return &rpc.DepthResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+
+	depth, err := ob.feedController.Depth(int(in.GetLevels()))
+	if err != nil {
+		return &rpc.DepthResponse{
+			Product: ob.product,
+			Error:   err.Error(),
+		}, rpcError(ctx, err)
+	}
+	return &rpc.DepthResponse{
+		Product:     ob.product,
+		Bids:        toRPCLevels(depth.Bids),
+		Asks:        toRPCLevels(depth.Asks),
+		LastUpdated: depth.LastUpdated,
+		BookVersion: depth.Version,
+	}, nil
 }
 
 // func (ob OrderbookGrpcController) mustEmbedUnimplementedOrderbookServiceServer() {}
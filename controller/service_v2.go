@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"pirosb3/real_feed/feed"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DECIMAL_SIGNIFICANT_DIGITS is the number of significant digits a float64 always represents exactly.
+	DECIMAL_SIGNIFICANT_DIGITS = 15
+	MAX_BATCH_QUOTES           = 100
+)
+
+var decimalPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// OrderbookGrpcControllerV2 serves the v2 API from the same Feed Controller as the v1 API.
+type OrderbookGrpcControllerV2 struct {
+	rpcv2.UnimplementedOrderbookServiceServer
+	feedController *FeedController
+	product        string
+}
+
+func NewOrderbookGrpcControllerV2(feedController *FeedController, product string) *OrderbookGrpcControllerV2 {
+	return &OrderbookGrpcControllerV2{
+		feedController: feedController,
+		product:        product,
+	}
+}
+
+// parseDecimal parses a positive decimal string. Exponents and special values are rejected.
+func parseDecimal(value string) (float64, error) {
+	if !decimalPattern.MatchString(value) {
+		return -1, fmt.Errorf("%w: '%s' is not a decimal", feed.ErrInvalidAmount, value)
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return -1, fmt.Errorf("%w: %s", feed.ErrInvalidAmount, err.Error())
+	}
+	return parsed, nil
+}
+
+// formatDecimal formats `value` rounded to DECIMAL_SIGNIFICANT_DIGITS, dropping the noise float64
+// arithmetic leaves in the last digits.
+func formatDecimal(value float64) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', DECIMAL_SIGNIFICANT_DIGITS, 64), 64)
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// operationFor returns the v1 operation of a side and amount type. Buying or selling an amount of
+// quote is expressed in v1 from the point of view of the quote currency: spending quote to buy
+// base is SELL_QUOTE, and selling base to receive quote is BUY_QUOTE.
+func operationFor(side rpcv2.Side, amountType rpcv2.AmountType) (string, error) {
+	switch {
+	case side == rpcv2.Side_BUY && amountType == rpcv2.AmountType_BASE:
+		return feed.BUY_BASE, nil
+	case side == rpcv2.Side_SELL && amountType == rpcv2.AmountType_BASE:
+		return feed.SELL_BASE, nil
+	case side == rpcv2.Side_BUY && amountType == rpcv2.AmountType_QUOTE:
+		return feed.SELL_QUOTE, nil
+	case side == rpcv2.Side_SELL && amountType == rpcv2.AmountType_QUOTE:
+		return feed.BUY_QUOTE, nil
+	case side == rpcv2.Side_SIDE_UNSPECIFIED:
+		return "", status.Error(codes.InvalidArgument, "Side must be BUY or SELL")
+	}
+	return "", status.Error(codes.InvalidArgument, "Amount type must be BASE or QUOTE")
+}
+
+// quoteAgainst prices a quote against `book`.
+func (ob OrderbookGrpcControllerV2) quoteAgainst(book *feed.OrderbookFeed, in *rpcv2.QuoteRequest) (*rpcv2.QuoteResponse, error) {
+	if in.GetProduct() != ob.product {
+		return nil, toStatus(&feed.ProductError{Requested: in.GetProduct(), Served: ob.product}).Err()
+	}
+	operation, err := operationFor(in.GetSide(), in.GetAmountType())
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parseDecimal(in.GetAmount())
+	if err != nil {
+		return nil, toStatus(err).Err()
+	}
+
+	counterAmount, lastUpdated, err := book.Quote(operation, amount)
+	if err != nil {
+		return nil, toStatus(err).Err()
+	}
+	price := counterAmount / amount
+	if in.GetAmountType() == rpcv2.AmountType_QUOTE {
+		price = amount / counterAmount
+	}
+	return &rpcv2.QuoteResponse{
+		RequestId:     requestIDOrNew(in.GetRequestId()),
+		Product:       ob.product,
+		Side:          in.GetSide(),
+		AmountType:    in.GetAmountType(),
+		Amount:        in.GetAmount(),
+		CounterAmount: formatDecimal(counterAmount),
+		Price:         formatDecimal(price),
+		Metadata: &rpcv2.ResponseMetadata{
+			LastUpdated: lastUpdated,
+			BookVersion: book.GetVersion(),
+			Provisional: book.IsProvisional(),
+			ServedAt:    ob.feedController.clock.Now().UnixNano() / 1e6,
+		},
+	}, nil
+}
+
+func requestIDOrNew(requestID string) string {
+	if requestID == "" {
+		return uuid.New().String()
+	}
+	return requestID
+}
+
+func (ob OrderbookGrpcControllerV2) Quote(ctx context.Context, in *rpcv2.QuoteRequest) (*rpcv2.QuoteResponse, error) {
+	return ob.quoteAgainst(ob.feedController.Orderbook(), in)
+}
+
+// toQuoteError converts the status error of a failed quote to its batch result.
+func toQuoteError(err error) *rpcv2.QuoteError {
+	st := status.Convert(err)
+	quoteErr := &rpcv2.QuoteError{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			quoteErr.Reason = info.GetReason()
+			quoteErr.Metadata = info.GetMetadata()
+		}
+	}
+	return quoteErr
+}
+
+func (ob OrderbookGrpcControllerV2) BatchQuote(ctx context.Context, in *rpcv2.BatchQuoteRequest) (*rpcv2.BatchQuoteResponse, error) {
+	if len(in.GetQuotes()) > MAX_BATCH_QUOTES {
+		return nil, status.Errorf(codes.InvalidArgument, "A batch cannot hold more than %d quotes", MAX_BATCH_QUOTES)
+	}
+
+	// Every quote is priced against the same copy of the book
+	book := ob.feedController.Orderbook().Clone()
+	response := &rpcv2.BatchQuoteResponse{
+		RequestId: requestIDOrNew(in.GetRequestId()),
+		Results:   make([]*rpcv2.BatchQuoteResult, len(in.GetQuotes())),
+	}
+	for idx, request := range in.GetQuotes() {
+		quote, err := ob.quoteAgainst(book, request)
+		if err != nil {
+			response.Results[idx] = &rpcv2.BatchQuoteResult{Error: toQuoteError(err)}
+			continue
+		}
+		response.Results[idx] = &rpcv2.BatchQuoteResult{Quote: quote}
+	}
+	return response, nil
+}
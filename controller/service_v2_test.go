@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/feed"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newV2TestController() OrderbookGrpcControllerV2 {
+	fc := NewFeedController(context.Background(), "BTC-USD")
+	fc.Orderbook().SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "19000.01", Size: "1.23456789"},
+	}, []*feed.Update{
+		&feed.Update{Price: "19000.02", Size: "2.5"},
+	})
+	return *NewOrderbookGrpcControllerV2(fc, "BTC-USD")
+}
+
+func TestQuoteKeepsPrecision(t *testing.T) {
+	ob := newV2TestController()
+	response, err := ob.Quote(context.Background(), &rpcv2.QuoteRequest{
+		RequestId:  "leg-1",
+		Product:    "BTC-USD",
+		Side:       rpcv2.Side_SELL,
+		AmountType: rpcv2.AmountType_BASE,
+		Amount:     "1.23456789",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// A 32-bit float would have returned 23456.8
+	if response.GetCounterAmount() != "23456.8022556789" {
+		t.Errorf("Expected 23456.8022556789, got %s", response.GetCounterAmount())
+	}
+	if response.GetRequestId() != "leg-1" || response.GetPrice() != "19000.01" {
+		t.Errorf("Unexpected response %v", response)
+	}
+
+	// Spending quote buys base from the asks
+	response, err = ob.Quote(context.Background(), &rpcv2.QuoteRequest{
+		Product:    "BTC-USD",
+		Side:       rpcv2.Side_BUY,
+		AmountType: rpcv2.AmountType_QUOTE,
+		Amount:     "19000.02",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if response.GetCounterAmount() != "1" || response.GetRequestId() == "" {
+		t.Errorf("Unexpected response %v", response)
+	}
+}
+
+func TestQuoteRejectsInvalidRequests(t *testing.T) {
+	ob := newV2TestController()
+	requests := []*rpcv2.QuoteRequest{
+		{Product: "BTC-USD", Side: rpcv2.Side_BUY, AmountType: rpcv2.AmountType_BASE, Amount: "1e3"},
+		{Product: "BTC-USD", Side: rpcv2.Side_BUY, AmountType: rpcv2.AmountType_BASE, Amount: "0"},
+		{Product: "BTC-USD", AmountType: rpcv2.AmountType_BASE, Amount: "1"},
+		{Product: "BTC-USD", Side: rpcv2.Side_SELL, Amount: "1"},
+	}
+	for _, request := range requests {
+		if _, err := ob.Quote(context.Background(), request); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected %v to be rejected, got %v", request, err)
+		}
+	}
+	_, err := ob.Quote(context.Background(), &rpcv2.QuoteRequest{Product: "BTC-USD", Side: rpcv2.Side_BUY, AmountType: rpcv2.AmountType_BASE, Amount: "3"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected insufficient liquidity, got %v", err)
+	}
+}
+
+func TestBatchQuoteReportsErrorsPerItem(t *testing.T) {
+	ob := newV2TestController()
+	response, err := ob.BatchQuote(context.Background(), &rpcv2.BatchQuoteRequest{
+		Quotes: []*rpcv2.QuoteRequest{
+			{RequestId: "buy", Product: "BTC-USD", Side: rpcv2.Side_BUY, AmountType: rpcv2.AmountType_BASE, Amount: "2"},
+			{RequestId: "too-large", Product: "BTC-USD", Side: rpcv2.Side_BUY, AmountType: rpcv2.AmountType_BASE, Amount: "3"},
+			{RequestId: "other-product", Product: "ETH-USD", Side: rpcv2.Side_SELL, AmountType: rpcv2.AmountType_BASE, Amount: "1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	results := response.GetResults()
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].GetQuote().GetCounterAmount() != "38000.04" || results[0].GetError() != nil {
+		t.Errorf("Unexpected result %v", results[0])
+	}
+	if results[1].GetError().GetReason() != REASON_INSUFFICIENT_LIQUIDITY || results[1].GetError().GetMetadata()["available"] != "2.5" {
+		t.Errorf("Expected insufficient liquidity, got %v", results[1])
+	}
+	if codes.Code(results[2].GetError().GetCode()) != codes.NotFound {
+		t.Errorf("Expected an unknown product, got %v", results[2])
+	}
+	if results[0].GetQuote().GetMetadata().GetBookVersion() != ob.feedController.Orderbook().GetVersion() {
+		t.Error("Expected the quotes to be priced against the current version")
+	}
+}
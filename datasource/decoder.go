@@ -0,0 +1,87 @@
+package datasource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"pirosb3/real_feed/feed"
+)
+
+type validator interface {
+	Validate() error
+}
+
+// peekMessageType reads the top-level "type" field of a frame, stopping as soon as it is found.
+// Coinbase Pro sends the type first, so usually only two tokens are read; fields before it are
+// skipped without being decoded. The frame is fully parsed, and validated, afterwards.
+func peekMessageType(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return "", err
+	} else if token != json.Delim('{') {
+		return "", errors.New("Message is not an object")
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if key != "type" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return "", err
+			}
+			continue
+		}
+		value, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		msgType, ok := value.(string)
+		if !ok || msgType == "" {
+			break
+		}
+		return msgType, nil
+	}
+	return "", errors.New("Message has no type")
+}
+
+// DecodeMessage decodes a raw websocket frame into its typed message and validates it. Message
+// types that are not modelled are returned as a bare feed.WebsocketType so callers can still log
+// them. An error is returned for frames that are malformed or fail validation.
+func DecodeMessage(data []byte) (feed.WebsocketMessage, error) {
+	msgType, err := peekMessageType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg feed.WebsocketMessage
+	switch msgType {
+	case "snapshot":
+		msg = &feed.L2SnapshotMessage{}
+	case "l2update":
+		msg = &feed.L2UpdateMessage{}
+	case "heartbeat":
+		msg = &feed.HeartbeatMessage{}
+	case "match", "last_match":
+		msg = &feed.MatchMessage{}
+	case "received", "open", "done", "change", "activate":
+		msg = &feed.FullChannelMessage{}
+	case "subscriptions":
+		msg = &feed.SubscriptionsMessage{}
+	case "error":
+		msg = &feed.ErrorMessage{}
+	default:
+		return feed.WebsocketType{Type: msgType}, nil
+	}
+
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	if v, ok := msg.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
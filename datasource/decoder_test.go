@@ -0,0 +1,136 @@
+package datasource
+
+import (
+	"pirosb3/real_feed/feed"
+	"testing"
+)
+
+func TestDecodeSnapshot(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"type":"snapshot","product_id":"ETH-USD","bids":[["333.2","0.5"]],"asks":[["335.12","0.5"],["336","1"]]}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	snapshot, ok := msg.(*feed.L2SnapshotMessage)
+	if !ok {
+		t.Fatalf("Expected a snapshot, got %T", msg)
+	}
+	bids, asks := snapshot.Updates()
+	if len(bids) != 1 || len(asks) != 2 {
+		t.Errorf("Expected 1 bid and 2 asks, got %d and %d", len(bids), len(asks))
+	}
+	if bids[0].Price != "333.2" || bids[0].Size != "0.5" {
+		t.Errorf("Unexpected bid %+v", bids[0])
+	}
+}
+
+func TestDecodeUpdate(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"type":"l2update","product_id":"ETH-USD","changes":[["buy","333.2","0"],["sell","336","2.5"]],"time":"2020-10-11T20:50:02.941691Z"}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	update, ok := msg.(*feed.L2UpdateMessage)
+	if !ok {
+		t.Fatalf("Expected an update, got %T", msg)
+	}
+	if update.Time.Unix() != 1602449402 {
+		t.Errorf("Expected 1602449402 but got %d", update.Time.Unix())
+	}
+	bids, asks := update.Updates()
+	if len(bids) != 1 || len(asks) != 1 {
+		t.Errorf("Expected 1 bid and 1 ask, got %d and %d", len(bids), len(asks))
+	}
+}
+
+func TestDecodeMatch(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"type":"match","trade_id":10,"sequence":50,"maker_order_id":"ac928c66-ca53-498f-9c13-a110027a60e8","taker_order_id":"132fb6ae-456b-4654-b4e0-d681ac05cea1","time":"2014-11-07T08:19:27.028459Z","product_id":"BTC-USD","size":"5.23512","price":"400.23","side":"sell"}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	match, ok := msg.(*feed.MatchMessage)
+	if !ok {
+		t.Fatalf("Expected a match, got %T", msg)
+	}
+	trade := match.Trade()
+	if trade.Price != 400.23 || trade.Size != 5.23512 || trade.TradeID != 10 {
+		t.Errorf("Unexpected trade %+v", trade)
+	}
+	if trade.Side != feed.BUY {
+		t.Errorf("A sell maker means a buy aggressor, got %s", trade.Side)
+	}
+}
+
+func TestDecodeFullChannel(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"type":"open","time":"2014-11-07T08:19:27.028459Z","product_id":"BTC-USD","sequence":10,"order_id":"d50ec984-77a8-460a-b958-66f114b0de9b","price":"200.2","remaining_size":"1.00","side":"sell"}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	open, ok := msg.(*feed.FullChannelMessage)
+	if !ok {
+		t.Fatalf("Expected a full channel message, got %T", msg)
+	}
+	if open.RemainingSize != "1.00" || open.Sequence != 10 {
+		t.Errorf("Unexpected message %+v", open)
+	}
+	if _, err := DecodeMessage([]byte(`{"type":"activate","product_id":"BTC-USD","timestamp":"1483736448.299000","order_id":"7b52009b-64fd-0a2a-49e6-d8a939753077","stop_type":"entry","side":"buy","stop_price":"80","size":"2","funds":"50"}`)); err != nil {
+		t.Errorf("Expected an activate message without sequence to be accepted, got %v", err)
+	}
+	if _, err := DecodeMessage([]byte(`{"type":"done","product_id":"BTC-USD","sequence":10,"side":"sell"}`)); err == nil {
+		t.Error("Expected a message without order id to be rejected")
+	}
+}
+
+func TestDecodeTypeNotFirst(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"sequence":12,"type" : "heartbeat","product_id":"ETH-USD","time":"2020-10-11T20:50:02.941691Z"}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := msg.(*feed.HeartbeatMessage); !ok {
+		t.Errorf("Expected a heartbeat, got %T", msg)
+	}
+}
+
+func TestDecodeUnknownType(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"type":"status","products":[]}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if msg.GetType() != "status" {
+		t.Errorf("Expected status, got %s", msg.GetType())
+	}
+}
+
+func TestDecodeRejectsInvalidMessages(t *testing.T) {
+	frames := []string{
+		`not json`,
+		`["type","heartbeat"]`,
+		`{"type":1,"product_id":"ETH-USD"}`,
+		`{"product_id":"ETH-USD"}`,
+		`{"type":"snapshot","product_id":"ETH-USD","bids":[["333.2"]],"asks":[]}`,
+		`{"type":"snapshot","product_id":"ETH-USD","bids":[["abc","1"]],"asks":[]}`,
+		`{"type":"snapshot","product_id":"ETH-USD","bids":[["333.2","-1"]],"asks":[]}`,
+		`{"type":"snapshot","product_id":"ETH-USD","bids":[[1, 2]],"asks":[]}`,
+		`{"type":"l2update","product_id":"ETH-USD","changes":[["hold","333.2","1"]],"time":"2020-10-11T20:50:02.941691Z"}`,
+		`{"type":"l2update","product_id":"ETH-USD","changes":[["buy","333.2"]],"time":"2020-10-11T20:50:02.941691Z"}`,
+		`{"type":"l2update","product_id":"ETH-USD","changes":[["buy","NaN","1"]],"time":"2020-10-11T20:50:02.941691Z"}`,
+		`{"type":"l2update","product_id":"ETH-USD","changes":[]}`,
+		`{"type":"match","trade_id":10,"time":"2014-11-07T08:19:27.028459Z","product_id":"BTC-USD","size":"0","price":"400.23","side":"sell"}`,
+		`{"type":"open","product_id":"BTC-USD","sequence":10,"order_id":"d50ec984-77a8-460a-b958-66f114b0de9b","price":"","remaining_size":"1.00","side":"sell"}`,
+		`{"type":"open","product_id":"BTC-USD","sequence":10,"order_id":"d50ec984-77a8-460a-b958-66f114b0de9b","remaining_size":"1.00","side":"sell"}`,
+		`{"type":"open","product_id":"BTC-USD","sequence":10,"order_id":"d50ec984-77a8-460a-b958-66f114b0de9b","price":"200.2","side":"sell"}`,
+	}
+	for _, frame := range frames {
+		if _, err := DecodeMessage([]byte(frame)); err == nil {
+			t.Errorf("Expected frame to be rejected: %s", frame)
+		}
+	}
+}
+
+func TestDecodeTypeInsideNestedValues(t *testing.T) {
+	msg, err := DecodeMessage([]byte(`{"sequence":12,"meta":{"type":"open"},"type":"heartbeat","product_id":"ETH-USD","time":"2020-10-11T20:50:02.941691Z"}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := msg.(*feed.HeartbeatMessage); !ok {
+		t.Errorf("Expected a heartbeat, got %T", msg)
+	}
+}
@@ -0,0 +1,38 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pirosb3/real_feed/feed"
+)
+
+const COINBASE_REST_URL = "https://api.pro.coinbase.com"
+
+// FetchLevelThreeSnapshot downloads the full order-by-order book for a product from the REST API.
+// The snapshot carries the sequence number needed to line it up with the "full" websocket channel.
+func FetchLevelThreeSnapshot(ctx context.Context, product string) (*feed.L3SnapshotMessage, error) {
+	url := fmt.Sprintf("%s/products/%s/book?level=3", COINBASE_REST_URL, product)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code %d when fetching level 3 snapshot", response.StatusCode)
+	}
+
+	var snapshot feed.L3SnapshotMessage
+	if err := json.NewDecoder(response.Body).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if err := snapshot.Validate(); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"pirosb3/real_feed/clock"
 	"pirosb3/real_feed/feed"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,15 +17,15 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const heartbeatTTLSeconds = 4
+const (
+	HEARTBEAT_TTL_SECONDS  = 4
+	COINBASE_WEBSOCKET_URL = "wss://ws-feed.pro.coinbase.com"
+)
 
-var (
-	pricingProm = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name:      "pricing",
-		Help:      "Orderbook visualization for 50 ETH",
-		Namespace: "feed",
-	}, []string{"uuid", "market"})
+// DEFAULT_CHANNELS are the channels subscribed to when running a level 2 book.
+var DEFAULT_CHANNELS = []string{"level2", "heartbeat", "matches"}
 
+var (
 	updatesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name:      "updates",
 		Help:      "Shows the frequency of orderbook updates coming out of the websocket",
@@ -41,6 +43,11 @@ var (
 		Help:      "Shows the frequency of timeouts",
 		Namespace: "feed",
 	}, []string{"uuid", "market"})
+	invalidMessagesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "invalidMessages",
+		Help:      "Counts websocket messages that could not be decoded or failed validation",
+		Namespace: "feed",
+	}, []string{"uuid", "market"})
 	wsLatency = promauto.NewSummaryVec(prometheus.SummaryOpts{
 		Name:      "websocketUpdateFrequency",
 		Help:      "Shows the frequency of websocket responses",
@@ -48,39 +55,55 @@ var (
 	}, []string{"uuid", "market"})
 )
 
+// FrameRecorder receives every raw frame read from the websocket, before it is decoded.
+type FrameRecorder interface {
+	Record(receivedAt time.Time, frame []byte)
+}
+
 type CoinbaseProWebsocket struct {
 	uuid                string
 	startLock           sync.Mutex
 	websocketConn       *websocket.Conn
 	product             string
+	channels            []string
+	recorder            FrameRecorder
+	clock               clock.Clock
+	url                 string
+	heartbeatTTL        time.Duration
 	running             bool
+	connected           int32
+	wg                  sync.WaitGroup
 	ctx                 context.Context
-	outChan             chan (map[string]interface{})
+	outChan             chan (feed.WebsocketMessage)
 	inChan              chan (interface{})
-	outInternalChan     chan (map[string]interface{})
+	outInternalChan     chan (feed.WebsocketMessage)
 	timeoutInternalChan chan (bool)
 }
 
 // NewCoinbaseProWebsocket creates a new Coinbase Pro websocket feed. The feed will only start running once `.Start()` is called on the websocket.
 // The `product` should be a Coinbase Pro ticket (example: "ETH-USD"), the `outChan` and `inChan` passed in allow the feed to send / receive messages
 // and allow any external component to interact with the websocket service.
-// This websocket is also fault-tolerant, if an update is not received within `heartbeatTTLSeconds` seconds, the websocket is automatically re-created.
+// This websocket is also fault-tolerant, if an update is not received within `HEARTBEAT_TTL_SECONDS` seconds (see `SetHeartbeatTTL`), the websocket is automatically re-created.
 // To shutdown the websocket, simply cancel the context passed in as first argument.
 func NewCoinbaseProWebsocket(
 	ctx context.Context,
 	product string,
-	outChan chan (map[string]interface{}),
+	outChan chan (feed.WebsocketMessage),
 	inChan chan (interface{}),
 ) *CoinbaseProWebsocket {
 	aUUID, _ := uuid.NewUUID()
 	return &CoinbaseProWebsocket{
 		uuid:                aUUID.String(),
 		product:             product,
+		channels:            DEFAULT_CHANNELS,
+		clock:               clock.NewReal(),
+		url:                 COINBASE_WEBSOCKET_URL,
+		heartbeatTTL:        HEARTBEAT_TTL_SECONDS * time.Second,
 		running:             false,
 		ctx:                 ctx,
 		inChan:              inChan,
 		outChan:             outChan,
-		outInternalChan:     make(chan (map[string]interface{})),
+		outInternalChan:     make(chan (feed.WebsocketMessage)),
 		timeoutInternalChan: make(chan bool),
 	}
 }
@@ -93,16 +116,100 @@ func (ws *CoinbaseProWebsocket) makeSubscriptionMessage() feed.MessageSubscripti
 		ProductIds: []string{
 			ws.product,
 		},
-		Channels: []interface{}{
-			"level2",
-			"heartbeat",
-		},
+		Channels: make([]interface{}, len(ws.channels)),
+	}
+	for idx, channel := range ws.channels {
+		subscription.Channels[idx] = channel
 	}
 	return subscription
 }
 
+// RequestSnapshot asks the exchange for a fresh snapshot by re-subscribing to the book channels.
+// Coinbase Pro always sends a snapshot as the first message of a level2 subscription. The request
+// is dropped, and false returned, if the websocket is busy.
+func (ws *CoinbaseProWebsocket) RequestSnapshot() bool {
+	unsubscribe := ws.makeSubscriptionMessage()
+	unsubscribe.Type = "unsubscribe"
+	for _, msg := range []interface{}{unsubscribe, ws.makeSubscriptionMessage()} {
+		select {
+		case ws.inChan <- msg:
+		default:
+			log.Warningln("Websocket is busy, snapshot request was dropped")
+			return false
+		}
+	}
+	return true
+}
+
+// SetChannels replaces the channels the websocket subscribes to. It must be called before `.Start()`.
+func (ws *CoinbaseProWebsocket) SetChannels(channels ...string) error {
+	ws.startLock.Lock()
+	defer ws.startLock.Unlock()
+
+	if ws.running {
+		return errors.New("Channels cannot be changed once the websocket is running")
+	}
+	ws.channels = channels
+	return nil
+}
+
+// SetRecorder passes every frame read from the websocket to `recorder`. It must be called before `.Start()`.
+func (ws *CoinbaseProWebsocket) SetRecorder(recorder FrameRecorder) error {
+	ws.startLock.Lock()
+	defer ws.startLock.Unlock()
+
+	if ws.running {
+		return errors.New("Recorder cannot be changed once the websocket is running")
+	}
+	ws.recorder = recorder
+	return nil
+}
+
+// SetURL replaces the websocket endpoint. It must be called before `.Start()`.
+func (ws *CoinbaseProWebsocket) SetURL(url string) error {
+	ws.startLock.Lock()
+	defer ws.startLock.Unlock()
+
+	if ws.running {
+		return errors.New("URL cannot be changed once the websocket is running")
+	}
+	ws.url = url
+	return nil
+}
+
+// SetHeartbeatTTL replaces how long the websocket may stay silent before it is re-created. It must
+// be called before `.Start()`.
+func (ws *CoinbaseProWebsocket) SetHeartbeatTTL(ttl time.Duration) error {
+	ws.startLock.Lock()
+	defer ws.startLock.Unlock()
+
+	if ws.running {
+		return errors.New("Heartbeat TTL cannot be changed once the websocket is running")
+	}
+	ws.heartbeatTTL = ttl
+	return nil
+}
+
+// SetClock replaces the clock used for heartbeat timeouts and latency measurements. It must be
+// called before `.Start()`.
+func (ws *CoinbaseProWebsocket) SetClock(clock clock.Clock) error {
+	ws.startLock.Lock()
+	defer ws.startLock.Unlock()
+
+	if ws.running {
+		return errors.New("Clock cannot be changed once the websocket is running")
+	}
+	ws.clock = clock
+	return nil
+}
+
 func (ws *CoinbaseProWebsocket) runLoop() {
+	defer ws.wg.Done()
+	heartbeat := ws.clock.NewTimer(ws.heartbeatTTL)
+	defer heartbeat.Stop()
 	for {
+		// The websocket times out when nothing happened for a whole heartbeat
+		heartbeat.Reset(ws.heartbeatTTL)
 		select {
 		case <-ws.ctx.Done():
 			// Parent context wants us to shut down. Simply stop websocket
@@ -111,7 +218,8 @@ func (ws *CoinbaseProWebsocket) runLoop() {
 		case msgIn := <-ws.inChan:
 			// Some other process is trying to write a message to the websocket
 			if ws.websocketConn == nil {
-				log.Fatalln("Configured websocket does not exist, this should never happen. Message was skipped")
+				log.Warningln("Configured websocket does not exist, message was skipped")
+				continue
 			}
 			ws.websocketConn.WriteJSON(msgIn)
 		case msgOut := <-ws.outInternalChan:
@@ -123,18 +231,22 @@ func (ws *CoinbaseProWebsocket) runLoop() {
 				log.Warningln("Websocket has no consumer for outgoing messages, dropping the message.")
 				droppedPacketsCounter.WithLabelValues(ws.uuid, ws.product).Inc()
 			}
-		case <-time.After(time.Second * heartbeatTTLSeconds):
+		case <-heartbeat.Chan():
 			// Something is wrong, websocket has not been responding for a fair amount of time. We should recreate the websocket
 			timeoutsCounter.WithLabelValues(ws.uuid, ws.product).Inc()
 			ws.timeoutInternalChan <- true
+			ws.wg.Add(1)
 			go ws.setupWebsocket()
 		}
 	}
 }
 
 func (ws *CoinbaseProWebsocket) setupWebsocket() {
+	defer ws.wg.Done()
 	var connection *websocket.Conn
+	ws.wg.Add(1)
 	go func() {
+		defer ws.wg.Done()
 		for {
 			<-ws.timeoutInternalChan
 			log.Warningln("Connection was intentionally closed due to a timeout or due to parent context closing")
@@ -145,24 +257,39 @@ func (ws *CoinbaseProWebsocket) setupWebsocket() {
 		}
 	}()
 
-	connection, _, err := websocket.DefaultDialer.Dial("wss://ws-feed.pro.coinbase.com", http.Header{})
+	connection, _, err := websocket.DefaultDialer.DialContext(ws.ctx, ws.url, http.Header{})
 	if err != nil {
 		log.WithField("err", err.Error()).Errorln("error in dialling initial connection")
 		return
 	}
 	ws.websocketConn = connection
 	connection.WriteJSON(ws.makeSubscriptionMessage())
+	// Counted rather than set, as a connection replaced after a timeout may close after its successor opened
+	atomic.AddInt32(&ws.connected, 1)
+	defer atomic.AddInt32(&ws.connected, -1)
 	for {
-		start := time.Now().Unix()
-		var wsType map[string]interface{}
-		err := connection.ReadJSON(&wsType)
+		start := ws.clock.Now().Unix()
+		_, data, err := connection.ReadMessage()
 		if err != nil {
 			log.Errorln(err.Error())
 			ws.websocketConn = nil
 			return
 		}
-		ws.outInternalChan <- wsType
-		end := time.Now().Unix()
+		if ws.recorder != nil {
+			ws.recorder.Record(ws.clock.Now(), data)
+		}
+		msg, err := DecodeMessage(data)
+		if err != nil {
+			log.WithField("err", err.Error()).Warningln("Skipped invalid message from websocket")
+			invalidMessagesCounter.WithLabelValues(ws.uuid, ws.product).Inc()
+			continue
+		}
+		select {
+		case ws.outInternalChan <- msg:
+		case <-ws.ctx.Done():
+			return
+		}
+		end := ws.clock.Now().Unix()
 		wsLatency.WithLabelValues(ws.uuid, ws.product).Observe(float64(end - start))
 	}
 }
@@ -179,6 +306,7 @@ func (ws *CoinbaseProWebsocket) Start() error {
 	ws.running = true
 
 	// Start websocket internal component
+	ws.wg.Add(2)
 	go ws.setupWebsocket()
 
 	// Start context manager
@@ -186,3 +314,14 @@ func (ws *CoinbaseProWebsocket) Start() error {
 
 	return nil
 }
+
+// IsConnected returns true while a connection to the websocket is open.
+func (ws *CoinbaseProWebsocket) IsConnected() bool {
+	return atomic.LoadInt32(&ws.connected) > 0
+}
+
+// Wait blocks until every goroutine of the websocket exited, which happens once the context
+// passed to the websocket is cancelled.
+func (ws *CoinbaseProWebsocket) Wait() {
+	ws.wg.Wait()
+}
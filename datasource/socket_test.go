@@ -2,12 +2,13 @@ package datasource
 
 import (
 	"context"
+	"pirosb3/real_feed/feed"
 	"testing"
 	"time"
 )
 
 func TestContextShutsDown(t *testing.T) {
-	outChan := make(chan (map[string]interface{}))
+	outChan := make(chan (feed.WebsocketMessage))
 	inChan := make(chan (interface{}))
 
 	ctx, cancelFn := context.WithCancel(context.Background())
@@ -0,0 +1,173 @@
+package feed
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	CANDLE_SOURCE_MID   = "MID"
+	CANDLE_SOURCE_TRADE = "TRADE"
+)
+
+var ErrUnsupportedInterval = errors.New("Candle interval is not supported")
+
+var lateObservationsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name:      "lateCandleObservations",
+	Help:      "Counts mids and trades dropped because the bar they belong to was already closed",
+	Namespace: "feed",
+}, []string{"market", "source"})
+
+// CandleBuilder aggregates mid prices and trades into OHLCV bars at several intervals, keeping a
+// bounded history for each. A bar is built from mid prices until its first trade arrives, from
+// then on only trades move its price. Observations older than the bar being built are dropped, as
+// their bar was already closed.
+type CandleBuilder struct {
+	ProductID  string
+	intervals  []time.Duration
+	maxCandles int
+	closed     map[time.Duration][]*Candle
+	current    map[time.Duration]*Candle
+	lock       *sync.RWMutex
+}
+
+// rollover returns the bar an observation made `at` belongs to, closing the current bar if a new
+// one starts. A nil bar is returned for late observations, whose bar was already closed.
+func (cb *CandleBuilder) rollover(interval time.Duration, at time.Time) (*Candle, *Candle) {
+	start := at.Truncate(interval)
+	current := cb.current[interval]
+	if current != nil && start.Before(current.Start) {
+		return nil, nil
+	}
+	if current != nil && start.Equal(current.Start) {
+		return current, nil
+	}
+
+	var closedCandle *Candle
+	if current != nil {
+		closedCandle = current
+		history := append(cb.closed[interval], current)
+		if len(history) > cb.maxCandles {
+			history = append([]*Candle(nil), history[len(history)-cb.maxCandles:]...)
+		}
+		cb.closed[interval] = history
+	}
+	current = &Candle{Interval: interval, Start: start}
+	cb.current[interval] = current
+	return current, closedCandle
+}
+
+func (candle *Candle) reset(source string, price float64) {
+	candle.Source = source
+	candle.Open, candle.High, candle.Low, candle.Close = price, price, price, price
+}
+
+func (candle *Candle) observe(price float64) {
+	if price > candle.High {
+		candle.High = price
+	}
+	if price < candle.Low {
+		candle.Low = price
+	}
+	candle.Close = price
+}
+
+// AddMid records a mid price observation, and returns a copy of every bar it closed.
+func (cb *CandleBuilder) AddMid(at time.Time, mid float64) []Candle {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	var closedCandles []Candle
+	for _, interval := range cb.intervals {
+		current, closedCandle := cb.rollover(interval, at)
+		if closedCandle != nil {
+			closedCandles = append(closedCandles, *closedCandle)
+		}
+		if current == nil {
+			lateObservationsCounter.WithLabelValues(cb.ProductID, CANDLE_SOURCE_MID).Inc()
+			continue
+		}
+		switch current.Source {
+		case "":
+			current.reset(CANDLE_SOURCE_MID, mid)
+		case CANDLE_SOURCE_MID:
+			current.observe(mid)
+		}
+	}
+	return closedCandles
+}
+
+// AddTrade records a trade, and returns a copy of every bar it closed.
+func (cb *CandleBuilder) AddTrade(trade *Trade) []Candle {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	var closedCandles []Candle
+	for _, interval := range cb.intervals {
+		current, closedCandle := cb.rollover(interval, trade.Time)
+		if closedCandle != nil {
+			closedCandles = append(closedCandles, *closedCandle)
+		}
+		if current == nil {
+			lateObservationsCounter.WithLabelValues(cb.ProductID, CANDLE_SOURCE_TRADE).Inc()
+			continue
+		}
+		if current.Source != CANDLE_SOURCE_TRADE {
+			current.reset(CANDLE_SOURCE_TRADE, trade.Price)
+		} else {
+			current.observe(trade.Price)
+		}
+		current.Volume += trade.Size
+		current.TradeCount++
+	}
+	return closedCandles
+}
+
+// Candles returns up to `limit` closed bars for an interval, oldest first. When `includeCurrent`
+// is true the bar still being built is appended. A limit of 0 returns the whole history.
+func (cb *CandleBuilder) Candles(interval time.Duration, limit int, includeCurrent bool) ([]Candle, error) {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+
+	history, ok := cb.closed[interval]
+	if !ok {
+		return nil, ErrUnsupportedInterval
+	}
+	if includeCurrent && cb.current[interval] != nil {
+		history = append(append([]*Candle(nil), history...), cb.current[interval])
+	}
+	if limit > 0 && limit < len(history) {
+		history = history[len(history)-limit:]
+	}
+	result := make([]Candle, len(history))
+	for idx, candle := range history {
+		result[idx] = *candle
+	}
+	return result, nil
+}
+
+// Intervals returns the intervals bars are built for.
+func (cb *CandleBuilder) Intervals() []time.Duration {
+	return cb.intervals
+}
+
+// NewCandleBuilder creates a builder for every interval in `intervals`, keeping at most
+// `maxCandles` closed bars per interval.
+func NewCandleBuilder(productID string, intervals []time.Duration, maxCandles int) *CandleBuilder {
+	closed := make(map[time.Duration][]*Candle)
+	for _, interval := range intervals {
+		closed[interval] = nil
+	}
+	return &CandleBuilder{
+		ProductID:  productID,
+		intervals:  intervals,
+		maxCandles: maxCandles,
+		closed:     closed,
+		current:    make(map[time.Duration]*Candle),
+		lock:       &sync.RWMutex{},
+	}
+}
@@ -0,0 +1,79 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCandlesFromMids(t *testing.T) {
+	cb := NewCandleBuilder("ETH-DAI", []time.Duration{time.Second, time.Minute}, 10)
+	start := time.Unix(1602449400, 0)
+	cb.AddMid(start, 100)
+	cb.AddMid(start.Add(200*time.Millisecond), 105)
+	cb.AddMid(start.Add(400*time.Millisecond), 95)
+	closed := cb.AddMid(start.Add(time.Second), 101)
+
+	if len(closed) != 1 || closed[0].Interval != time.Second {
+		t.Fatalf("Expected the 1s bar to close, got %+v", closed)
+	}
+	bar := closed[0]
+	if bar.Open != 100 || bar.High != 105 || bar.Low != 95 || bar.Close != 95 || bar.Source != CANDLE_SOURCE_MID {
+		t.Errorf("Unexpected bar %+v", bar)
+	}
+
+	minute, _ := cb.Candles(time.Minute, 0, true)
+	if len(minute) != 1 || minute[0].Close != 101 || minute[0].High != 105 {
+		t.Errorf("Unexpected current minute bar %+v", minute)
+	}
+	if _, err := cb.Candles(5*time.Minute, 0, false); err != ErrUnsupportedInterval {
+		t.Errorf("Expected an unsupported interval")
+	}
+}
+
+func TestCandlesPreferTrades(t *testing.T) {
+	cb := NewCandleBuilder("ETH-DAI", []time.Duration{time.Minute}, 2)
+	start := time.Unix(1602449400, 0)
+	cb.AddMid(start, 100)
+	cb.AddTrade(&Trade{Price: 102, Size: 1, Time: start.Add(time.Second)})
+	cb.AddMid(start.Add(2*time.Second), 150)
+	cb.AddTrade(&Trade{Price: 99, Size: 2, Time: start.Add(3 * time.Second)})
+
+	candles, _ := cb.Candles(time.Minute, 0, true)
+	bar := candles[0]
+	if bar.Source != CANDLE_SOURCE_TRADE || bar.Open != 102 || bar.High != 102 || bar.Low != 99 || bar.Close != 99 {
+		t.Errorf("Expected the bar to follow trades only, got %+v", bar)
+	}
+	if bar.Volume != 3 || bar.TradeCount != 2 {
+		t.Errorf("Expected a volume of 3 over 2 trades, got %+v", bar)
+	}
+
+	for minute := 1; minute < 5; minute++ {
+		cb.AddMid(start.Add(time.Duration(minute)*time.Minute), 100)
+	}
+	candles, _ = cb.Candles(time.Minute, 0, false)
+	if len(candles) != 2 || !candles[1].Start.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("Expected the 2 most recent closed bars, got %+v", candles)
+	}
+}
+
+func TestLateObservationsAreDropped(t *testing.T) {
+	cb := NewCandleBuilder("ETH-DAI", []time.Duration{time.Minute}, 10)
+	start := time.Unix(1602449400, 0)
+	cb.AddTrade(&Trade{Price: 100, Size: 1, Time: start})
+	cb.AddTrade(&Trade{Price: 101, Size: 1, Time: start.Add(time.Minute)})
+
+	// Both observations belong to the bar which already closed
+	cb.AddTrade(&Trade{Price: 50, Size: 5, Time: start.Add(30 * time.Second)})
+	cb.AddMid(start.Add(40*time.Second), 200)
+
+	candles, _ := cb.Candles(time.Minute, 0, true)
+	if len(candles) != 2 {
+		t.Fatalf("Expected 2 bars, got %+v", candles)
+	}
+	if closed := candles[0]; closed.Close != 100 || closed.Volume != 1 {
+		t.Errorf("Closed bar should not change, got %+v", closed)
+	}
+	if current := candles[1]; current.Low != 101 || current.High != 101 || current.Volume != 1 || current.TradeCount != 1 {
+		t.Errorf("Late observations should not reach the current bar, got %+v", current)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"sync"
 	"time"
 
+	"pirosb3/real_feed/clock"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,6 +18,10 @@ const (
 	INSUFFICIENT_LIQUIDITY = "INSUFFICIENT_LIQUIDITY"
 	BIDS                   = "BIDS"
 	ASKS                   = "ASKS"
+	BUY_BASE               = "BUY_BASE"
+	BUY_QUOTE              = "BUY_QUOTE"
+	SELL_BASE              = "SELL_BASE"
+	SELL_QUOTE             = "SELL_QUOTE"
 )
 
 // OrderbookFeed is the primary struct responsible for storage and access of the bids and asks.
@@ -28,6 +34,13 @@ type OrderbookFeed struct {
 	lastEpochSeen            int64
 	updateLock               *sync.RWMutex
 	snapshotWasSet           bool
+	provisional              bool
+	version                  uint64
+	integrityErr             *IntegrityError
+	lastIntegrityScan        time.Time
+	reservations             *reservations
+	staleTimeout             int64
+	clock                    clock.Clock
 }
 
 // GetProduct returns the base and quote assets.
@@ -42,13 +55,41 @@ func (of *OrderbookFeed) GetProduct() (string, string) {
 // BuyQuote simulates a market buy of a certain amount. For example, in a
 // BTC-USD book, BuyQuote(usdAmount) will return btcToSell.
 func (of *OrderbookFeed) BuyQuote(amount float64) (float64, int64, error) {
-	return of.performMarketOperationOnQuote(amount, of.bids, of.bidsSizeMap)
+	return of.performMarketOperationOnQuote(amount, of.bids, of.bidsSizeMap, of.reservations.reserved(of.clock.Now(), BIDS))
 }
 
 // SellQuote simulates a market sell of a certain amount. For example, in a
 // BTC-USD book, SellQuote(usdAmount) will return btcToBuy.
 func (of *OrderbookFeed) SellQuote(amount float64) (float64, int64, error) {
-	return of.performMarketOperationOnQuote(amount, of.asks, of.asksSizeMap)
+	return of.performMarketOperationOnQuote(amount, of.asks, of.asksSizeMap, of.reservations.reserved(of.clock.Now(), ASKS))
+}
+
+// Quote runs one of the four quote functions, selected by `operation` (BUY_BASE, BUY_QUOTE,
+// SELL_BASE or SELL_QUOTE).
+func (of *OrderbookFeed) Quote(operation string, amount float64) (float64, int64, error) {
+	switch operation {
+	case BUY_BASE:
+		return of.BuyBase(amount)
+	case BUY_QUOTE:
+		return of.BuyQuote(amount)
+	case SELL_BASE:
+		return of.SellBase(amount)
+	case SELL_QUOTE:
+		return of.SellQuote(amount)
+	}
+	return -1, of.lastEpochSeen, errors.New("Unsupported operation: " + operation)
+}
+
+// SetStaleTimeout replaces how old, in seconds, the last update may be before the book is
+// considered stale. The default is TIMEOUT_STALE_BOOK.
+func (of *OrderbookFeed) SetStaleTimeout(seconds int64) {
+	of.staleTimeout = seconds
+}
+
+// SetClock replaces the clock used to decide whether the book is stale and when firm quotes
+// expire. This allows quoting a historical or replayed book in simulated time.
+func (of *OrderbookFeed) SetClock(clock clock.Clock) {
+	of.clock = clock
 }
 
 // CleanUpOrderbook performs housekeeping on the books, by merging and removing
@@ -75,15 +116,36 @@ func (of *OrderbookFeed) CleanUpOrderbook() {
 	of.asks = newAsks
 }
 
-func (of *OrderbookFeed) performMarketOperationOnQuote(amount float64, book sortByOrderbookPrice, sizeMap map[string]float64) (float64, int64, error) {
+// checkQuotable returns an error if the orderbook cannot be used to quote `amount`.
+func (of *OrderbookFeed) checkQuotable(amount float64) error {
+	if err := of.Ready(); err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+// Ready returns an error if the orderbook cannot currently be quoted, because it never received
+// a snapshot, is stale or failed its integrity checks.
+func (of *OrderbookFeed) Ready() error {
 	if !of.snapshotWasSet {
-		return -1, of.lastEpochSeen, errors.New("A snapshot was never set, therefore the orderbook is inaccurate")
+		return ErrNoSnapshot
 	}
-	if (time.Now().Unix() - of.lastEpochSeen) > TIMEOUT_STALE_BOOK {
-		return -1, of.lastEpochSeen, errors.New("Orderbook is stale")
+	timeout := of.staleTimeout
+	if of.IsProvisional() {
+		timeout = TIMEOUT_PROVISIONAL_BOOK
 	}
-	if amount <= 0 {
-		return -1, of.lastEpochSeen, errors.New("Amount invalid")
+	if (of.clock.Now().Unix() - of.lastEpochSeen) > timeout {
+		return ErrStale
+	}
+	return of.IntegrityError()
+}
+
+func (of *OrderbookFeed) performMarketOperationOnQuote(amount float64, book sortByOrderbookPrice, sizeMap map[string]float64, reserved map[string]float64) (float64, int64, error) {
+	if err := of.checkQuotable(amount); err != nil {
+		return -1, of.lastEpochSeen, err
 	}
 
 	remaining := amount
@@ -95,7 +157,8 @@ func (of *OrderbookFeed) performMarketOperationOnQuote(amount float64, book sort
 		}
 
 		of.updateLock.RLock()
-		size, ok := sizeMap[orderSet.Key]
+		_, ok := sizeMap[orderSet.Key]
+		size := availableSize(sizeMap, reserved, orderSet.Key)
 		of.updateLock.RUnlock()
 		if !ok {
 			log.WithField("key", orderSet.Key).Errorln("Key cannot be found in lookup table.")
@@ -115,37 +178,31 @@ func (of *OrderbookFeed) performMarketOperationOnQuote(amount float64, book sort
 		return baseAmountToPay, of.lastEpochSeen, nil
 	}
 
-	return -1, of.lastEpochSeen, errors.New(INSUFFICIENT_LIQUIDITY)
+	return -1, of.lastEpochSeen, &LiquidityError{Requested: amount, Available: amount - remaining}
 }
 
 // BuyBase simulates a market buy of a certain amount. For example, in a
 // BTC-USD book, BuyBase(btcToBuy) will return usdSold.
 func (of *OrderbookFeed) BuyBase(amount float64) (float64, int64, error) {
-	return of.performMarketOperationOnBase(amount, of.asks, of.asksSizeMap)
+	return of.performMarketOperationOnBase(amount, of.asks, of.asksSizeMap, of.reservations.reserved(of.clock.Now(), ASKS))
 }
 
 // SellBase simulates a market buy of a certain amount. For example, in a
 // BTC-USD book, SellBase(btcToSell) will return usdPurchased.
 func (of *OrderbookFeed) SellBase(amount float64) (float64, int64, error) {
-	return of.performMarketOperationOnBase(amount, of.bids, of.bidsSizeMap)
+	return of.performMarketOperationOnBase(amount, of.bids, of.bidsSizeMap, of.reservations.reserved(of.clock.Now(), BIDS))
 }
 
-func (of *OrderbookFeed) performMarketOperationOnBase(amount float64, book sortByOrderbookPrice, sizeMap map[string]float64) (float64, int64, error) {
-	if !of.snapshotWasSet {
-		return -1, of.lastEpochSeen, errors.New("A snapshot was never set, therefore the orderbook is inaccurate")
-	}
-	if (time.Now().Unix() - of.lastEpochSeen) > TIMEOUT_STALE_BOOK {
-		return -1, of.lastEpochSeen, errors.New("Orderbook is stale")
-	}
-	if amount <= 0 {
-		return -1, of.lastEpochSeen, errors.New("Amount invalid")
+func (of *OrderbookFeed) performMarketOperationOnBase(amount float64, book sortByOrderbookPrice, sizeMap map[string]float64, reserved map[string]float64) (float64, int64, error) {
+	if err := of.checkQuotable(amount); err != nil {
+		return -1, of.lastEpochSeen, err
 	}
 	remainingAmt := amount
 	profitMade := 0.0
 	for _, orderSet := range book {
 
 		of.updateLock.RLock()
-		orderSize := sizeMap[orderSet.Key]
+		orderSize := availableSize(sizeMap, reserved, orderSet.Key)
 		of.updateLock.RUnlock()
 
 		amountToConsume := orderSize
@@ -167,7 +224,7 @@ func (of *OrderbookFeed) performMarketOperationOnBase(amount float64, book sortB
 	if remainingAmt == 0 {
 		return profitMade, of.lastEpochSeen, nil
 	}
-	return -1, of.lastEpochSeen, errors.New(INSUFFICIENT_LIQUIDITY)
+	return -1, of.lastEpochSeen, &LiquidityError{Requested: amount, Available: amount - remainingAmt}
 }
 
 func (of *OrderbookFeed) writeUpdate(updates []*Update, side string) bool {
@@ -214,6 +271,41 @@ func (of *OrderbookFeed) GetBookCount() (int, int) {
 	return len(of.bids), len(of.asks)
 }
 
+// BestBidAsk returns the best bid and best ask with a non-zero size. False is returned if either
+// side of the book is empty.
+func (of *OrderbookFeed) BestBidAsk() (float64, float64, bool) {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	return of.bestBidAskLocked()
+}
+
+// bestBidAskLocked is BestBidAsk for callers already holding the update lock.
+func (of *OrderbookFeed) bestBidAskLocked() (float64, float64, bool) {
+	bestBid, bestAsk := -1.0, -1.0
+	for _, bid := range of.bids {
+		if of.bidsSizeMap[bid.Key] > 0 {
+			bestBid = bid.Value
+			break
+		}
+	}
+	for _, ask := range of.asks {
+		if of.asksSizeMap[ask.Key] > 0 {
+			bestAsk = ask.Value
+			break
+		}
+	}
+	return bestBid, bestAsk, bestBid > 0 && bestAsk > 0
+}
+
+// MidPrice returns the price halfway between the best bid and the best ask.
+func (of *OrderbookFeed) MidPrice() (float64, bool) {
+	bestBid, bestAsk, ok := of.BestBidAsk()
+	if !ok {
+		return -1, false
+	}
+	return (bestBid + bestAsk) / 2, true
+}
+
 func (of *OrderbookFeed) setData(epoch int64, bids []*Update, asks []*Update, recreate bool) bool {
 	if epoch < of.lastEpochSeen {
 		log.WithField("lastEpochSeen", of.lastEpochSeen).WithField("newEpoch", epoch).Warningln("Skipping update due to race condition")
@@ -221,6 +313,9 @@ func (of *OrderbookFeed) setData(epoch int64, bids []*Update, asks []*Update, re
 	}
 	of.lastEpochSeen = epoch
 
+	of.updateLock.Lock()
+	defer of.updateLock.Unlock()
+
 	if recreate {
 		// Re-create all maps and structs
 		of.bids = nil
@@ -230,10 +325,8 @@ func (of *OrderbookFeed) setData(epoch int64, bids []*Update, asks []*Update, re
 	}
 
 	// Write a fresh batch of updates
-	of.updateLock.Lock()
 	containsNewInsertsBids := of.writeUpdate(bids, BIDS)
 	containsNewInsertsAsks := of.writeUpdate(asks, ASKS)
-	of.updateLock.Unlock()
 
 	// Sort the results after the update was written
 	if containsNewInsertsBids {
@@ -246,12 +339,24 @@ func (of *OrderbookFeed) setData(epoch int64, bids []*Update, asks []*Update, re
 			return of.asks[i].Value < of.asks[j].Value
 		})
 	}
+
+	of.version++
+
+	// Verify the book is still sane, quotes are refused until it is
+	of.integrityErr = of.verifyUpdate(recreate, bids, asks)
+	if of.integrityErr != nil {
+		integrityViolationsCounter.WithLabelValues(of.ProductID, of.integrityErr.Kind).Inc()
+		log.WithField("market", of.ProductID).WithField("err", of.integrityErr.Error()).Warningln("Orderbook is invalid")
+	}
 	return true
 }
 
 // SetSnapshot resets the orderbook with a new snapshot of bids and asks. This operation
 // is idempotent and clears out the old books.
 func (of *OrderbookFeed) SetSnapshot(epoch int64, bids []*Update, asks []*Update) bool {
+	if of.IsProvisional() {
+		return of.reconcile(epoch, bids, asks)
+	}
 	result := of.setData(epoch, bids, asks, true)
 	if result {
 		of.snapshotWasSet = true
@@ -273,5 +378,8 @@ func NewOrderbookFeed(ProductID string) *OrderbookFeed {
 		updateLock:    &sync.RWMutex{},
 		asksSizeMap:   make(map[string]float64),
 		bidsSizeMap:   make(map[string]float64),
+		reservations:  newReservations(),
+		staleTimeout:  TIMEOUT_STALE_BOOK,
+		clock:         clock.NewReal(),
 	}
 }
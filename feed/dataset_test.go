@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"testing"
 	"time"
+
+	"pirosb3/real_feed/clock"
 )
 
 func transformToUpdate(input [][]interface{}) []*Update {
@@ -191,10 +193,10 @@ func TestErrorWillReturnIfNoSnapshotWasEverSet(t *testing.T) {
 
 func TestEndToEnd(t *testing.T) {
 	response, err := http.Get(URL)
-	defer response.Body.Close()
 	if err != nil {
 		panic(err)
 	}
+	defer response.Body.Close()
 
 	var l2Data LevelTwoOrderbook
 	decoder := json.NewDecoder(response.Body)
@@ -248,8 +250,13 @@ func TestSellQuoteWhenBookIsOld(t *testing.T) {
 	asks := []*Update{
 		&Update{Price: "335.12", Size: "0.5"},
 	}
-	timestamp := time.Now().Unix() - 6
-	ob.SetSnapshot(timestamp, bids, asks)
+	manual := clock.NewManual(time.Now())
+	ob.SetClock(manual)
+	ob.SetSnapshot(manual.Now().Unix(), bids, asks)
+	if _, _, err := ob.SellQuote(50); err != nil {
+		t.Errorf("Orderbook is fresh but an error was raised: %s", err.Error())
+	}
+	manual.Advance((TIMEOUT_STALE_BOOK + 1) * time.Second)
 
 	_, _, err := ob.SellQuote(50)
 	if err == nil || err.Error() != "Orderbook is stale" {
@@ -0,0 +1,84 @@
+package feed
+
+const (
+	// DEFAULT_DEPTH_LEVELS is the number of levels per side returned when none is requested.
+	DEFAULT_DEPTH_LEVELS = 10
+	// MAX_DEPTH_LEVELS is the largest number of levels per side that may be requested.
+	MAX_DEPTH_LEVELS = 1000
+)
+
+type DepthLevel struct {
+	Price float64
+	Size  float64
+}
+
+// Depth is the top of both sides of the book, best level first.
+type Depth struct {
+	Bids        []DepthLevel
+	Asks        []DepthLevel
+	LastUpdated int64
+	Version     uint64
+}
+
+func topLevels(book sortByOrderbookPrice, sizeMap map[string]float64, levels int) []DepthLevel {
+	capacity := levels
+	if len(book) < capacity {
+		capacity = len(book)
+	}
+	result := make([]DepthLevel, 0, capacity)
+	for _, orderSet := range book {
+		if len(result) == levels {
+			break
+		}
+		if size := sizeMap[orderSet.Key]; size > 0 {
+			result = append(result, DepthLevel{Price: orderSet.Value, Size: size})
+		}
+	}
+	return result
+}
+
+// WalkLevels calls `visit` with the key, price and size of every non-empty level of `side` (BIDS
+// or ASKS), best first, until it returns false. The book is walked in place under its read lock,
+// so `visit` must not call back into the book.
+func (of *OrderbookFeed) WalkLevels(side string, visit func(key string, price float64, size float64) bool) error {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	if !of.snapshotWasSet {
+		return ErrNoSnapshot
+	}
+	book, sizeMap := of.asks, of.asksSizeMap
+	if side == BIDS {
+		book, sizeMap = of.bids, of.bidsSizeMap
+	}
+	for _, orderSet := range book {
+		if size := sizeMap[orderSet.Key]; size > 0 && !visit(orderSet.Key, orderSet.Value, size) {
+			break
+		}
+	}
+	return nil
+}
+
+// Depth returns the best `levels` non-empty levels of each side of the book, from a single
+// version of the book. DEFAULT_DEPTH_LEVELS are returned if `levels` is 0, and ErrInvalidAmount
+// if it is negative or above MAX_DEPTH_LEVELS.
+func (of *OrderbookFeed) Depth(levels int) (*Depth, error) {
+	if levels < 0 || levels > MAX_DEPTH_LEVELS {
+		return nil, ErrInvalidAmount
+	}
+	if levels == 0 {
+		levels = DEFAULT_DEPTH_LEVELS
+	}
+	if err := of.Ready(); err != nil {
+		return nil, err
+	}
+
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	return &Depth{
+		Bids:        topLevels(of.bids, of.bidsSizeMap, levels),
+		Asks:        topLevels(of.asks, of.asksSizeMap, levels),
+		LastUpdated: of.lastEpochSeen,
+		Version:     of.version,
+	}, nil
+}
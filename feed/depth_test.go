@@ -0,0 +1,45 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDepthSkipsEmptyLevels(t *testing.T) {
+	ob := newExactOutTestFeed()
+	ob.WriteUpdate(time.Now().Unix(), []*Update{
+		&Update{Price: "333.2", Size: "0"},
+	}, nil)
+
+	depth, err := ob.Depth(2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(depth.Bids) != 2 || depth.Bids[0].Price != 320 || depth.Bids[1].Price != 310 || depth.Bids[1].Size != 1.5 {
+		t.Errorf("Unexpected bids %v", depth.Bids)
+	}
+	if len(depth.Asks) != 2 || depth.Asks[0].Price != 335.12 {
+		t.Errorf("Unexpected asks %v", depth.Asks)
+	}
+	if depth.Version != ob.GetVersion() {
+		t.Errorf("Expected version %d, got %d", ob.GetVersion(), depth.Version)
+	}
+
+	if _, err := NewOrderbookFeed("ETH-DAI").Depth(1); err != ErrNoSnapshot {
+		t.Errorf("Expected no snapshot, got %v", err)
+	}
+}
+
+func TestDepthRejectsTooManyLevels(t *testing.T) {
+	ob := newExactOutTestFeed()
+	if _, err := ob.Depth(2147483647); err != ErrInvalidAmount {
+		t.Errorf("Expected an invalid amount, got %v", err)
+	}
+	depth, err := ob.Depth(MAX_DEPTH_LEVELS)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cap(depth.Bids) > len(ob.bids) {
+		t.Errorf("Expected the levels to be allocated for the book, got capacity %d", cap(depth.Bids))
+	}
+}
@@ -0,0 +1,46 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned when the book cannot answer a quote. They keep the messages clients matched on
+// before they were typed, compare them with errors.Is rather than by message.
+var (
+	ErrStale                 = errors.New("Orderbook is stale")
+	ErrNoSnapshot            = errors.New("A snapshot was never set, therefore the orderbook is inaccurate")
+	ErrInsufficientLiquidity = errors.New(INSUFFICIENT_LIQUIDITY)
+	ErrInvalidAmount         = errors.New("Amount invalid")
+	ErrUnknownProduct        = errors.New("Unknown product")
+)
+
+// LiquidityError reports how much of an amount the book could fill. It matches
+// ErrInsufficientLiquidity. Both amounts are in the unit of the request.
+type LiquidityError struct {
+	Requested float64
+	Available float64
+}
+
+func (le *LiquidityError) Error() string {
+	return INSUFFICIENT_LIQUIDITY
+}
+
+func (le *LiquidityError) Is(target error) bool {
+	return target == ErrInsufficientLiquidity
+}
+
+// ProductError is returned when a product other than the one of the book is requested. It
+// matches ErrUnknownProduct.
+type ProductError struct {
+	Requested string
+	Served    string
+}
+
+func (pe *ProductError) Error() string {
+	return fmt.Sprintf("Requested quote for feed '%s', but service is serving feed '%s'", pe.Requested, pe.Served)
+}
+
+func (pe *ProductError) Is(target error) bool {
+	return target == ErrUnknownProduct
+}
@@ -0,0 +1,36 @@
+package feed
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestErrorsMatchSentinels(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	if _, _, err := ob.BuyBase(1); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("Expected no snapshot, got %v", err)
+	}
+
+	ob = newExactOutTestFeed()
+	if _, _, err := ob.SellBase(0); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected an invalid amount, got %v", err)
+	}
+
+	_, _, err := ob.BuyBase(2)
+	var liquidityErr *LiquidityError
+	if !errors.Is(err, ErrInsufficientLiquidity) || !errors.As(err, &liquidityErr) {
+		t.Fatalf("Expected insufficient liquidity, got %v", err)
+	}
+	if err.Error() != INSUFFICIENT_LIQUIDITY {
+		t.Errorf("Expected the legacy message, got %s", err.Error())
+	}
+	if liquidityErr.Requested != 2 || math.Abs(liquidityErr.Available-1.5) > 1e-9 {
+		t.Errorf("Expected 1.5 of 2 to be available, got %f of %f", liquidityErr.Available, liquidityErr.Requested)
+	}
+
+	productErr := error(&ProductError{Requested: "BTC-USD", Served: "ETH-DAI"})
+	if !errors.Is(productErr, ErrUnknownProduct) {
+		t.Error("Expected a product error to match the unknown product")
+	}
+}
@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrLimitPriceReached = errors.New("Limit price reached before the amount could be filled")
+	ErrMaxInExceeded     = errors.New("Required input exceeds the maximum input amount")
+)
+
+// QuoteOptions controls fees and limits applied to exact-out quotes.
+type QuoteOptions struct {
+	// FeeBps is deducted from the output, so the output amount is what is received after fees.
+	FeeBps float64
+	// LimitPrice is the worst price level that may be consumed. Zero disables the limit.
+	LimitPrice float64
+	// MaxIn is the largest input that is acceptable. Zero disables the limit.
+	MaxIn float64
+}
+
+// walkForOutput consumes levels until `target` output was produced, returning the input needed.
+// Buying from the asks outputs base for quote, selling into the bids outputs quote for base.
+func walkForOutput(book sortByOrderbookPrice, sizeMap map[string]float64, reserved map[string]float64, target float64, isBuy bool, limitPrice float64) (float64, error) {
+	remaining := target
+	input := 0.0
+	for _, orderSet := range book {
+		if remaining <= 0 {
+			break
+		}
+		size := availableSize(sizeMap, reserved, orderSet.Key)
+		if size <= 0 {
+			continue
+		}
+		if limitPrice > 0 && ((isBuy && orderSet.Value > limitPrice) || (!isBuy && orderSet.Value < limitPrice)) {
+			return -1, ErrLimitPriceReached
+		}
+		if isBuy {
+			consumed := size
+			if consumed > remaining {
+				consumed = remaining
+			}
+			remaining -= consumed
+			input += consumed * orderSet.Value
+		} else {
+			notional := size * orderSet.Value
+			if notional > remaining {
+				notional = remaining
+			}
+			remaining -= notional
+			input += notional / orderSet.Value
+		}
+	}
+	if remaining > 0 {
+		return -1, &LiquidityError{Requested: target, Available: target - remaining}
+	}
+	return input, nil
+}
+
+func (of *OrderbookFeed) performExactOut(amountOut float64, isBuy bool, opts QuoteOptions) (float64, int64, error) {
+	if err := of.checkQuotable(amountOut); err != nil {
+		return -1, of.lastEpochSeen, err
+	}
+	if opts.FeeBps < 0 || opts.FeeBps >= 10000 || opts.LimitPrice < 0 || opts.MaxIn < 0 {
+		return -1, of.lastEpochSeen, fmt.Errorf("%w: fee must be in [0, 10000) bps, limit price and max input cannot be negative", ErrInvalidAmount)
+	}
+
+	bidsReserved := of.reservations.reserved(of.clock.Now(), BIDS)
+	asksReserved := of.reservations.reserved(of.clock.Now(), ASKS)
+
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	grossOut := amountOut / (1 - opts.FeeBps/10000)
+	var input float64
+	var err error
+	if isBuy {
+		input, err = walkForOutput(of.asks, of.asksSizeMap, asksReserved, grossOut, true, opts.LimitPrice)
+	} else {
+		input, err = walkForOutput(of.bids, of.bidsSizeMap, bidsReserved, grossOut, false, opts.LimitPrice)
+	}
+	if err != nil {
+		return -1, of.lastEpochSeen, err
+	}
+	if opts.MaxIn > 0 && input > opts.MaxIn {
+		return -1, of.lastEpochSeen, ErrMaxInExceeded
+	}
+	return input, of.lastEpochSeen, nil
+}
+
+// BuyBaseExactOut returns the quote currency to spend to receive exactly `baseOut` after fees.
+// For example, in a BTC-USD book, BuyBaseExactOut(btcToReceive) will return usdToSpend.
+func (of *OrderbookFeed) BuyBaseExactOut(baseOut float64, opts QuoteOptions) (float64, int64, error) {
+	return of.performExactOut(baseOut, true, opts)
+}
+
+// BuyQuoteExactOut returns the base currency to sell to receive exactly `quoteOut` after fees.
+// For example, in a BTC-USD book, BuyQuoteExactOut(usdToReceive) will return btcToSell.
+func (of *OrderbookFeed) BuyQuoteExactOut(quoteOut float64, opts QuoteOptions) (float64, int64, error) {
+	return of.performExactOut(quoteOut, false, opts)
+}
+
+// SellBaseExactOut is the inverse of SellBase: it returns the base currency to sell to net exactly
+// `quoteOut` after fees. For example, in a BTC-USD book, SellBaseExactOut(usdToNet) will return
+// btcToSell. Selling base and buying quote are the same trade, so it matches BuyQuoteExactOut.
+func (of *OrderbookFeed) SellBaseExactOut(quoteOut float64, opts QuoteOptions) (float64, int64, error) {
+	return of.BuyQuoteExactOut(quoteOut, opts)
+}
+
+// SellQuoteExactOut is the inverse of SellQuote: it returns the quote currency to sell to receive
+// exactly `baseOut` after fees. For example, in a BTC-USD book, SellQuoteExactOut(btcToReceive)
+// will return usdToSpend. Selling quote and buying base are the same trade, so it matches
+// BuyBaseExactOut.
+func (of *OrderbookFeed) SellQuoteExactOut(baseOut float64, opts QuoteOptions) (float64, int64, error) {
+	return of.BuyBaseExactOut(baseOut, opts)
+}
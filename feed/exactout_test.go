@@ -0,0 +1,88 @@
+package feed
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func newExactOutTestFeed() *OrderbookFeed {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333.2", Size: "0.5"},
+		&Update{Price: "320", Size: "0.5"},
+		&Update{Price: "310", Size: "1.5"},
+	}, []*Update{
+		&Update{Price: "335.12", Size: "0.5"},
+		&Update{Price: "340", Size: "1"},
+	})
+	return ob
+}
+
+func TestExactOutInvertsQuotes(t *testing.T) {
+	ob := newExactOutTestFeed()
+
+	// Selling 0.6 base nets 198.6 quote, so netting 198.6 quote needs 0.6 base
+	baseIn, _, err := ob.SellBaseExactOut(198.6, QuoteOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if math.Abs(baseIn-0.6) > 1e-9 {
+		t.Errorf("Expected 0.6 but got %f", baseIn)
+	}
+
+	// Spending 200 quote buys some base, receiving that base needs 200 quote
+	baseOut, _, _ := ob.SellQuote(200)
+	quoteIn, _, err := ob.SellQuoteExactOut(baseOut, QuoteOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if math.Abs(quoteIn-200) > 1e-9 {
+		t.Errorf("Expected 200 but got %f", quoteIn)
+	}
+
+	// Without fees the buy variants match the existing quote functions
+	expected, _, _ := ob.BuyBase(0.7)
+	result, _, _ := ob.BuyBaseExactOut(0.7, QuoteOptions{})
+	if result != expected {
+		t.Errorf("Expected %f but got %f", expected, result)
+	}
+	expected, _, _ = ob.BuyQuote(200)
+	result, _, _ = ob.BuyQuoteExactOut(200, QuoteOptions{})
+	if math.Abs(result-expected) > 1e-12 {
+		t.Errorf("Expected %f but got %f", expected, result)
+	}
+}
+
+func TestExactOutFeesAndLimits(t *testing.T) {
+	ob := newExactOutTestFeed()
+
+	// A 50% fee means 0.25 base must be bought to receive 0.125 base
+	withFee, _, err := ob.BuyBaseExactOut(0.125, QuoteOptions{FeeBps: 5000})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if withFee != 0.25*335.12 {
+		t.Errorf("Expected %f but got %f", 0.25*335.12, withFee)
+	}
+
+	if _, _, err := ob.BuyBaseExactOut(1, QuoteOptions{LimitPrice: 336}); err != ErrLimitPriceReached {
+		t.Errorf("Expected the limit price to be reached, got %v", err)
+	}
+	if _, _, err := ob.BuyBaseExactOut(0.5, QuoteOptions{LimitPrice: 336}); err != nil {
+		t.Errorf("Expected the order to fill within the limit, got %v", err)
+	}
+	if _, _, err := ob.SellBaseExactOut(300, QuoteOptions{LimitPrice: 325}); err != ErrLimitPriceReached {
+		t.Errorf("Expected the limit price to be reached, got %v", err)
+	}
+	if _, _, err := ob.BuyBaseExactOut(1, QuoteOptions{MaxIn: 100}); err != ErrMaxInExceeded {
+		t.Errorf("Expected the maximum input to be exceeded, got %v", err)
+	}
+	if _, _, err := ob.BuyBaseExactOut(10, QuoteOptions{}); err == nil || err.Error() != INSUFFICIENT_LIQUIDITY {
+		t.Errorf("Expected insufficient liquidity, got %v", err)
+	}
+	if _, _, err := ob.BuyBaseExactOut(1, QuoteOptions{FeeBps: 10000}); !errors.Is(err, ErrInvalidAmount) {
+		t.Error("Expected a 100% fee to be rejected")
+	}
+}
@@ -0,0 +1,264 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	QUOTE_OUTCOME_REQUESTED = "requested"
+	QUOTE_OUTCOME_ACCEPTED  = "accepted"
+	QUOTE_OUTCOME_REJECTED  = "rejected"
+	QUOTE_OUTCOME_EXPIRED   = "expired"
+
+	// MAX_FIRM_QUOTE_TTL bounds how long a single quote can hold liquidity away from other clients
+	MAX_FIRM_QUOTE_TTL = time.Minute
+)
+
+var (
+	ErrQuoteNotFound = errors.New("Quote cannot be found, it may have expired or been accepted already")
+	ErrQuoteExpired  = errors.New("Quote has expired")
+	ErrQuoteMoved    = errors.New("The orderbook no longer supports the quoted price")
+)
+
+var firmQuotesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name:      "firmQuotes",
+	Help:      "Counts firm quotes by outcome",
+	Namespace: "feed",
+}, []string{"market", "outcome"})
+
+// FirmQuote is a quote backed by liquidity reserved in the book until it expires or is accepted.
+type FirmQuote struct {
+	ID        string
+	ProductID string
+	Operation string
+	InAmount  float64
+	OutAmount float64
+	// Price is the average price of the quote, in quote currency per unit of base.
+	Price       float64
+	ExpiresAt   time.Time
+	LastUpdated int64
+
+	// Size reserved at every price level, by side
+	bids, asks map[string]float64
+}
+
+// reservations tracks the liquidity held by outstanding firm quotes.
+type reservations struct {
+	lock   sync.Mutex
+	quotes map[string]*FirmQuote
+}
+
+func newReservations() *reservations {
+	return &reservations{quotes: make(map[string]*FirmQuote)}
+}
+
+// expire drops the quotes that expired before `now`.
+func (r *reservations) expire(now time.Time) {
+	for id, quote := range r.quotes {
+		if !now.Before(quote.ExpiresAt) {
+			delete(r.quotes, id)
+			firmQuotesCounter.WithLabelValues(quote.ProductID, QUOTE_OUTCOME_EXPIRED).Inc()
+		}
+	}
+}
+
+// reserved returns the size held at every price level of a side by quotes still live at `now`.
+func (r *reservations) reserved(now time.Time, side string) map[string]float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.reservedLocked(now, side)
+}
+
+func (r *reservations) reservedLocked(now time.Time, side string) map[string]float64 {
+	r.expire(now)
+	result := make(map[string]float64)
+	for _, quote := range r.quotes {
+		levels := quote.asks
+		if side == BIDS {
+			levels = quote.bids
+		}
+		for key, size := range levels {
+			result[key] += size
+		}
+	}
+	return result
+}
+
+// availableSize returns the size at a price level that is not held by a firm quote.
+func availableSize(sizeMap map[string]float64, reserved map[string]float64, key string) float64 {
+	size := sizeMap[key] - reserved[key]
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// consumeLevels walks the book for one of the four operations, returning the amount on the
+// other side of the trade and the size taken from every level. The update lock must be held.
+func consumeLevels(book sortByOrderbookPrice, sizeMap map[string]float64, reserved map[string]float64, amount float64, amountIsBase bool) (float64, map[string]float64, error) {
+	consumed := make(map[string]float64)
+	remaining := amount
+	result := 0.0
+	for _, orderSet := range book {
+		if remaining <= 0 {
+			break
+		}
+		size := availableSize(sizeMap, reserved, orderSet.Key)
+		if size <= 0 {
+			continue
+		}
+		var taken float64
+		if amountIsBase {
+			taken = size
+			if taken > remaining {
+				taken = remaining
+			}
+			remaining -= taken
+			result += taken * orderSet.Value
+		} else {
+			notional := size * orderSet.Value
+			if notional > remaining {
+				notional = remaining
+			}
+			remaining -= notional
+			taken = notional / orderSet.Value
+			result += taken
+		}
+		consumed[orderSet.Key] += taken
+	}
+	if remaining > 0 {
+		return -1, nil, &LiquidityError{Requested: amount, Available: amount - remaining}
+	}
+	return result, consumed, nil
+}
+
+// walkOperation runs one of the four operations against the liquidity not held by other quotes.
+func (of *OrderbookFeed) walkOperation(operation string, amount float64, bidsReserved map[string]float64, asksReserved map[string]float64) (float64, string, map[string]float64, error) {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	var result float64
+	var consumed map[string]float64
+	var err error
+	side := ASKS
+	switch operation {
+	case BUY_BASE:
+		result, consumed, err = consumeLevels(of.asks, of.asksSizeMap, asksReserved, amount, true)
+	case SELL_BASE:
+		side = BIDS
+		result, consumed, err = consumeLevels(of.bids, of.bidsSizeMap, bidsReserved, amount, true)
+	case BUY_QUOTE:
+		side = BIDS
+		result, consumed, err = consumeLevels(of.bids, of.bidsSizeMap, bidsReserved, amount, false)
+	case SELL_QUOTE:
+		result, consumed, err = consumeLevels(of.asks, of.asksSizeMap, asksReserved, amount, false)
+	default:
+		return -1, "", nil, errors.New("Unsupported operation: " + operation)
+	}
+	return result, side, consumed, err
+}
+
+// quotePrice returns the average price of an operation, in quote currency per unit of base.
+func quotePrice(operation string, inAmount float64, outAmount float64) float64 {
+	if operation == BUY_BASE || operation == SELL_BASE {
+		return outAmount / inAmount
+	}
+	return inAmount / outAmount
+}
+
+// RequestQuote prices one of the four operations and reserves the liquidity it would consume for
+// `ttl`, which cannot exceed MAX_FIRM_QUOTE_TTL. Reserved liquidity is deducted from every other
+// quote until the firm quote expires or is accepted.
+func (of *OrderbookFeed) RequestQuote(operation string, amount float64, ttl time.Duration) (*FirmQuote, error) {
+	if err := of.checkQuotable(amount); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 || ttl > MAX_FIRM_QUOTE_TTL {
+		return nil, fmt.Errorf("%w: quote TTL must be positive and at most %s", ErrInvalidAmount, MAX_FIRM_QUOTE_TTL)
+	}
+
+	// Pricing and reserving must be atomic, or two quotes could reserve the same liquidity
+	of.reservations.lock.Lock()
+	defer of.reservations.lock.Unlock()
+
+	now := of.clock.Now()
+	bidsReserved := of.reservations.reservedLocked(now, BIDS)
+	asksReserved := of.reservations.reservedLocked(now, ASKS)
+	result, side, consumed, err := of.walkOperation(operation, amount, bidsReserved, asksReserved)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &FirmQuote{
+		ID:          uuid.New().String(),
+		ProductID:   of.ProductID,
+		Operation:   operation,
+		InAmount:    amount,
+		OutAmount:   result,
+		Price:       quotePrice(operation, amount, result),
+		ExpiresAt:   now.Add(ttl),
+		LastUpdated: of.lastEpochSeen,
+	}
+	if side == BIDS {
+		quote.bids = consumed
+	} else {
+		quote.asks = consumed
+	}
+	of.reservations.quotes[quote.ID] = quote
+	firmQuotesCounter.WithLabelValues(of.ProductID, QUOTE_OUTCOME_REQUESTED).Inc()
+	return quote, nil
+}
+
+// AcceptQuote releases the liquidity held by a quote and succeeds only if the live book, net of
+// other reservations, still supports the quoted price within `toleranceBps`. The quote can no
+// longer be accepted afterwards, whatever the outcome.
+func (of *OrderbookFeed) AcceptQuote(id string, toleranceBps float64) (*FirmQuote, error) {
+	// The reservation is held until the outcome is known, or a concurrent quote could reserve
+	// the liquidity promised to this one
+	of.reservations.lock.Lock()
+	defer of.reservations.lock.Unlock()
+
+	quote, ok := of.reservations.quotes[id]
+	if !ok {
+		return nil, ErrQuoteNotFound
+	}
+	delete(of.reservations.quotes, id)
+
+	now := of.clock.Now()
+	if !now.Before(quote.ExpiresAt) {
+		firmQuotesCounter.WithLabelValues(of.ProductID, QUOTE_OUTCOME_EXPIRED).Inc()
+		return quote, ErrQuoteExpired
+	}
+	if err := of.checkQuotable(quote.InAmount); err != nil {
+		firmQuotesCounter.WithLabelValues(of.ProductID, QUOTE_OUTCOME_REJECTED).Inc()
+		return quote, err
+	}
+
+	bidsReserved := of.reservations.reservedLocked(now, BIDS)
+	asksReserved := of.reservations.reservedLocked(now, ASKS)
+	live, _, _, err := of.walkOperation(quote.Operation, quote.InAmount, bidsReserved, asksReserved)
+	if err != nil {
+		firmQuotesCounter.WithLabelValues(of.ProductID, QUOTE_OUTCOME_REJECTED).Inc()
+		return quote, err
+	}
+
+	// Buying base and buying quote return what the client pays, the other two what it receives
+	tolerance := quote.OutAmount * toleranceBps / 10000
+	moved := live < quote.OutAmount-tolerance
+	if quote.Operation == BUY_BASE || quote.Operation == BUY_QUOTE {
+		moved = live > quote.OutAmount+tolerance
+	}
+	if moved {
+		firmQuotesCounter.WithLabelValues(of.ProductID, QUOTE_OUTCOME_REJECTED).Inc()
+		return quote, ErrQuoteMoved
+	}
+	firmQuotesCounter.WithLabelValues(of.ProductID, QUOTE_OUTCOME_ACCEPTED).Inc()
+	return quote, nil
+}
@@ -0,0 +1,111 @@
+package feed
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/clock"
+)
+
+func TestFirmQuoteReservesLiquidity(t *testing.T) {
+	ob := newExactOutTestFeed()
+
+	quote, err := ob.RequestQuote(BUY_BASE, 0.5, time.Minute)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if quote.OutAmount != 0.5*335.12 || quote.Price != 335.12 {
+		t.Errorf("Unexpected quote %f at %f", quote.OutAmount, quote.Price)
+	}
+
+	// The best ask is held by the firm quote, so the next buyer walks to the next level
+	result, _, _ := ob.BuyBase(0.5)
+	if result != 0.5*340 {
+		t.Errorf("Expected %f but got %f", 0.5*340, result)
+	}
+	second, err := ob.RequestQuote(BUY_BASE, 0.5, time.Minute)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if second.Price != 340 {
+		t.Errorf("Expected the second quote at 340 but got %f", second.Price)
+	}
+
+	if _, err := ob.AcceptQuote(quote.ID, 0); err != nil {
+		t.Errorf("Expected the quote to be accepted, got %v", err)
+	}
+	if _, err := ob.AcceptQuote(quote.ID, 0); err != ErrQuoteNotFound {
+		t.Errorf("Expected the quote to be consumed, got %v", err)
+	}
+	result, _, _ = ob.BuyBase(0.5)
+	if result != 0.5*335.12 {
+		t.Errorf("Expected the reservation to be released, got %f", result)
+	}
+}
+
+func TestFirmQuoteExpiryAndTolerance(t *testing.T) {
+	ob := newExactOutTestFeed()
+	now := time.Now()
+	manual := clock.NewManual(now)
+	ob.SetClock(manual)
+
+	expiring, _ := ob.RequestQuote(SELL_BASE, 0.5, time.Second)
+	manual.Advance(2 * time.Second)
+	now = manual.Now()
+	result, _, _ := ob.SellBase(0.5)
+	if result != 0.5*333.2 {
+		t.Errorf("Expected the reservation to expire, got %f", result)
+	}
+	if _, err := ob.AcceptQuote(expiring.ID, 0); err != ErrQuoteNotFound {
+		t.Errorf("Expected the expired quote to be dropped, got %v", err)
+	}
+
+	quote, _ := ob.RequestQuote(SELL_BASE, 0.5, time.Minute)
+	ob.WriteUpdate(now.Unix(), []*Update{&Update{Price: "333.2", Size: "0"}}, nil)
+	if _, err := ob.AcceptQuote(quote.ID, 10); err != ErrQuoteMoved {
+		t.Errorf("Expected the quote to be rejected, got %v", err)
+	}
+
+	quote, _ = ob.RequestQuote(SELL_BASE, 0.5, time.Minute)
+	ob.WriteUpdate(now.Unix(), []*Update{&Update{Price: "319.9", Size: "1"}}, nil)
+	ob.WriteUpdate(now.Unix(), []*Update{&Update{Price: "320", Size: "0"}}, nil)
+	if _, err := ob.AcceptQuote(quote.ID, 10); err != nil {
+		t.Errorf("Expected a move within tolerance to be accepted, got %v", err)
+	}
+}
+
+func TestFirmQuoteReservationsApplyToCurvesAndProfiles(t *testing.T) {
+	ob := newExactOutTestFeed()
+	if _, err := ob.RequestQuote(BUY_BASE, 0.5, time.Minute); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	curve, err := ob.ImpactCurve(BUY, []float64{0.5, 1.5})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if curve.Points[0].AveragePrice != 340 || curve.Points[1].Filled {
+		t.Errorf("Expected the reserved ask to be skipped, got %+v", curve.Points)
+	}
+
+	profile, err := ob.LiquidityProfile([]float64{50}, []float64{0.5})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if profile.Slippage[0].BuyPrice != 340 || profile.Bands[0].AskSize != 0 {
+		t.Errorf("Expected the reserved ask to be excluded, got %+v %+v", profile.Slippage[0], profile.Bands[0])
+	}
+}
+
+func TestFirmQuoteTTLIsBounded(t *testing.T) {
+	ob := newExactOutTestFeed()
+	for _, ttl := range []time.Duration{0, -time.Second, MAX_FIRM_QUOTE_TTL + time.Millisecond} {
+		if _, err := ob.RequestQuote(BUY_BASE, 0.5, ttl); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Expected a TTL of %s to be invalid, got %v", ttl, err)
+		}
+	}
+	if _, err := ob.RequestQuote(BUY_BASE, 0.5, MAX_FIRM_QUOTE_TTL); err != nil {
+		t.Errorf("Expected the maximum TTL to be accepted, got %v", err)
+	}
+}
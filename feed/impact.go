@@ -0,0 +1,128 @@
+package feed
+
+import (
+	"errors"
+	"sort"
+)
+
+type ImpactPoint struct {
+	Amount       float64
+	Filled       bool
+	Notional     float64
+	AveragePrice float64
+	// ImpactBps is how much worse than mid the average price is, in basis points.
+	ImpactBps float64
+}
+
+type ImpactCurve struct {
+	ProductID   string
+	Side        string
+	Mid         float64
+	Points      []ImpactPoint
+	LastUpdated int64
+	Version     uint64
+}
+
+// impactCurve walks the book once, net of the `reserved` liquidity, recording the notional
+// exchanged as the cumulative size crosses each of `amounts`. The update lock must be held.
+func impactCurve(book sortByOrderbookPrice, sizeMap map[string]float64, reserved map[string]float64, amounts []float64, mid float64, isBuy bool) []ImpactPoint {
+	order := make([]int, len(amounts))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return amounts[order[i]] < amounts[order[j]]
+	})
+
+	points := make([]ImpactPoint, len(amounts))
+	for idx, amount := range amounts {
+		points[idx].Amount = amount
+	}
+
+	next := 0
+	filled, notional := 0.0, 0.0
+	for _, orderSet := range book {
+		size := availableSize(sizeMap, reserved, orderSet.Key)
+		if size <= 0 {
+			continue
+		}
+		for size > 0 && next < len(order) {
+			target := amounts[order[next]]
+			if filled+size < target {
+				filled += size
+				notional += size * orderSet.Value
+				size = 0
+				break
+			}
+			consumed := target - filled
+			filled = target
+			notional += consumed * orderSet.Value
+			size -= consumed
+
+			point := &points[order[next]]
+			if target > 0 {
+				point.Filled = true
+				point.Notional = notional
+				point.AveragePrice = notional / target
+				if isBuy {
+					point.ImpactBps = (point.AveragePrice - mid) / mid * 10000
+				} else {
+					point.ImpactBps = (mid - point.AveragePrice) / mid * 10000
+				}
+			}
+			next++
+		}
+		if next >= len(order) {
+			break
+		}
+	}
+	return points
+}
+
+// ImpactCurve computes the average price and the impact from mid of a market order of each of
+// `amounts`, in base currency, on `side` (BUY or SELL). All the points are computed against the
+// same version of the book, net of the liquidity reserved by firm quotes. Amounts must be
+// positive, and at least one must be given. Amounts the book cannot fill are returned with Filled
+// set to false.
+func (of *OrderbookFeed) ImpactCurve(side string, amounts []float64) (*ImpactCurve, error) {
+	if side != BUY && side != SELL {
+		return nil, errors.New("Unsupported side: " + side)
+	}
+	if err := of.checkQuotable(1); err != nil {
+		return nil, err
+	}
+	if len(amounts) == 0 {
+		return nil, ErrInvalidAmount
+	}
+	for _, amount := range amounts {
+		if amount <= 0 {
+			return nil, ErrInvalidAmount
+		}
+	}
+
+	now := of.clock.Now()
+	bidsReserved, asksReserved := of.reservations.reserved(now, BIDS), of.reservations.reserved(now, ASKS)
+
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	// The mid is taken under the same lock as the levels, so that both describe the same book
+	bestBid, bestAsk, ok := of.bestBidAskLocked()
+	if !ok {
+		return nil, ErrInsufficientLiquidity
+	}
+	mid := (bestBid + bestAsk) / 2
+	curve := &ImpactCurve{
+		ProductID:   of.ProductID,
+		Side:        side,
+		Mid:         mid,
+		LastUpdated: of.lastEpochSeen,
+		Version:     of.version,
+	}
+	if side == BUY {
+		curve.Points = impactCurve(of.asks, of.asksSizeMap, asksReserved, amounts, mid, true)
+	} else {
+		curve.Points = impactCurve(of.bids, of.bidsSizeMap, bidsReserved, amounts, mid, false)
+	}
+	return curve, nil
+}
@@ -0,0 +1,67 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImpactCurveMatchesQuotes(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333.2", Size: "0.5"},
+		&Update{Price: "320", Size: "0.5"},
+		&Update{Price: "310", Size: "1.5"},
+	}, []*Update{
+		&Update{Price: "335.12", Size: "0.5"},
+		&Update{Price: "340", Size: "1"},
+	})
+
+	amounts := []float64{2.5, 0.6, 0.2, 3}
+	curve, err := ob.ImpactCurve(SELL, amounts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for idx, amount := range amounts {
+		expected, _, err := ob.SellBase(amount)
+		point := curve.Points[idx]
+		if point.Amount != amount {
+			t.Errorf("Points should be returned in request order")
+		}
+		if err != nil {
+			if point.Filled {
+				t.Errorf("Expected %f not to be filled", amount)
+			}
+			continue
+		}
+		if !point.Filled || point.Notional != expected || point.AveragePrice != expected/amount {
+			t.Errorf("Expected notional %f for %f, got %+v", expected, amount, point)
+		}
+		if point.ImpactBps <= 0 {
+			t.Errorf("Expected a positive impact, got %f", point.ImpactBps)
+		}
+	}
+
+	curve, _ = ob.ImpactCurve(BUY, []float64{0.2, 1})
+	expected, _, _ := ob.BuyBase(1)
+	if curve.Points[1].Notional != expected || curve.Points[0].AveragePrice != 335.12 {
+		t.Errorf("Unexpected buy curve %+v", curve.Points)
+	}
+}
+
+func TestImpactCurveRejectsInvalidInput(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333.2", Size: "0.5"},
+	}, []*Update{
+		&Update{Price: "335.12", Size: "0.5"},
+	})
+	if _, err := ob.ImpactCurve("HOLD", []float64{1}); err == nil {
+		t.Error("Expected an unsupported side")
+	}
+	if _, err := ob.ImpactCurve(BUY, []float64{1, -1}); err == nil {
+		t.Error("Expected an invalid amount")
+	}
+	if _, err := ob.ImpactCurve(SELL, nil); err != ErrInvalidAmount {
+		t.Errorf("Expected at least one amount to be required, got %v", err)
+	}
+}
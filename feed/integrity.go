@@ -0,0 +1,148 @@
+package feed
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	VIOLATION_CROSSED       = "crossed"
+	VIOLATION_LOCKED        = "locked"
+	VIOLATION_ORPHANED_KEY  = "orphaned_key"
+	VIOLATION_NAN_SIZE      = "nan_size"
+	VIOLATION_NEGATIVE_SIZE = "negative_size"
+
+	// INTEGRITY_SCAN_INTERVAL spaces out the scans of every level of the book between snapshots.
+	// Updates in between only check the levels they touched and the top of the book.
+	INTEGRITY_SCAN_INTERVAL = 10 * time.Second
+)
+
+var integrityViolationsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name:      "integrityViolations",
+	Help:      "Counts book updates that left the orderbook in an invalid state",
+	Namespace: "feed",
+}, []string{"market", "kind"})
+
+// IntegrityError describes why an orderbook failed its integrity checks.
+type IntegrityError struct {
+	Kind   string
+	Detail string
+}
+
+func (ie *IntegrityError) Error() string {
+	return fmt.Sprintf("Orderbook failed integrity checks: %s (%s)", ie.Kind, ie.Detail)
+}
+
+func checkSize(key string, size float64) *IntegrityError {
+	if math.IsNaN(size) {
+		return &IntegrityError{Kind: VIOLATION_NAN_SIZE, Detail: key}
+	}
+	if size < 0 {
+		return &IntegrityError{Kind: VIOLATION_NEGATIVE_SIZE, Detail: key}
+	}
+	return nil
+}
+
+func checkSide(book sortByOrderbookPrice, sizeMap map[string]float64) *IntegrityError {
+	for _, orderSet := range book {
+		size, ok := sizeMap[orderSet.Key]
+		if !ok {
+			return &IntegrityError{Kind: VIOLATION_ORPHANED_KEY, Detail: orderSet.Key}
+		}
+		if err := checkSize(orderSet.Key, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUpdated verifies the sizes of the levels touched by `updates`. Updates that could not be
+// parsed were never written, so they are not checked.
+func checkUpdated(updates []*Update, sizeMap map[string]float64) *IntegrityError {
+	for _, update := range updates {
+		size, ok := sizeMap[update.Price]
+		if !ok {
+			continue
+		}
+		if err := checkSize(update.Price, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bestLevel returns the first level of a side with a positive size, or nil.
+func bestLevel(book sortByOrderbookPrice, sizeMap map[string]float64) *orderbookSortedKey {
+	for _, orderSet := range book {
+		if sizeMap[orderSet.Key] > 0 {
+			return orderSet
+		}
+	}
+	return nil
+}
+
+// checkTop verifies that the book is neither crossed nor locked.
+func (of *OrderbookFeed) checkTop() *IntegrityError {
+	bestBid := bestLevel(of.bids, of.bidsSizeMap)
+	bestAsk := bestLevel(of.asks, of.asksSizeMap)
+	if bestBid == nil || bestAsk == nil {
+		return nil
+	}
+	detail := fmt.Sprintf("best bid %s, best ask %s", bestBid.Key, bestAsk.Key)
+	if bestBid.Value > bestAsk.Value {
+		return &IntegrityError{Kind: VIOLATION_CROSSED, Detail: detail}
+	}
+	if bestBid.Value == bestAsk.Value {
+		return &IntegrityError{Kind: VIOLATION_LOCKED, Detail: detail}
+	}
+	return nil
+}
+
+// checkIntegrity verifies that every level has a valid size and that the book is not crossed
+// or locked. It must be called with the update lock held, after the books were sorted.
+func (of *OrderbookFeed) checkIntegrity() *IntegrityError {
+	if err := checkSide(of.bids, of.bidsSizeMap); err != nil {
+		return err
+	}
+	if err := checkSide(of.asks, of.asksSizeMap); err != nil {
+		return err
+	}
+	return of.checkTop()
+}
+
+// verifyUpdate checks the book after `bids` and `asks` were written. Every level is checked on
+// snapshots, while the book is invalid and every INTEGRITY_SCAN_INTERVAL, otherwise only the
+// levels the update touched and the top of the book are. The update lock must be held.
+func (of *OrderbookFeed) verifyUpdate(snapshot bool, bids []*Update, asks []*Update) *IntegrityError {
+	now := of.clock.Now()
+	if snapshot || of.integrityErr != nil || now.Sub(of.lastIntegrityScan) >= INTEGRITY_SCAN_INTERVAL {
+		of.lastIntegrityScan = now
+		return of.checkIntegrity()
+	}
+	if err := checkUpdated(bids, of.bidsSizeMap); err != nil {
+		return err
+	}
+	if err := checkUpdated(asks, of.asksSizeMap); err != nil {
+		return err
+	}
+	return of.checkTop()
+}
+
+// IsValid returns true when the last update left the orderbook in a consistent state.
+func (of *OrderbookFeed) IsValid() bool {
+	return of.IntegrityError() == nil
+}
+
+// IntegrityError returns the reason why the orderbook is invalid, or nil if it is valid.
+func (of *OrderbookFeed) IntegrityError() error {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	if of.integrityErr == nil {
+		return nil
+	}
+	return of.integrityErr
+}
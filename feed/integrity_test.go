@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/clock"
+)
+
+func TestCrossedBookRefusesQuotes(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333.2", Size: "0.5"},
+	}, []*Update{
+		&Update{Price: "335.12", Size: "0.5"},
+	})
+	if !ob.IsValid() {
+		t.Fatalf("Expected book to be valid, got %s", ob.IntegrityError().Error())
+	}
+
+	ob.WriteUpdate(time.Now().Unix(), []*Update{
+		&Update{Price: "336", Size: "1"},
+	}, []*Update{})
+	err, ok := ob.IntegrityError().(*IntegrityError)
+	if !ok || err.Kind != VIOLATION_CROSSED {
+		t.Fatalf("Expected a crossed book, got %v", ob.IntegrityError())
+	}
+	if _, _, err := ob.SellBase(0.1); err == nil {
+		t.Error("Quotes should be refused on a crossed book")
+	}
+
+	// Removing the offending bid heals the book
+	ob.WriteUpdate(time.Now().Unix(), []*Update{
+		&Update{Price: "336", Size: "0"},
+	}, []*Update{})
+	if !ob.IsValid() {
+		t.Errorf("Expected book to be valid again, got %s", ob.IntegrityError().Error())
+	}
+}
+
+func TestLockedBook(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "335", Size: "0.5"},
+	}, []*Update{
+		&Update{Price: "335", Size: "0.5"},
+	})
+	err, ok := ob.IntegrityError().(*IntegrityError)
+	if !ok || err.Kind != VIOLATION_LOCKED {
+		t.Errorf("Expected a locked book, got %v", ob.IntegrityError())
+	}
+}
+
+func TestInvalidSizes(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333", Size: "NaN"},
+	}, []*Update{})
+	err, ok := ob.IntegrityError().(*IntegrityError)
+	if !ok || err.Kind != VIOLATION_NAN_SIZE {
+		t.Errorf("Expected a NaN size, got %v", ob.IntegrityError())
+	}
+
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333", Size: "-1"},
+	}, []*Update{})
+	err, ok = ob.IntegrityError().(*IntegrityError)
+	if !ok || err.Kind != VIOLATION_NEGATIVE_SIZE {
+		t.Errorf("Expected a negative size, got %v", ob.IntegrityError())
+	}
+}
+
+func TestOrphanedKey(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	manual := clock.NewManual(time.Now())
+	ob.SetClock(manual)
+	ob.SetSnapshot(manual.Now().Unix(), []*Update{
+		&Update{Price: "333", Size: "1"},
+	}, []*Update{})
+	delete(ob.bidsSizeMap, "333")
+
+	// Updates only check the levels they touch until the next full scan
+	ob.WriteUpdate(manual.Now().Unix(), []*Update{}, []*Update{})
+	if !ob.IsValid() {
+		t.Errorf("Expected untouched levels not to be scanned, got %v", ob.IntegrityError())
+	}
+	manual.Advance(INTEGRITY_SCAN_INTERVAL)
+	ob.WriteUpdate(manual.Now().Unix(), []*Update{}, []*Update{})
+	err, ok := ob.IntegrityError().(*IntegrityError)
+	if !ok || err.Kind != VIOLATION_ORPHANED_KEY {
+		t.Errorf("Expected an orphaned key, got %v", ob.IntegrityError())
+	}
+}
+
+func TestUpdatesCheckTheLevelsTheyTouch(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333", Size: "1"},
+	}, []*Update{
+		&Update{Price: "335", Size: "1"},
+	})
+	ob.WriteUpdate(time.Now().Unix(), []*Update{
+		&Update{Price: "330", Size: "NaN"},
+	}, []*Update{})
+	err, ok := ob.IntegrityError().(*IntegrityError)
+	if !ok || err.Kind != VIOLATION_NAN_SIZE {
+		t.Errorf("Expected a NaN size, got %v", ob.IntegrityError())
+	}
+
+	// An invalid book is scanned in full until it heals
+	ob.WriteUpdate(time.Now().Unix(), []*Update{
+		&Update{Price: "330", Size: "1"},
+	}, []*Update{})
+	if !ob.IsValid() {
+		t.Errorf("Expected the book to heal, got %v", ob.IntegrityError())
+	}
+}
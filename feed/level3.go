@@ -0,0 +1,341 @@
+package feed
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const MAX_PENDING_L3_MESSAGES = 10000
+
+var (
+	ErrNotSynced      = errors.New("Level 3 book is waiting for a snapshot")
+	ErrSequenceGap    = errors.New("Level 3 book missed a message and must be re-synced")
+	ErrOrderNotFound  = errors.New("Order cannot be found in the book")
+	ErrPendingOverrun = errors.New("Too many messages were buffered while waiting for a snapshot")
+)
+
+type l3Level struct {
+	key    string
+	value  float64
+	orders []*L3Order
+}
+
+func (level *l3Level) size() float64 {
+	total := 0.0
+	for _, order := range level.orders {
+		total += order.Size
+	}
+	return total
+}
+
+// OrderbookL3Feed keeps an order-by-order book fed from the Coinbase Pro "full" channel. Orders
+// are kept in arrival order within each price level, which allows estimating the queue position
+// of a resting order. The aggregated price levels can be fed into an OrderbookFeed to reuse the
+// existing quote functions.
+type OrderbookL3Feed struct {
+	ProductID            string
+	orders               map[string]*L3Order
+	bidLevels, askLevels map[string]*l3Level
+	sequence             int64
+	synced               bool
+	pending              []WebsocketMessage
+	updateLock           *sync.RWMutex
+}
+
+func (of *OrderbookL3Feed) levelsForSide(side string) map[string]*l3Level {
+	if side == BIDS {
+		return of.bidLevels
+	}
+	return of.askLevels
+}
+
+func bookSide(exchangeSide string) string {
+	if exchangeSide == "buy" {
+		return BIDS
+	}
+	return ASKS
+}
+
+func levelUpdate(levels map[string]*l3Level, price string) *Update {
+	size := 0.0
+	if level, ok := levels[price]; ok {
+		size = level.size()
+	}
+	return &Update{Price: price, Size: strconv.FormatFloat(size, 'f', -1, 64)}
+}
+
+func (of *OrderbookL3Feed) addOrder(order *L3Order) {
+	levels := of.levelsForSide(order.Side)
+	level, ok := levels[order.Price]
+	if !ok {
+		value, _ := strconv.ParseFloat(order.Price, 64)
+		level = &l3Level{key: order.Price, value: value}
+		levels[order.Price] = level
+	}
+	level.orders = append(level.orders, order)
+	of.orders[order.OrderID] = order
+}
+
+func (of *OrderbookL3Feed) removeOrder(order *L3Order) {
+	levels := of.levelsForSide(order.Side)
+	delete(of.orders, order.OrderID)
+	level, ok := levels[order.Price]
+	if !ok {
+		return
+	}
+	for idx, queued := range level.orders {
+		if queued.OrderID == order.OrderID {
+			level.orders = append(level.orders[:idx], level.orders[idx+1:]...)
+			break
+		}
+	}
+	if len(level.orders) == 0 {
+		delete(levels, order.Price)
+	}
+}
+
+// SetSnapshot resets the book with a level 3 snapshot. Messages buffered while waiting for the
+// snapshot are replayed on top of it, skipping those already included in the snapshot.
+func (of *OrderbookL3Feed) SetSnapshot(snapshot *L3SnapshotMessage, receivedAt time.Time) error {
+	of.updateLock.Lock()
+	defer of.updateLock.Unlock()
+
+	of.orders = make(map[string]*L3Order)
+	of.bidLevels = make(map[string]*l3Level)
+	of.askLevels = make(map[string]*l3Level)
+	for _, level := range snapshot.Bids {
+		size, _ := strconv.ParseFloat(level[1], 64)
+		of.addOrder(&L3Order{OrderID: level[2], Side: BIDS, Price: level[0], Size: size, OpenedAt: receivedAt})
+	}
+	for _, level := range snapshot.Asks {
+		size, _ := strconv.ParseFloat(level[1], 64)
+		of.addOrder(&L3Order{OrderID: level[2], Side: ASKS, Price: level[0], Size: size, OpenedAt: receivedAt})
+	}
+	of.sequence = snapshot.Sequence
+	of.synced = true
+
+	pending := of.pending
+	of.pending = nil
+	for _, msg := range pending {
+		if _, _, err := of.apply(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyMessage applies a full channel message to the book and returns the new aggregated size of
+// every price level it touched, ready to be passed to OrderbookFeed.WriteUpdate. Messages received
+// before the first snapshot are buffered and ErrNotSynced is returned. ErrSequenceGap is returned
+// when a message was missed, in which case a new snapshot must be set.
+func (of *OrderbookL3Feed) ApplyMessage(msg WebsocketMessage) ([]*Update, []*Update, error) {
+	of.updateLock.Lock()
+	defer of.updateLock.Unlock()
+
+	if !of.synced {
+		if len(of.pending) >= MAX_PENDING_L3_MESSAGES {
+			of.pending = nil
+			return nil, nil, ErrPendingOverrun
+		}
+		of.pending = append(of.pending, msg)
+		return nil, nil, ErrNotSynced
+	}
+	return of.apply(msg)
+}
+
+func messageSequence(msg WebsocketMessage) (int64, bool) {
+	switch typedMsg := msg.(type) {
+	case *FullChannelMessage:
+		return typedMsg.Sequence, true
+	case *MatchMessage:
+		return typedMsg.Sequence, true
+	}
+	return 0, false
+}
+
+func (of *OrderbookL3Feed) apply(msg WebsocketMessage) ([]*Update, []*Update, error) {
+	sequence, ok := messageSequence(msg)
+	if !ok || sequence <= of.sequence {
+		return nil, nil, nil
+	}
+	if sequence != of.sequence+1 {
+		of.synced = false
+		return nil, nil, ErrSequenceGap
+	}
+	of.sequence = sequence
+
+	var side, price string
+	switch typedMsg := msg.(type) {
+	case *FullChannelMessage:
+		side, price = bookSide(typedMsg.Side), typedMsg.Price
+		switch typedMsg.Type {
+		case "open":
+			size, _ := strconv.ParseFloat(typedMsg.RemainingSize, 64)
+			of.addOrder(&L3Order{
+				OrderID:  typedMsg.OrderID,
+				Side:     side,
+				Price:    price,
+				Size:     size,
+				OpenedAt: typedMsg.Time,
+			})
+		case "done":
+			order, ok := of.orders[typedMsg.OrderID]
+			if !ok {
+				return nil, nil, nil
+			}
+			of.removeOrder(order)
+		case "change":
+			order, ok := of.orders[typedMsg.OrderID]
+			if !ok || typedMsg.NewSize == "" {
+				return nil, nil, nil
+			}
+			order.Size, _ = strconv.ParseFloat(typedMsg.NewSize, 64)
+			price = order.Price
+		default:
+			// "received" and "activate" do not affect the visible book
+			return nil, nil, nil
+		}
+	case *MatchMessage:
+		order, ok := of.orders[typedMsg.MakerOrderID]
+		if !ok {
+			return nil, nil, nil
+		}
+		size, _ := strconv.ParseFloat(typedMsg.Size, 64)
+		order.Size -= size
+		if order.Size <= 0 {
+			order.Size = 0
+		}
+		side, price = order.Side, order.Price
+	}
+
+	update := levelUpdate(of.levelsForSide(side), price)
+	if side == BIDS {
+		return []*Update{update}, nil, nil
+	}
+	return nil, []*Update{update}, nil
+}
+
+func sortedLevels(levels map[string]*l3Level, descending bool) []*l3Level {
+	result := make([]*l3Level, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, level)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if descending {
+			return result[i].value > result[j].value
+		}
+		return result[i].value < result[j].value
+	})
+	return result
+}
+
+// LevelTwo aggregates the orders into price levels, sorted from best to worst.
+func (of *OrderbookL3Feed) LevelTwo() ([]*Update, []*Update) {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	var bids, asks []*Update
+	for _, level := range sortedLevels(of.bidLevels, true) {
+		bids = append(bids, levelUpdate(of.bidLevels, level.key))
+	}
+	for _, level := range sortedLevels(of.askLevels, false) {
+		asks = append(asks, levelUpdate(of.askLevels, level.key))
+	}
+	return bids, asks
+}
+
+// LevelOrders returns a copy of the orders resting at a price level, in queue order.
+func (of *OrderbookL3Feed) LevelOrders(side string, price string) []*L3Order {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	level, ok := of.levelsForSide(side)[price]
+	if !ok {
+		return nil
+	}
+	result := make([]*L3Order, len(level.orders))
+	for idx, order := range level.orders {
+		orderCopy := *order
+		result[idx] = &orderCopy
+	}
+	return result
+}
+
+// QueuePosition returns how many orders, and how much size, are ahead of an order at its level.
+func (of *OrderbookL3Feed) QueuePosition(orderID string, now time.Time) (*QueuePosition, error) {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	order, ok := of.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	level := of.levelsForSide(order.Side)[order.Price]
+	position := &QueuePosition{
+		OrderID:   order.OrderID,
+		Side:      order.Side,
+		Price:     order.Price,
+		LevelSize: level.size(),
+		Age:       now.Sub(order.OpenedAt),
+	}
+	for _, queued := range level.orders {
+		if queued.OrderID == orderID {
+			break
+		}
+		position.OrdersAhead++
+		position.SizeAhead += queued.Size
+	}
+	return position, nil
+}
+
+// IsSynced returns true when a snapshot was set and no message was missed since.
+func (of *OrderbookL3Feed) IsSynced() bool {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	return of.synced
+}
+
+// Resync marks the book as out of sync. Messages are buffered until the next snapshot is set.
+func (of *OrderbookL3Feed) Resync() {
+	of.updateLock.Lock()
+	defer of.updateLock.Unlock()
+	of.synced = false
+}
+
+// GetSequence returns the sequence number of the last message applied to the book.
+func (of *OrderbookL3Feed) GetSequence() int64 {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	return of.sequence
+}
+
+// GetOrderCount returns the number of resting bids and asks.
+func (of *OrderbookL3Feed) GetOrderCount() (int, int) {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	bids, asks := 0, 0
+	for _, order := range of.orders {
+		if order.Side == BIDS {
+			bids++
+		} else {
+			asks++
+		}
+	}
+	return bids, asks
+}
+
+// NewOrderbookL3Feed creates a new, empty, level 3 book. It must receive a snapshot before it
+// starts applying messages.
+func NewOrderbookL3Feed(ProductID string) *OrderbookL3Feed {
+	return &OrderbookL3Feed{
+		ProductID:  ProductID,
+		orders:     make(map[string]*L3Order),
+		bidLevels:  make(map[string]*l3Level),
+		askLevels:  make(map[string]*l3Level),
+		updateLock: &sync.RWMutex{},
+	}
+}
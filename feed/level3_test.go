@@ -0,0 +1,106 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestL3Feed(t *testing.T) *OrderbookL3Feed {
+	ob := NewOrderbookL3Feed("ETH-DAI")
+	err := ob.SetSnapshot(&L3SnapshotMessage{
+		Sequence: 10,
+		Bids: [][]string{
+			{"333.2", "0.5", "b1"},
+			{"333.2", "1.5", "b2"},
+			{"320", "0.5", "b3"},
+		},
+		Asks: [][]string{
+			{"335.12", "0.5", "a1"},
+		},
+	}, time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return ob
+}
+
+func TestL3SnapshotAggregatesLevels(t *testing.T) {
+	ob := newTestL3Feed(t)
+	bids, asks := ob.LevelTwo()
+	if len(bids) != 2 || len(asks) != 1 {
+		t.Fatalf("Expected 2 bid levels and 1 ask level, got %d and %d", len(bids), len(asks))
+	}
+	if bids[0].Price != "333.2" || bids[0].Size != "2" {
+		t.Errorf("Unexpected best bid %+v", bids[0])
+	}
+
+	l2 := NewOrderbookFeed("ETH-DAI")
+	l2.SetSnapshot(time.Now().Unix(), bids, asks)
+	result, _, err := l2.SellBase(2.5)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if result != 826.4 {
+		t.Errorf("Expected 826.4 but got %f", result)
+	}
+}
+
+func TestL3MessagesUpdateLevels(t *testing.T) {
+	ob := newTestL3Feed(t)
+	now := time.Unix(1010, 0)
+
+	bids, _, err := ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "open"}, Sequence: 11, OrderID: "b4", Side: "buy", Price: "333.2", RemainingSize: "1", Time: now})
+	if err != nil || len(bids) != 1 || bids[0].Size != "3" {
+		t.Errorf("Expected open to grow the level to 3, got %+v (%v)", bids, err)
+	}
+	bids, _, err = ob.ApplyMessage(&MatchMessage{WebsocketType: WebsocketType{Type: "match"}, Sequence: 12, MakerOrderID: "b1", Size: "0.25", Price: "333.2", Side: "buy"})
+	if err != nil || bids[0].Size != "2.75" {
+		t.Errorf("Expected match to shrink the level to 2.75, got %+v (%v)", bids, err)
+	}
+	bids, _, err = ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "change"}, Sequence: 13, OrderID: "b2", Side: "buy", Price: "333.2", NewSize: "1", OldSize: "1.5"})
+	if err != nil || bids[0].Size != "2.25" {
+		t.Errorf("Expected change to shrink the level to 2.25, got %+v (%v)", bids, err)
+	}
+	_, asks, err := ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "done"}, Sequence: 14, OrderID: "a1", Side: "sell", Price: "335.12", Reason: "canceled"})
+	if err != nil || asks[0].Size != "0" {
+		t.Errorf("Expected done to empty the ask level, got %+v (%v)", asks, err)
+	}
+
+	position, err := ob.QueuePosition("b4", now.Add(5*time.Second))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if position.OrdersAhead != 2 || position.SizeAhead != 1.25 || position.Age != 5*time.Second {
+		t.Errorf("Unexpected queue position %+v", position)
+	}
+	if len(ob.LevelOrders(BIDS, "333.2")) != 3 {
+		t.Errorf("Expected 3 orders at 333.2")
+	}
+}
+
+func TestL3SequenceGap(t *testing.T) {
+	ob := newTestL3Feed(t)
+	_, _, err := ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "received"}, Sequence: 9, OrderID: "x", Side: "buy"})
+	if err != nil {
+		t.Errorf("Old messages should be ignored, got %s", err.Error())
+	}
+	_, _, err = ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "received"}, Sequence: 12, OrderID: "x", Side: "buy"})
+	if err != ErrSequenceGap || ob.IsSynced() {
+		t.Errorf("Expected a sequence gap")
+	}
+}
+
+func TestL3BuffersUntilSnapshot(t *testing.T) {
+	ob := NewOrderbookL3Feed("ETH-DAI")
+	_, _, err := ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "open"}, Sequence: 10, OrderID: "old", Side: "sell", Price: "340", RemainingSize: "1"})
+	if err != ErrNotSynced {
+		t.Errorf("Expected ErrNotSynced")
+	}
+	ob.ApplyMessage(&FullChannelMessage{WebsocketType: WebsocketType{Type: "open"}, Sequence: 11, OrderID: "new", Side: "sell", Price: "341", RemainingSize: "1"})
+	ob.SetSnapshot(&L3SnapshotMessage{Sequence: 10, Asks: [][]string{{"340", "1", "old"}}}, time.Now())
+
+	_, asks := ob.LevelTwo()
+	if len(asks) != 2 || ob.GetSequence() != 11 {
+		t.Errorf("Expected the buffered message to be replayed, got %d asks at sequence %d", len(asks), ob.GetSequence())
+	}
+}
@@ -0,0 +1,122 @@
+package feed
+
+// DEFAULT_DEPTH_BANDS_BPS are the distances from mid, in basis points, depth is reported for.
+var DEFAULT_DEPTH_BANDS_BPS = []float64{10, 25, 50, 100}
+
+// DEFAULT_SLIPPAGE_SIZES are the sizes, in base currency, the slippage curve is computed for.
+var DEFAULT_SLIPPAGE_SIZES = []float64{1, 10, 50, 100}
+
+type DepthBand struct {
+	Bps         float64
+	BidSize     float64
+	AskSize     float64
+	BidNotional float64
+	AskNotional float64
+	// Imbalance is (bid - ask) / (bid + ask) notional, between -1 (only asks) and 1 (only bids).
+	Imbalance float64
+}
+
+type SlippagePoint struct {
+	Size            float64
+	BuyFilled       bool
+	BuyPrice        float64
+	BuySlippageBps  float64
+	SellFilled      bool
+	SellPrice       float64
+	SellSlippageBps float64
+}
+
+type LiquidityProfile struct {
+	ProductID   string
+	BestBid     float64
+	BestAsk     float64
+	Mid         float64
+	SpreadBps   float64
+	Bands       []DepthBand
+	Slippage    []SlippagePoint
+	LastUpdated int64
+}
+
+func depthWithin(book sortByOrderbookPrice, sizeMap map[string]float64, reserved map[string]float64, inBand func(float64) bool) (float64, float64) {
+	size, notional := 0.0, 0.0
+	for _, orderSet := range book {
+		if !inBand(orderSet.Value) {
+			break
+		}
+		levelSize := availableSize(sizeMap, reserved, orderSet.Key)
+		if levelSize <= 0 {
+			continue
+		}
+		size += levelSize
+		notional += levelSize * orderSet.Value
+	}
+	return size, notional
+}
+
+// LiquidityProfile computes, from a single consistent view of the book, the cumulative depth within
+// each of `bandsBps` basis points of mid and the slippage of market orders of each of `sizes`.
+// Bands and sizes must be positive. Liquidity reserved by firm quotes is excluded from both.
+func (of *OrderbookFeed) LiquidityProfile(bandsBps []float64, sizes []float64) (*LiquidityProfile, error) {
+	if err := of.checkQuotable(1); err != nil {
+		return nil, err
+	}
+	for _, bps := range bandsBps {
+		if bps <= 0 {
+			return nil, ErrInvalidAmount
+		}
+	}
+	for _, size := range sizes {
+		if size <= 0 {
+			return nil, ErrInvalidAmount
+		}
+	}
+
+	now := of.clock.Now()
+	bidsReserved, asksReserved := of.reservations.reserved(now, BIDS), of.reservations.reserved(now, ASKS)
+
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	// The mid is taken under the same lock as the levels, so that both describe the same book
+	bestBid, bestAsk, ok := of.bestBidAskLocked()
+	if !ok {
+		return nil, ErrInsufficientLiquidity
+	}
+	mid := (bestBid + bestAsk) / 2
+	profile := &LiquidityProfile{
+		ProductID:   of.ProductID,
+		BestBid:     bestBid,
+		BestAsk:     bestAsk,
+		Mid:         mid,
+		SpreadBps:   (bestAsk - bestBid) / mid * 10000,
+		Bands:       make([]DepthBand, len(bandsBps)),
+		Slippage:    make([]SlippagePoint, len(sizes)),
+		LastUpdated: of.lastEpochSeen,
+	}
+
+	for idx, bps := range bandsBps {
+		lower, upper := mid*(1-bps/10000), mid*(1+bps/10000)
+		band := DepthBand{Bps: bps}
+		band.BidSize, band.BidNotional = depthWithin(of.bids, of.bidsSizeMap, bidsReserved, func(price float64) bool { return price >= lower })
+		band.AskSize, band.AskNotional = depthWithin(of.asks, of.asksSizeMap, asksReserved, func(price float64) bool { return price <= upper })
+		if total := band.BidNotional + band.AskNotional; total > 0 {
+			band.Imbalance = (band.BidNotional - band.AskNotional) / total
+		}
+		profile.Bands[idx] = band
+	}
+
+	buys := impactCurve(of.asks, of.asksSizeMap, asksReserved, sizes, mid, true)
+	sells := impactCurve(of.bids, of.bidsSizeMap, bidsReserved, sizes, mid, false)
+	for idx, size := range sizes {
+		profile.Slippage[idx] = SlippagePoint{
+			Size:            size,
+			BuyFilled:       buys[idx].Filled,
+			BuyPrice:        buys[idx].AveragePrice,
+			BuySlippageBps:  buys[idx].ImpactBps,
+			SellFilled:      sells[idx].Filled,
+			SellPrice:       sells[idx].AveragePrice,
+			SellSlippageBps: sells[idx].ImpactBps,
+		}
+	}
+	return profile, nil
+}
@@ -0,0 +1,73 @@
+package feed
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLiquidityProfile(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "99.95", Size: "1"},
+		&Update{Price: "99.5", Size: "2"},
+		&Update{Price: "98", Size: "10"},
+	}, []*Update{
+		&Update{Price: "100.05", Size: "1"},
+		&Update{Price: "100.5", Size: "1"},
+	})
+
+	profile, err := ob.LiquidityProfile([]float64{10, 100}, []float64{2, 5})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if profile.Mid != 100 || math.Abs(profile.SpreadBps-10) > 1e-9 {
+		t.Errorf("Unexpected mid %f and spread %f", profile.Mid, profile.SpreadBps)
+	}
+
+	narrow := profile.Bands[0]
+	if narrow.BidSize != 1 || narrow.AskSize != 1 || math.Abs(narrow.Imbalance) > 1e-3 {
+		t.Errorf("Unexpected 10bps band %+v", narrow)
+	}
+	wide := profile.Bands[1]
+	if wide.BidSize != 3 || wide.AskSize != 2 || wide.Imbalance <= 0 {
+		t.Errorf("Unexpected 100bps band %+v", wide)
+	}
+
+	small := profile.Slippage[0]
+	if !small.BuyFilled || small.BuyPrice != 100.275 || math.Abs(small.BuySlippageBps-27.5) > 1e-9 {
+		t.Errorf("Unexpected buy slippage %+v", small)
+	}
+	if !small.SellFilled || small.SellPrice != 99.725 {
+		t.Errorf("Unexpected sell slippage %+v", small)
+	}
+	large := profile.Slippage[1]
+	if large.BuyFilled || !large.SellFilled {
+		t.Errorf("Expected only the sell side to fill 5 units, got %+v", large)
+	}
+}
+
+func TestLiquidityProfileNeedsBothSides(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "99.95", Size: "1"},
+	}, []*Update{})
+	if _, err := ob.LiquidityProfile(DEFAULT_DEPTH_BANDS_BPS, DEFAULT_SLIPPAGE_SIZES); err == nil {
+		t.Error("Expected an error for a one-sided book")
+	}
+}
+
+func TestLiquidityProfileRejectsInvalidInput(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "99.95", Size: "1"},
+	}, []*Update{
+		&Update{Price: "100.05", Size: "1"},
+	})
+	if _, err := ob.LiquidityProfile([]float64{10, 0}, DEFAULT_SLIPPAGE_SIZES); err != ErrInvalidAmount {
+		t.Errorf("Expected a zero band to be rejected, got %v", err)
+	}
+	if _, err := ob.LiquidityProfile(DEFAULT_DEPTH_BANDS_BPS, []float64{1, -2}); err != ErrInvalidAmount {
+		t.Errorf("Expected a negative size to be rejected, got %v", err)
+	}
+}
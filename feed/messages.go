@@ -0,0 +1,211 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// GetType returns the message type as sent by the exchange, for example "l2update".
+func (wt WebsocketType) GetType() string {
+	return wt.Type
+}
+
+func validateLevel(price, size string) error {
+	parsedPrice, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid price '%s'", price)
+	}
+	if math.IsNaN(parsedPrice) || math.IsInf(parsedPrice, 0) || parsedPrice <= 0 {
+		return fmt.Errorf("Price out of range '%s'", price)
+	}
+	parsedSize, err := strconv.ParseFloat(size, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid size '%s'", size)
+	}
+	if math.IsNaN(parsedSize) || math.IsInf(parsedSize, 0) || parsedSize < 0 {
+		return fmt.Errorf("Size out of range '%s'", size)
+	}
+	return nil
+}
+
+func validateLevels(levels [][]string) error {
+	for _, level := range levels {
+		if len(level) != 2 {
+			return fmt.Errorf("Expected a [price, size] pair, got %d elements", len(level))
+		}
+		if err := validateLevel(level[0], level[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks that every level of the snapshot is a well-formed [price, size] pair.
+func (m *L2SnapshotMessage) Validate() error {
+	if m.ProductID == "" {
+		return errors.New("Snapshot is missing a product")
+	}
+	if err := validateLevels(m.Bids); err != nil {
+		return err
+	}
+	return validateLevels(m.Asks)
+}
+
+// Updates converts the snapshot levels into bids and asks ready to be applied to an OrderbookFeed.
+func (m *L2SnapshotMessage) Updates() ([]*Update, []*Update) {
+	bids := make([]*Update, len(m.Bids))
+	for idx, level := range m.Bids {
+		bids[idx] = &Update{Price: level[0], Size: level[1]}
+	}
+	asks := make([]*Update, len(m.Asks))
+	for idx, level := range m.Asks {
+		asks[idx] = &Update{Price: level[0], Size: level[1]}
+	}
+	return bids, asks
+}
+
+// Validate checks that every change is a well-formed [side, price, size] triple.
+func (m *L2UpdateMessage) Validate() error {
+	if m.ProductID == "" {
+		return errors.New("Update is missing a product")
+	}
+	if m.Time.IsZero() {
+		return errors.New("Update is missing a timestamp")
+	}
+	for _, change := range m.Changes {
+		if len(change) != 3 {
+			return fmt.Errorf("Expected a [side, price, size] triple, got %d elements", len(change))
+		}
+		if change[0] != "buy" && change[0] != "sell" {
+			return fmt.Errorf("Unsupported side '%s'", change[0])
+		}
+		if err := validateLevel(change[1], change[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Updates splits the changes into bids and asks ready to be applied to an OrderbookFeed.
+func (m *L2UpdateMessage) Updates() ([]*Update, []*Update) {
+	var bids, asks []*Update
+	for _, change := range m.Changes {
+		update := &Update{Price: change[1], Size: change[2]}
+		switch change[0] {
+		case "buy":
+			bids = append(bids, update)
+		case "sell":
+			asks = append(asks, update)
+		}
+	}
+	return bids, asks
+}
+
+// Validate checks that the match carries a usable price, size and maker side.
+func (m *MatchMessage) Validate() error {
+	if m.ProductID == "" {
+		return errors.New("Match is missing a product")
+	}
+	if m.Time.IsZero() {
+		return errors.New("Match is missing a timestamp")
+	}
+	if m.Side != "buy" && m.Side != "sell" {
+		return fmt.Errorf("Unsupported side '%s'", m.Side)
+	}
+	if err := validateLevel(m.Price, m.Size); err != nil {
+		return err
+	}
+	if size, _ := strconv.ParseFloat(m.Size, 64); size == 0 {
+		return errors.New("Match has no size")
+	}
+	return nil
+}
+
+// Trade converts the match into a Trade. Coinbase reports the maker side, so the aggressor
+// is the opposite side: a "sell" maker means the taker bought.
+func (m *MatchMessage) Trade() *Trade {
+	price, _ := strconv.ParseFloat(m.Price, 64)
+	size, _ := strconv.ParseFloat(m.Size, 64)
+	side := BUY
+	if m.Side == "buy" {
+		side = SELL
+	}
+	return &Trade{
+		TradeID:   m.TradeID,
+		ProductID: m.ProductID,
+		Price:     price,
+		Size:      size,
+		Side:      side,
+		Time:      m.Time,
+	}
+}
+
+func validateOptionalAmount(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) || parsed < 0 {
+		return fmt.Errorf("Invalid %s '%s'", name, value)
+	}
+	return nil
+}
+
+// Validate checks the fields shared by every full channel message, and any amount that is present.
+// Every message but activate must carry a sequence, and open messages a price and a remaining size.
+func (m *FullChannelMessage) Validate() error {
+	if m.ProductID == "" {
+		return errors.New("Message is missing a product")
+	}
+	// Activate messages are sent outside the sequence of the book
+	if m.Sequence <= 0 && m.Type != "activate" {
+		return errors.New("Message is missing a sequence")
+	}
+	if m.OrderID == "" {
+		return errors.New("Message is missing an order id")
+	}
+	if m.Side != "buy" && m.Side != "sell" {
+		return fmt.Errorf("Unsupported side '%s'", m.Side)
+	}
+	if m.Type == "open" {
+		// Open orders rest on the book, so they must have a price and a size
+		if err := validateLevel(m.Price, m.RemainingSize); err != nil {
+			return err
+		}
+	} else if m.Price != "" {
+		if err := validateLevel(m.Price, "0"); err != nil {
+			return err
+		}
+	}
+	for name, value := range map[string]string{
+		"size":           m.Size,
+		"remaining_size": m.RemainingSize,
+		"new_size":       m.NewSize,
+		"old_size":       m.OldSize,
+	} {
+		if err := validateOptionalAmount(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks that every level of the snapshot is a well-formed [price, size, order_id] triple.
+func (m *L3SnapshotMessage) Validate() error {
+	for _, levels := range [][][]string{m.Bids, m.Asks} {
+		for _, level := range levels {
+			if len(level) != 3 {
+				return fmt.Errorf("Expected a [price, size, order_id] triple, got %d elements", len(level))
+			}
+			if err := validateLevel(level[0], level[1]); err != nil {
+				return err
+			}
+			if level[2] == "" {
+				return errors.New("Snapshot order is missing an order id")
+			}
+		}
+	}
+	return nil
+}
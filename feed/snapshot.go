@@ -0,0 +1,174 @@
+package feed
+
+import (
+	"strconv"
+	"sync"
+
+	"pirosb3/real_feed/clock"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+// TIMEOUT_PROVISIONAL_BOOK is how old, in seconds, a restored book may be and still be quoted
+// while waiting for the first live snapshot.
+const TIMEOUT_PROVISIONAL_BOOK = 30
+
+var reconciledLevelsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name:      "reconciledLevels",
+	Help:      "Number of price levels that differed between the restored book and the first live snapshot",
+	Namespace: "feed",
+}, []string{"market", "side"})
+
+func snapshotSide(book sortByOrderbookPrice, sizeMap map[string]float64) []*Update {
+	result := make([]*Update, 0, len(book))
+	for _, orderSet := range book {
+		size := sizeMap[orderSet.Key]
+		if size <= 0 {
+			continue
+		}
+		result = append(result, &Update{Price: orderSet.Key, Size: strconv.FormatFloat(size, 'f', -1, 64)})
+	}
+	return result
+}
+
+func copySizeMap(sizeMap map[string]float64) map[string]float64 {
+	result := make(map[string]float64, len(sizeMap))
+	for key, size := range sizeMap {
+		if size > 0 {
+			result[key] = size
+		}
+	}
+	return result
+}
+
+func countChangedLevels(before, after map[string]float64) int {
+	changed := 0
+	for key, size := range after {
+		if size > 0 && before[key] != size {
+			changed++
+		}
+	}
+	for key := range before {
+		if after[key] <= 0 {
+			changed++
+		}
+	}
+	return changed
+}
+
+// Snapshot returns a copy of the non-empty levels of the book, alongside its version and the
+// epoch of the last update.
+func (of *OrderbookFeed) Snapshot() (*BookSnapshot, error) {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+
+	if !of.snapshotWasSet {
+		return nil, ErrNoSnapshot
+	}
+	return &BookSnapshot{
+		ProductID: of.ProductID,
+		Version:   of.version,
+		Epoch:     of.lastEpochSeen,
+		Timestamp: of.clock.Now(),
+		Bids:      snapshotSide(of.bids, of.bidsSizeMap),
+		Asks:      snapshotSide(of.asks, of.asksSizeMap),
+	}, nil
+}
+
+// RestoreSnapshot loads a previously persisted snapshot into an empty book. The book is marked as
+// provisional until the first live snapshot replaces it. Nothing is restored, and false is
+// returned, if the book already received live data.
+func (of *OrderbookFeed) RestoreSnapshot(snapshot *BookSnapshot) bool {
+	if snapshot.ProductID != of.ProductID || of.snapshotWasSet || of.lastEpochSeen >= 0 {
+		return false
+	}
+	if !of.setData(snapshot.Epoch, snapshot.Bids, snapshot.Asks, true) {
+		return false
+	}
+	of.updateLock.Lock()
+	defer of.updateLock.Unlock()
+	of.version = snapshot.Version
+	of.snapshotWasSet = true
+	of.provisional = true
+	return true
+}
+
+// IsProvisional returns true while the book holds restored data that was not yet confirmed by a
+// live snapshot.
+func (of *OrderbookFeed) IsProvisional() bool {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	return of.provisional
+}
+
+// GetVersion returns a counter that is incremented every time the book changes.
+func (of *OrderbookFeed) GetVersion() uint64 {
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	return of.version
+}
+
+// Clone returns a copy of the book frozen at the current instant, with the liquidity held by firm
+// quotes at that instant. Quotes against the copy are consistent with each other, whatever
+// updates the book receives afterwards.
+func (of *OrderbookFeed) Clone() *OrderbookFeed {
+	now := of.clock.Now()
+	held := newReservations()
+	of.reservations.lock.Lock()
+	of.reservations.expire(now)
+	for id, quote := range of.reservations.quotes {
+		held.quotes[id] = quote
+	}
+	of.reservations.lock.Unlock()
+
+	of.updateLock.RLock()
+	defer of.updateLock.RUnlock()
+	clone := &OrderbookFeed{
+		ProductID:         of.ProductID,
+		bids:              append(sortByOrderbookPrice(nil), of.bids...),
+		asks:              append(sortByOrderbookPrice(nil), of.asks...),
+		bidsSizeMap:       make(map[string]float64, len(of.bidsSizeMap)),
+		asksSizeMap:       make(map[string]float64, len(of.asksSizeMap)),
+		lastEpochSeen:     of.lastEpochSeen,
+		updateLock:        &sync.RWMutex{},
+		snapshotWasSet:    of.snapshotWasSet,
+		provisional:       of.provisional,
+		version:           of.version,
+		integrityErr:      of.integrityErr,
+		lastIntegrityScan: of.lastIntegrityScan,
+		reservations:      held,
+		staleTimeout:      of.staleTimeout,
+		clock:             clock.NewManual(now),
+	}
+	for key, size := range of.bidsSizeMap {
+		clone.bidsSizeMap[key] = size
+	}
+	for key, size := range of.asksSizeMap {
+		clone.asksSizeMap[key] = size
+	}
+	return clone
+}
+
+// reconcile replaces a provisional book with live data, reporting how much the restored book
+// had drifted.
+func (of *OrderbookFeed) reconcile(epoch int64, bids []*Update, asks []*Update) bool {
+	of.updateLock.RLock()
+	oldBids, oldAsks := copySizeMap(of.bidsSizeMap), copySizeMap(of.asksSizeMap)
+	of.updateLock.RUnlock()
+
+	if !of.setData(epoch, bids, asks, true) {
+		return false
+	}
+
+	of.updateLock.Lock()
+	defer of.updateLock.Unlock()
+	of.provisional = false
+	changedBids := countChangedLevels(oldBids, copySizeMap(of.bidsSizeMap))
+	changedAsks := countChangedLevels(oldAsks, copySizeMap(of.asksSizeMap))
+	reconciledLevelsGauge.WithLabelValues(of.ProductID, "bids").Set(float64(changedBids))
+	reconciledLevelsGauge.WithLabelValues(of.ProductID, "asks").Set(float64(changedAsks))
+	log.WithField("market", of.ProductID).WithField("changedBids", changedBids).WithField("changedAsks", changedAsks).Infoln("Reconciled provisional orderbook with live data")
+	return true
+}
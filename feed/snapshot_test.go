@@ -0,0 +1,83 @@
+package feed
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRestoreSnapshotIsProvisional(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix()-10, []*Update{
+		&Update{Price: "333.2", Size: "0.5"},
+		&Update{Price: "320", Size: "0.5"},
+	}, []*Update{
+		&Update{Price: "335.12", Size: "0.5"},
+	})
+	snapshot, err := ob.Snapshot()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	restored := NewOrderbookFeed("ETH-DAI")
+	if !restored.RestoreSnapshot(snapshot) {
+		t.Fatal("Snapshot should have been restored")
+	}
+	if !restored.IsProvisional() || restored.GetVersion() != snapshot.Version {
+		t.Errorf("Expected a provisional book at version %d", snapshot.Version)
+	}
+
+	// A 10 second old provisional book can still be quoted
+	result, _, err := restored.SellBase(0.6)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result != 198.6 {
+		t.Errorf("Expected 198.6 but got %f", result)
+	}
+
+	// The first live snapshot replaces the provisional data
+	restored.SetSnapshot(time.Now().Unix(), []*Update{
+		&Update{Price: "333.2", Size: "1"},
+	}, []*Update{})
+	if restored.IsProvisional() {
+		t.Error("Book should no longer be provisional")
+	}
+	if restored.RestoreSnapshot(snapshot) {
+		t.Error("A live book should never be overwritten by a restored snapshot")
+	}
+}
+
+func TestSnapshotRequiresData(t *testing.T) {
+	ob := NewOrderbookFeed("ETH-DAI")
+	if _, err := ob.Snapshot(); err == nil {
+		t.Error("Expected an error before any snapshot was set")
+	}
+}
+
+func TestCloneIsNotAffectedByUpdates(t *testing.T) {
+	ob := newExactOutTestFeed()
+	if _, err := ob.RequestQuote(SELL_BASE, 0.5, time.Minute); err != nil {
+		t.Fatal(err.Error())
+	}
+	clone := ob.Clone()
+
+	ob.WriteUpdate(time.Now().Unix(), []*Update{
+		&Update{Price: "320", Size: "0"},
+	}, nil)
+	if clone.GetVersion() == ob.GetVersion() {
+		t.Error("Expected the clone to keep its version")
+	}
+
+	// The quote holds the 333.2 level, so selling 0.6 reaches 320 on the clone only
+	result, _, err := clone.SellBase(0.6)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if math.Abs(result-(0.5*320+0.1*310)) > 1e-9 {
+		t.Errorf("Expected 191 but got %f", result)
+	}
+	if result, _, _ := ob.SellBase(0.6); math.Abs(result-0.6*310) > 1e-9 {
+		t.Errorf("Expected the book to have moved, got %f", result)
+	}
+}
@@ -0,0 +1,137 @@
+package feed
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	BUY  = "BUY"
+	SELL = "SELL"
+)
+
+// TradeTape keeps a rolling, in-memory window of the most recent trades for a product. Trades
+// older than the largest configured window, or beyond `maxTrades`, are evicted as new ones arrive.
+type TradeTape struct {
+	ProductID   string
+	trades      []*Trade
+	windows     []time.Duration
+	maxAge      time.Duration
+	maxTrades   int
+	lastTradeID int64
+	lock        *sync.RWMutex
+}
+
+// AddTrade appends a trade to the tape. Trades that were already seen (for example the
+// "last_match" replayed on every subscription) are ignored and false is returned.
+func (tt *TradeTape) AddTrade(trade *Trade) bool {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	if trade.TradeID <= tt.lastTradeID {
+		return false
+	}
+	tt.lastTradeID = trade.TradeID
+	tt.trades = append(tt.trades, trade)
+
+	// Evict by age, relative to the newest trade, and then by count
+	cutoff := trade.Time.Add(-tt.maxAge)
+	evict := 0
+	for evict < len(tt.trades) && tt.trades[evict].Time.Before(cutoff) {
+		evict++
+	}
+	if len(tt.trades)-evict > tt.maxTrades {
+		evict = len(tt.trades) - tt.maxTrades
+	}
+	if evict > 0 {
+		tt.trades = append([]*Trade(nil), tt.trades[evict:]...)
+	}
+	return true
+}
+
+// LastPrice returns the price of the most recent trade, and false if no trade was seen yet.
+func (tt *TradeTape) LastPrice() (float64, bool) {
+	tt.lock.RLock()
+	defer tt.lock.RUnlock()
+	if len(tt.trades) == 0 {
+		return 0, false
+	}
+	return tt.trades[len(tt.trades)-1].Price, true
+}
+
+// RecentTrades returns up to `limit` trades, newest first. A limit of 0 returns the whole tape.
+func (tt *TradeTape) RecentTrades(limit int) []*Trade {
+	tt.lock.RLock()
+	defer tt.lock.RUnlock()
+	if limit <= 0 || limit > len(tt.trades) {
+		limit = len(tt.trades)
+	}
+	result := make([]*Trade, limit)
+	for idx := 0; idx < limit; idx++ {
+		result[idx] = tt.trades[len(tt.trades)-1-idx]
+	}
+	return result
+}
+
+// Windows returns the rolling windows the tape was configured with.
+func (tt *TradeTape) Windows() []time.Duration {
+	return tt.windows
+}
+
+// Stats returns volume, aggressor volume and VWAP for a rolling window ending at `now`.
+func (tt *TradeTape) Stats(window time.Duration, now time.Time) TradeStats {
+	tt.lock.RLock()
+	defer tt.lock.RUnlock()
+
+	stats := TradeStats{Window: window}
+	cutoff := now.Add(-window)
+	notional := 0.0
+	for idx := len(tt.trades) - 1; idx >= 0; idx-- {
+		trade := tt.trades[idx]
+		if trade.Time.Before(cutoff) {
+			break
+		}
+		if trade.Time.After(now) {
+			continue
+		}
+		stats.Count++
+		stats.Volume += trade.Size
+		notional += trade.Size * trade.Price
+		if trade.Side == BUY {
+			stats.BuyVolume += trade.Size
+		} else {
+			stats.SellVolume += trade.Size
+		}
+	}
+	if stats.Volume > 0 {
+		stats.VWAP = notional / stats.Volume
+	}
+	return stats
+}
+
+// AllStats returns Stats for every configured window.
+func (tt *TradeTape) AllStats(now time.Time) []TradeStats {
+	result := make([]TradeStats, len(tt.windows))
+	for idx, window := range tt.windows {
+		result[idx] = tt.Stats(window, now)
+	}
+	return result
+}
+
+// NewTradeTape creates a new trade tape that can report statistics over each of `windows`,
+// holding at most `maxTrades` trades.
+func NewTradeTape(productID string, windows []time.Duration, maxTrades int) *TradeTape {
+	maxAge := time.Duration(0)
+	for _, window := range windows {
+		if window > maxAge {
+			maxAge = window
+		}
+	}
+	return &TradeTape{
+		ProductID: productID,
+		windows:   windows,
+		maxAge:    maxAge,
+		maxTrades: maxTrades,
+		lock:      &sync.RWMutex{},
+	}
+}
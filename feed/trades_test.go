@@ -0,0 +1,65 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTradeTapeStats(t *testing.T) {
+	tape := NewTradeTape("ETH-DAI", []time.Duration{time.Minute, 5 * time.Minute}, 100)
+	now := time.Unix(1602449402, 0)
+	tape.AddTrade(&Trade{TradeID: 1, Price: 300, Size: 2, Side: SELL, Time: now.Add(-3 * time.Minute)})
+	tape.AddTrade(&Trade{TradeID: 2, Price: 310, Size: 1, Side: BUY, Time: now.Add(-30 * time.Second)})
+	tape.AddTrade(&Trade{TradeID: 3, Price: 320, Size: 1, Side: BUY, Time: now})
+
+	lastPrice, ok := tape.LastPrice()
+	if !ok || lastPrice != 320 {
+		t.Errorf("Expected last price 320, got %f", lastPrice)
+	}
+
+	stats := tape.Stats(time.Minute, now)
+	if stats.Count != 2 || stats.Volume != 2 || stats.VWAP != 315 {
+		t.Errorf("Unexpected 1m stats %+v", stats)
+	}
+	stats = tape.Stats(5*time.Minute, now)
+	if stats.Count != 3 || stats.BuyVolume != 2 || stats.SellVolume != 2 || stats.VWAP != 307.5 {
+		t.Errorf("Unexpected 5m stats %+v", stats)
+	}
+	if len(tape.AllStats(now)) != 2 {
+		t.Errorf("Expected stats for 2 windows")
+	}
+}
+
+func TestTradeTapeIgnoresDuplicates(t *testing.T) {
+	tape := NewTradeTape("ETH-DAI", []time.Duration{time.Minute}, 100)
+	now := time.Now()
+	if !tape.AddTrade(&Trade{TradeID: 5, Price: 300, Size: 1, Side: BUY, Time: now}) {
+		t.Error("First trade should have been added")
+	}
+	if tape.AddTrade(&Trade{TradeID: 5, Price: 300, Size: 1, Side: BUY, Time: now}) {
+		t.Error("Duplicate trade should have been ignored")
+	}
+	if len(tape.RecentTrades(0)) != 1 {
+		t.Errorf("Expected 1 trade, got %d", len(tape.RecentTrades(0)))
+	}
+}
+
+func TestTradeTapeEviction(t *testing.T) {
+	tape := NewTradeTape("ETH-DAI", []time.Duration{time.Minute}, 3)
+	now := time.Now()
+	tape.AddTrade(&Trade{TradeID: 1, Price: 1, Size: 1, Time: now.Add(-2 * time.Minute)})
+	tape.AddTrade(&Trade{TradeID: 2, Price: 2, Size: 1, Time: now})
+	if len(tape.RecentTrades(0)) != 1 {
+		t.Errorf("Expected the old trade to be evicted, got %d trades", len(tape.RecentTrades(0)))
+	}
+	for id := int64(3); id < 10; id++ {
+		tape.AddTrade(&Trade{TradeID: id, Price: float64(id), Size: 1, Time: now})
+	}
+	recent := tape.RecentTrades(0)
+	if len(recent) != 3 || recent[0].TradeID != 9 || recent[2].TradeID != 7 {
+		t.Errorf("Expected the 3 newest trades, newest first")
+	}
+	if len(tape.RecentTrades(2)) != 2 {
+		t.Errorf("Expected limit to be applied")
+	}
+}
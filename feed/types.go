@@ -3,8 +3,22 @@ package feed
 import "time"
 
 type Update struct {
-	Price string
-	Size  string
+	Price string `json:"price"`
+	Size  string `json:"size"`
+}
+
+// BookSnapshot is a serializable, point-in-time copy of an OrderbookFeed.
+type BookSnapshot struct {
+	ProductID string `json:"product_id"`
+	// Version is the internal version of the book, incremented on every change
+	Version uint64 `json:"version"`
+	// Sequence is the exchange sequence of the last message applied to the book, when the feed
+	// provides one (level 3). It is zero otherwise.
+	Sequence  int64     `json:"sequence,omitempty"`
+	Epoch     int64     `json:"epoch"`
+	Timestamp time.Time `json:"timestamp"`
+	Bids      []*Update `json:"bids"`
+	Asks      []*Update `json:"asks"`
 }
 
 type orderbookSortedKey struct {
@@ -24,6 +38,11 @@ type TickerChannel struct {
 	ProductIds []string `json:"product_ids"`
 }
 
+// WebsocketMessage is implemented by every typed message decoded from the websocket feed.
+type WebsocketMessage interface {
+	GetType() string
+}
+
 type WebsocketType struct {
 	Type string `json:"type"`
 }
@@ -46,6 +65,112 @@ type L2SnapshotMessage struct {
 	Asks      [][]string `json:"asks"`
 }
 
+type HeartbeatMessage struct {
+	WebsocketType
+	ProductID   string    `json:"product_id"`
+	Sequence    int64     `json:"sequence"`
+	LastTradeID int64     `json:"last_trade_id"`
+	Time        time.Time `json:"time"`
+}
+
+type SubscriptionsMessage struct {
+	WebsocketType
+	Channels []interface{} `json:"channels"`
+}
+
+type ErrorMessage struct {
+	WebsocketType
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+type MatchMessage struct {
+	WebsocketType
+	TradeID      int64     `json:"trade_id"`
+	Sequence     int64     `json:"sequence"`
+	MakerOrderID string    `json:"maker_order_id"`
+	TakerOrderID string    `json:"taker_order_id"`
+	Time         time.Time `json:"time"`
+	ProductID    string    `json:"product_id"`
+	Size         string    `json:"size"`
+	Price        string    `json:"price"`
+	Side         string    `json:"side"`
+}
+
+// FullChannelMessage models the received, open, done, change and activate messages of the
+// Coinbase Pro "full" channel. Fields that do not apply to a message type are left empty.
+type FullChannelMessage struct {
+	WebsocketType
+	ProductID     string    `json:"product_id"`
+	Sequence      int64     `json:"sequence"`
+	Time          time.Time `json:"time"`
+	OrderID       string    `json:"order_id"`
+	OrderType     string    `json:"order_type"`
+	Side          string    `json:"side"`
+	Price         string    `json:"price"`
+	Size          string    `json:"size"`
+	RemainingSize string    `json:"remaining_size"`
+	NewSize       string    `json:"new_size"`
+	OldSize       string    `json:"old_size"`
+	Reason        string    `json:"reason"`
+}
+
+// L3SnapshotMessage is the level 3 book returned by the REST API, each level being a
+// [price, size, order_id] triple.
+type L3SnapshotMessage struct {
+	Sequence int64      `json:"sequence"`
+	Bids     [][]string `json:"bids"`
+	Asks     [][]string `json:"asks"`
+}
+
+type L3Order struct {
+	OrderID  string
+	Side     string
+	Price    string
+	Size     float64
+	OpenedAt time.Time
+}
+
+type QueuePosition struct {
+	OrderID     string
+	Side        string
+	Price       string
+	OrdersAhead int
+	SizeAhead   float64
+	LevelSize   float64
+	Age         time.Duration
+}
+
+type Trade struct {
+	TradeID   int64
+	ProductID string
+	Price     float64
+	Size      float64
+	Side      string
+	Time      time.Time
+}
+
+type TradeStats struct {
+	Window     time.Duration
+	Count      int
+	Volume     float64
+	BuyVolume  float64
+	SellVolume float64
+	VWAP       float64
+}
+
+type Candle struct {
+	Interval   time.Duration
+	Start      time.Time
+	Source     string
+	Open       float64
+	High       float64
+	Low        float64
+	Close      float64
+	Volume     float64
+	TradeCount int
+}
+
 type OrderbookModel interface {
 	SetSnapshot(epoch int64, bids []*Update, asks []*Update) bool
 	WriteUpdate(epoch int64, bids []*Update, asks []*Update) bool
@@ -0,0 +1,364 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"pirosb3/real_feed/auth"
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/rpc"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	OPENAPI_PATH = "/openapi.json"
+	// MAX_BODY_BYTES bounds the size of a request body.
+	MAX_BODY_BYTES = 1 << 20
+)
+
+// route maps a path to a method of the gRPC controller. GET requests take the fields of the
+// request message as query parameters, POST requests take the message as a JSON body.
+type route struct {
+	path     string
+	summary  string
+	postOnly bool
+	request  proto.Message
+	response proto.Message
+	call     func(ctx context.Context, in proto.Message) (proto.Message, error)
+}
+
+// Gateway serves the OrderbookService as JSON over HTTP. Messages are encoded with the standard
+// protobuf JSON mapping, so field names match the gRPC API.
+type Gateway struct {
+	routes      []route
+	mux         *http.ServeMux
+	interceptor grpc.UnaryServerInterceptor
+}
+
+func NewGateway(ob controller.OrderbookGrpcController) *Gateway {
+	g := &Gateway{
+		routes: makeRoutes(ob),
+		mux:    http.NewServeMux(),
+	}
+	for idx := range g.routes {
+		r := g.routes[idx]
+		g.mux.HandleFunc(r.path, func(w http.ResponseWriter, req *http.Request) {
+			g.serveRoute(r, w, req)
+		})
+	}
+	g.mux.HandleFunc(OPENAPI_PATH, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(g.OpenAPI())
+	})
+	return g
+}
+
+func makeRoutes(ob controller.OrderbookGrpcController) []route {
+	return []route{
+		{path: "/v1/quotes/buy-base", summary: "Quote to pay for buying an amount of base", request: &rpc.PricingRequest{}, response: &rpc.PricingResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.BuyBase(ctx, in.(*rpc.PricingRequest))
+			}},
+		{path: "/v1/quotes/buy-quote", summary: "Base to sell for receiving an amount of quote", request: &rpc.PricingRequest{}, response: &rpc.PricingResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.BuyQuote(ctx, in.(*rpc.PricingRequest))
+			}},
+		{path: "/v1/quotes/sell-base", summary: "Quote received for selling an amount of base", request: &rpc.PricingRequest{}, response: &rpc.PricingResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.SellBase(ctx, in.(*rpc.PricingRequest))
+			}},
+		{path: "/v1/quotes/sell-quote", summary: "Base bought by spending an amount of quote", request: &rpc.PricingRequest{}, response: &rpc.PricingResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.SellQuote(ctx, in.(*rpc.PricingRequest))
+			}},
+		{path: "/v1/quotes/at", summary: "Quote against the book as it was at a point in time", request: &rpc.HistoricalPricingRequest{}, response: &rpc.PricingResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.QuoteAt(ctx, in.(*rpc.HistoricalPricingRequest))
+			}},
+		{path: "/v1/quotes/buy-base-exact-out", summary: "Quote to pay for receiving an amount of base", request: &rpc.ExactOutRequest{}, response: &rpc.ExactOutResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.BuyBaseExactOut(ctx, in.(*rpc.ExactOutRequest))
+			}},
+		{path: "/v1/quotes/buy-quote-exact-out", summary: "Base to sell for receiving an amount of quote", request: &rpc.ExactOutRequest{}, response: &rpc.ExactOutResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.BuyQuoteExactOut(ctx, in.(*rpc.ExactOutRequest))
+			}},
+		{path: "/v1/quotes/sell-base-exact-out", summary: "Base to sell for netting an amount of quote", request: &rpc.ExactOutRequest{}, response: &rpc.ExactOutResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.SellBaseExactOut(ctx, in.(*rpc.ExactOutRequest))
+			}},
+		{path: "/v1/quotes/sell-quote-exact-out", summary: "Quote to spend for netting an amount of base", request: &rpc.ExactOutRequest{}, response: &rpc.ExactOutResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.SellQuoteExactOut(ctx, in.(*rpc.ExactOutRequest))
+			}},
+		{path: "/v1/firm-quotes", summary: "Request a quote that reserves liquidity until it expires", postOnly: true, request: &rpc.FirmQuoteRequest{}, response: &rpc.FirmQuoteResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.RequestQuote(ctx, in.(*rpc.FirmQuoteRequest))
+			}},
+		{path: "/v1/firm-quotes/accept", summary: "Accept a firm quote", postOnly: true, request: &rpc.AcceptQuoteRequest{}, response: &rpc.AcceptQuoteResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.AcceptQuote(ctx, in.(*rpc.AcceptQuoteRequest))
+			}},
+		{path: "/v1/ticker", summary: "Best bid, best ask and last trade", request: &rpc.TickerRequest{}, response: &rpc.TickerResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.GetTicker(ctx, in.(*rpc.TickerRequest))
+			}},
+		{path: "/v1/depth", summary: "Best levels of both sides of the book", request: &rpc.DepthRequest{}, response: &rpc.DepthResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.GetDepth(ctx, in.(*rpc.DepthRequest))
+			}},
+		{path: "/v1/liquidity", summary: "Depth around mid and slippage by size", request: &rpc.LiquidityRequest{}, response: &rpc.LiquidityProfileResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.GetLiquidityProfile(ctx, in.(*rpc.LiquidityRequest))
+			}},
+		{path: "/v1/impact", summary: "Price impact of market orders by size", request: &rpc.ImpactCurveRequest{}, response: &rpc.ImpactCurveResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.GetImpactCurve(ctx, in.(*rpc.ImpactCurveRequest))
+			}},
+		{path: "/v1/trades", summary: "Recent trades and trade statistics", request: &rpc.TradesRequest{}, response: &rpc.TradesResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.GetRecentTrades(ctx, in.(*rpc.TradesRequest))
+			}},
+		{path: "/v1/candles", summary: "Candles of an interval", request: &rpc.CandlesRequest{}, response: &rpc.CandlesResponse{},
+			call: func(ctx context.Context, in proto.Message) (proto.Message, error) {
+				return ob.GetCandles(ctx, in.(*rpc.CandlesRequest))
+			}},
+	}
+}
+
+// SetInterceptor runs `interceptor` around every call, as the gRPC server does, with the API key
+// of the HTTP request as request metadata. The method the interceptor receives is the path of the
+// route. It must be called before serving requests.
+func (g *Gateway) SetInterceptor(interceptor grpc.UnaryServerInterceptor) {
+	g.interceptor = interceptor
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	g.mux.ServeHTTP(w, req)
+}
+
+func (g *Gateway) serveRoute(r route, w http.ResponseWriter, req *http.Request) {
+	in := r.request.ProtoReflect().New().Interface()
+	switch {
+	case req.Method == http.MethodGet && !r.postOnly:
+		if err := decodeQuery(req.URL.Query(), in.ProtoReflect()); err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+	case req.Method == http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MAX_BODY_BYTES))
+		if err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		if err := protojson.Unmarshal(body, in); err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+	default:
+		w.Header().Set("Allow", allowedMethods(r))
+		writeStatus(w, http.StatusMethodNotAllowed, status.New(codes.Unimplemented, "Method not allowed"))
+		return
+	}
+
+	// Failures are returned as status errors, and not in the error field of the response
+	ctx := auth.IncomingContext(req)
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Join(md, metadata.Pairs(controller.ERROR_MODE_METADATA, controller.STATUS_ERROR_MODE)))
+	var out proto.Message
+	var err error
+	if g.interceptor != nil {
+		var result interface{}
+		result, err = g.interceptor(ctx, in, &grpc.UnaryServerInfo{FullMethod: r.path}, func(ctx context.Context, in interface{}) (interface{}, error) {
+			return r.call(ctx, in.(proto.Message))
+		})
+		out, _ = result.(proto.Message)
+	} else {
+		out, err = r.call(ctx, in)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(out)
+	if err != nil {
+		writeError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func allowedMethods(r route) string {
+	if r.postOnly {
+		return http.MethodPost
+	}
+	return http.MethodGet + ", " + http.MethodPost
+}
+
+// decodeQuery sets the scalar fields of `msg` from query parameters named after their JSON
+// names. Repeated fields take one parameter per value.
+func decodeQuery(values url.Values, msg protoreflect.Message) error {
+	fields := msg.Descriptor().Fields()
+	for key, raw := range values {
+		field := fields.ByJSONName(key)
+		if field == nil {
+			field = fields.ByName(protoreflect.Name(key))
+		}
+		if field == nil || field.IsMap() || field.Message() != nil {
+			return fmt.Errorf("Unsupported parameter '%s'", key)
+		}
+		if field.IsList() {
+			list := msg.Mutable(field).List()
+			for _, item := range raw {
+				value, err := parseScalar(field, item)
+				if err != nil {
+					return err
+				}
+				list.Append(value)
+			}
+			continue
+		}
+		value, err := parseScalar(field, raw[len(raw)-1])
+		if err != nil {
+			return err
+		}
+		msg.Set(field, value)
+	}
+	return nil
+}
+
+func parseScalar(field protoreflect.FieldDescriptor, raw string) (protoreflect.Value, error) {
+	invalid := fmt.Errorf("Invalid value '%s' for parameter '%s'", raw, field.JSONName())
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(raw), nil
+	case protoreflect.BoolKind:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfBool(parsed), nil
+	case protoreflect.DoubleKind, protoreflect.FloatKind:
+		bitSize := 64
+		if field.Kind() == protoreflect.FloatKind {
+			bitSize = 32
+		}
+		parsed, err := strconv.ParseFloat(raw, bitSize)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		if bitSize == 32 {
+			return protoreflect.ValueOfFloat32(float32(parsed)), nil
+		}
+		return protoreflect.ValueOfFloat64(parsed), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		parsed, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfInt32(int32(parsed)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfInt64(parsed), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfUint32(uint32(parsed)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfUint64(parsed), nil
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByName(protoreflect.Name(raw)); value != nil {
+			return protoreflect.ValueOfEnum(value.Number()), nil
+		}
+		return protoreflect.Value{}, invalid
+	}
+	return protoreflect.Value{}, invalid
+}
+
+// errorBody is the JSON body of failed requests.
+type errorBody struct {
+	Code     int               `json:"code"`
+	Status   string            `json:"status"`
+	Message  string            `json:"message"`
+	Reason   string            `json:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeStatus(w, httpStatus(st.Code()), st)
+}
+
+func writeStatus(w http.ResponseWriter, httpStatus int, st *status.Status) {
+	body := errorBody{
+		Code:    int(st.Code()),
+		Status:  st.Code().String(),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			body.Reason = info.GetReason()
+			body.Metadata = info.GetMetadata()
+		}
+		if retry, ok := detail.(*errdetails.RetryInfo); ok {
+			seconds := math.Ceil(float64(retry.GetRetryDelay().GetSeconds()) + float64(retry.GetRetryDelay().GetNanos())/1e9)
+			w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(body)
+}
+
+// httpStatus maps gRPC codes to HTTP statuses the way grpc-gateway does.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/auth"
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/feed"
+)
+
+func newTestGateway() *Gateway {
+	fc := controller.NewFeedController(context.Background(), "ETH-DAI")
+	fc.Orderbook().SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "333.2", Size: "0.5"},
+		&feed.Update{Price: "320", Size: "0.5"},
+	}, []*feed.Update{
+		&feed.Update{Price: "335.12", Size: "0.5"},
+	})
+	return NewGateway(*controller.NewOrderbookGrpcController(fc, "ETH-DAI"))
+}
+
+func serve(g *Gateway, method string, target string, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	recorder := httptest.NewRecorder()
+	g.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	result := make(map[string]interface{})
+	json.Unmarshal(recorder.Body.Bytes(), &result)
+	return recorder, result
+}
+
+func TestQuotesOverGetAndPost(t *testing.T) {
+	g := newTestGateway()
+	recorder, result := serve(g, http.MethodGet, "/v1/quotes/sell-base?product=ETH-DAI&inAmount=0.6", "")
+	if recorder.Code != http.StatusOK || result["outAmount"].(float64) != 198.6 {
+		t.Errorf("Unexpected response %d %s", recorder.Code, recorder.Body.String())
+	}
+	recorder, result = serve(g, http.MethodPost, "/v1/depth", `{"product": "ETH-DAI", "levels": 1}`)
+	if recorder.Code != http.StatusOK || len(result["bids"].([]interface{})) != 1 {
+		t.Errorf("Unexpected response %d %s", recorder.Code, recorder.Body.String())
+	}
+	recorder, result = serve(g, http.MethodGet, "/v1/ticker?product=ETH-DAI", "")
+	if recorder.Code != http.StatusOK || result["bestAsk"].(float64) != 335.12 {
+		t.Errorf("Unexpected response %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestErrorsUseHTTPStatuses(t *testing.T) {
+	g := newTestGateway()
+	recorder, result := serve(g, http.MethodGet, "/v1/quotes/sell-base?product=ETH-DAI&inAmount=5", "")
+	if recorder.Code != http.StatusBadRequest || result["reason"] != controller.REASON_INSUFFICIENT_LIQUIDITY {
+		t.Errorf("Expected insufficient liquidity, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder, _ := serve(g, http.MethodGet, "/v1/ticker?product=BTC-USD", ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown product, got %d", recorder.Code)
+	}
+	if recorder, _ := serve(g, http.MethodGet, "/v1/depth?product=ETH-DAI&levels=many", ""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid parameter, got %d", recorder.Code)
+	}
+	if recorder, _ := serve(g, http.MethodGet, "/v1/depth?product=ETH-DAI&levels=2147483647", ""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected too many levels to be rejected, got %d", recorder.Code)
+	}
+	if recorder, _ := serve(g, http.MethodGet, "/v1/firm-quotes?product=ETH-DAI", ""); recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected firm quotes to require POST, got %d", recorder.Code)
+	}
+}
+
+func TestOpenAPICoversEveryRoute(t *testing.T) {
+	g := newTestGateway()
+	recorder, document := serve(g, http.MethodGet, OPENAPI_PATH, "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d", recorder.Code)
+	}
+	paths := document["paths"].(map[string]interface{})
+	for _, r := range g.routes {
+		if _, ok := paths[r.path]; !ok {
+			t.Errorf("Expected %s to be documented", r.path)
+		}
+	}
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"DepthResponse", "PriceLevel", "Error"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("Expected the %s schema", name)
+		}
+	}
+}
+
+func TestInterceptorAuthenticatesRequests(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "clients")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clients.yaml")
+	os.WriteFile(path, []byte("clients:\n  - name: bot\n    apiKeySHA256: "+auth.HashAPIKey("secret")+"\n    requestsPerSecond: 1\n    maxQuoteAmount: 1\n"), 0600)
+	authenticator, err := auth.NewAuthenticator(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	g := newTestGateway()
+	g.SetInterceptor(authenticator.UnaryInterceptor)
+
+	if recorder, _ := serve(g, http.MethodGet, "/v1/ticker?product=ETH-DAI", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a request without a key to be rejected, got %d", recorder.Code)
+	}
+	request := httptest.NewRequest(http.MethodGet, "/v1/quotes/sell-base?product=ETH-DAI&inAmount=2", nil)
+	request.Header.Set(auth.API_KEY_METADATA, "secret")
+	recorder := httptest.NewRecorder()
+	g.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected an amount above the limit to be rejected, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	request = httptest.NewRequest(http.MethodGet, "/v1/ticker?product=ETH-DAI", nil)
+	request.Header.Set(auth.AUTHORIZATION_METADATA, "Bearer secret")
+	recorder = httptest.NewRecorder()
+	g.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected the rate limit to be exceeded, got %d", recorder.Code)
+	}
+}
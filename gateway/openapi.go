@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const OPENAPI_VERSION = "3.0.3"
+
+// OpenAPI returns the OpenAPI document of the gateway, generated from its routes and the
+// descriptors of their messages.
+func (g *Gateway) OpenAPI() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for _, r := range g.routes {
+		request := r.request.ProtoReflect().Descriptor()
+		response := r.response.ProtoReflect().Descriptor()
+		addSchema(schemas, request)
+		addSchema(schemas, response)
+
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Success",
+				"content":     jsonContent(schemaRef(response)),
+			},
+			"default": map[string]interface{}{
+				"description": "Failure",
+				"content":     jsonContent(map[string]interface{}{"$ref": "#/components/schemas/Error"}),
+			},
+		}
+		operations := map[string]interface{}{
+			"post": map[string]interface{}{
+				"summary":     r.summary,
+				"operationId": operationID(r.path, http.MethodPost),
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content":  jsonContent(schemaRef(request)),
+				},
+				"responses": responses,
+			},
+		}
+		if !r.postOnly {
+			operations["get"] = map[string]interface{}{
+				"summary":     r.summary,
+				"operationId": operationID(r.path, http.MethodGet),
+				"parameters":  queryParameters(request),
+				"responses":   responses,
+			}
+		}
+		paths[r.path] = operations
+	}
+	schemas["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"code":     map[string]interface{}{"type": "integer", "description": "gRPC status code"},
+			"status":   map[string]interface{}{"type": "string"},
+			"message":  map[string]interface{}{"type": "string"},
+			"reason":   map[string]interface{}{"type": "string"},
+			"metadata": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]interface{}{
+			"title":   "OrderbookService",
+			"version": "v1",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// operationID turns /v1/quotes/buy-base into getV1QuotesBuyBase.
+func operationID(path string, method string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func schemaRef(message protoreflect.MessageDescriptor) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + string(message.Name())}
+}
+
+// addSchema adds the schema of `message`, and of the messages it references, to `schemas`.
+func addSchema(schemas map[string]interface{}, message protoreflect.MessageDescriptor) {
+	name := string(message.Name())
+	if _, ok := schemas[name]; ok {
+		return
+	}
+	properties := make(map[string]interface{})
+	schemas[name] = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	fields := message.Fields()
+	for idx := 0; idx < fields.Len(); idx++ {
+		field := fields.Get(idx)
+		properties[field.JSONName()] = fieldSchema(schemas, field)
+	}
+}
+
+func fieldSchema(schemas map[string]interface{}, field protoreflect.FieldDescriptor) map[string]interface{} {
+	if field.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": valueSchema(schemas, field.MapValue()),
+		}
+	}
+	if field.IsList() {
+		return map[string]interface{}{
+			"type":  "array",
+			"items": valueSchema(schemas, field),
+		}
+	}
+	return valueSchema(schemas, field)
+}
+
+// valueSchema returns the schema of a single value of `field`, following the protobuf JSON
+// mapping: 64-bit integers are strings and enums are their names.
+func valueSchema(schemas map[string]interface{}, field protoreflect.FieldDescriptor) map[string]interface{} {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		addSchema(schemas, field.Message())
+		return schemaRef(field.Message())
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, values.Len())
+		for idx := range names {
+			names[idx] = string(values.Get(idx).Name())
+		}
+		return map[string]interface{}{"type": "string", "enum": names}
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// queryParameters describes the scalar fields of `message` as query parameters.
+func queryParameters(message protoreflect.MessageDescriptor) []interface{} {
+	var parameters []interface{}
+	fields := message.Fields()
+	for idx := 0; idx < fields.Len(); idx++ {
+		field := fields.Get(idx)
+		if field.IsMap() || field.Message() != nil {
+			continue
+		}
+		parameter := map[string]interface{}{
+			"name":   field.JSONName(),
+			"in":     "query",
+			"schema": fieldSchema(nil, field),
+		}
+		if field.IsList() {
+			parameter["explode"] = true
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
@@ -1,7 +1,6 @@
-
 module pirosb3/real_feed
 
-go 1.15
+go 1.16
 
 require (
 	github.com/fullstorydev/grpcurl v1.7.0 // indirect
@@ -10,7 +9,9 @@ require (
 	github.com/gorilla/websocket v1.4.2
 	github.com/prometheus/client_golang v1.7.1
 	github.com/sirupsen/logrus v1.7.0
+	google.golang.org/genproto v0.0.0-20200331122359-1ee6d9798940
 	google.golang.org/grpc v1.33.0
 	google.golang.org/grpc/cmd/protoc-gen-go-grpc v1.0.0 // indirect
 	google.golang.org/protobuf v1.23.0
-)
\ No newline at end of file
+	gopkg.in/yaml.v2 v2.3.0
+)
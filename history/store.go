@@ -0,0 +1,181 @@
+package history
+
+import (
+	"errors"
+	"pirosb3/real_feed/clock"
+	"pirosb3/real_feed/feed"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DEFAULT_RETENTION is how much history the service keeps unless configured otherwise.
+	DEFAULT_RETENTION = time.Hour
+	// DEFAULT_MAX_UPDATES bounds the updates kept for every product, whatever the retention
+	// period. It holds about an hour of a busy Coinbase Pro level 2 feed.
+	DEFAULT_MAX_UPDATES = 500000
+)
+
+var ErrNoHistory = errors.New("No history was recorded for the requested product and time")
+
+type delta struct {
+	time       time.Time
+	epoch      int64
+	bids, asks []*feed.Update
+}
+
+// segment is a full keyframe of the book followed by every update applied after it, up to the
+// next keyframe.
+type segment struct {
+	keyframe *feed.BookSnapshot
+	deltas   []*delta
+}
+
+// Store is an embedded, in-memory, time-series store of orderbooks. It keeps periodic full
+// snapshots (keyframes) and the updates between them, which allows reconstructing the book at
+// any instant within the retention period. Keyframes and updates must be recorded with times
+// from the same clock, the one books are then requested at.
+type Store struct {
+	lock             sync.RWMutex
+	series           map[string][]*segment
+	updates          map[string]int
+	keyframeInterval time.Duration
+	retention        time.Duration
+	maxUpdates       int
+	staleTimeout     int64
+}
+
+// NewStore creates a store that expects a keyframe every `keyframeInterval` and keeps
+// `retention` worth of history for every product, up to DEFAULT_MAX_UPDATES updates.
+func NewStore(keyframeInterval time.Duration, retention time.Duration) *Store {
+	return &Store{
+		series:           make(map[string][]*segment),
+		updates:          make(map[string]int),
+		keyframeInterval: keyframeInterval,
+		retention:        retention,
+		maxUpdates:       DEFAULT_MAX_UPDATES,
+		staleTimeout:     feed.TIMEOUT_STALE_BOOK,
+	}
+}
+
+// SetMaxUpdates replaces how many updates are kept for every product. The oldest segments are
+// dropped once they are exceeded, even within the retention period, and a new keyframe is needed
+// once the latest segment holds that many.
+func (s *Store) SetMaxUpdates(maxUpdates int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.maxUpdates = maxUpdates
+}
+
+// SetStaleTimeout replaces how old, in seconds, the last update of a reconstructed book may be
+// before it is considered stale. The default is feed.TIMEOUT_STALE_BOOK.
+func (s *Store) SetStaleTimeout(seconds int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.staleTimeout = seconds
+}
+
+// RecordSnapshot starts a new segment with a full copy of the book, and drops segments that
+// fell out of the retention period.
+func (s *Store) RecordSnapshot(snapshot *feed.BookSnapshot) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.series[snapshot.ProductID] = append(s.series[snapshot.ProductID], &segment{keyframe: snapshot})
+	s.evict(snapshot.ProductID, snapshot.Timestamp)
+}
+
+// evict drops the oldest segments of a product that fell out of the retention period as of
+// `now`, or that exceed the maximum number of updates, with a warning as the retention is then
+// not honoured. The latest segment is always kept. The lock must be held.
+func (s *Store) evict(product string, now time.Time) {
+	segments := s.series[product]
+	// A segment is only useful while the segment after it starts within the retention period
+	cutoff := now.Add(-s.retention)
+	evict := 0
+	for evict < len(segments)-1 {
+		expired := !segments[evict+1].keyframe.Timestamp.After(cutoff)
+		if !expired && s.updates[product] <= s.maxUpdates {
+			break
+		}
+		if !expired {
+			log.WithField("product", product).WithField("maxUpdates", s.maxUpdates).WithField("retention", s.retention).
+				Warnln("History dropped updates within the retention period, the maximum number of updates is too low for it")
+		}
+		s.updates[product] -= len(segments[evict].deltas)
+		segments[evict] = nil
+		evict++
+	}
+	s.series[product] = segments[evict:]
+}
+
+// RecordUpdate appends an update to the latest segment of a product. Updates received before
+// the first keyframe are ignored.
+func (s *Store) RecordUpdate(product string, updateTime time.Time, epoch int64, bids []*feed.Update, asks []*feed.Update) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	segments := s.series[product]
+	if len(segments) == 0 {
+		return
+	}
+	last := segments[len(segments)-1]
+	last.deltas = append(last.deltas, &delta{time: updateTime, epoch: epoch, bids: bids, asks: asks})
+	s.updates[product]++
+	s.evict(product, updateTime)
+}
+
+// NeedsKeyframe returns true when the latest keyframe of a product is older than the keyframe
+// interval, when the latest segment holds the maximum number of updates, or when no keyframe was
+// ever recorded.
+func (s *Store) NeedsKeyframe(product string, now time.Time) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	segments := s.series[product]
+	if len(segments) == 0 {
+		return true
+	}
+	last := segments[len(segments)-1]
+	return now.Sub(last.keyframe.Timestamp) >= s.keyframeInterval || len(last.deltas) >= s.maxUpdates
+}
+
+// BookAt reconstructs the orderbook of a product as it was at `at`. Staleness checks on the
+// returned book are evaluated as of `at`.
+func (s *Store) BookAt(product string, at time.Time) (*feed.OrderbookFeed, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	segments := s.series[product]
+	idx := sort.Search(len(segments), func(i int) bool {
+		return segments[i].keyframe.Timestamp.After(at)
+	}) - 1
+	if idx < 0 {
+		return nil, ErrNoHistory
+	}
+	selected := segments[idx]
+
+	book := feed.NewOrderbookFeed(product)
+	book.SetStaleTimeout(s.staleTimeout)
+	book.SetSnapshot(selected.keyframe.Epoch, selected.keyframe.Bids, selected.keyframe.Asks)
+	for _, update := range selected.deltas {
+		if update.time.After(at) {
+			break
+		}
+		book.WriteUpdate(update.epoch, update.bids, update.asks)
+	}
+	book.SetClock(clock.NewManual(at))
+	return book, nil
+}
+
+// QuoteAt reconstructs the book at `at` and runs one of the quote functions against it.
+func (s *Store) QuoteAt(product string, at time.Time, operation string, amount float64) (float64, int64, error) {
+	book, err := s.BookAt(product, at)
+	if err != nil {
+		return -1, -1, err
+	}
+	return book.Quote(operation, amount)
+}
@@ -0,0 +1,94 @@
+package history
+
+import (
+	"pirosb3/real_feed/feed"
+	"testing"
+	"time"
+)
+
+func TestQuoteAt(t *testing.T) {
+	store := NewStore(time.Minute, time.Hour)
+	start := time.Unix(1602449400, 0)
+
+	store.RecordUpdate("ETH-DAI", start, start.Unix(), nil, nil)
+	store.RecordSnapshot(&feed.BookSnapshot{
+		ProductID: "ETH-DAI",
+		Epoch:     start.Unix(),
+		Timestamp: start,
+		Bids: []*feed.Update{
+			&feed.Update{Price: "333.2", Size: "0.5"},
+			&feed.Update{Price: "310", Size: "1.5"},
+		},
+	})
+	store.RecordUpdate("ETH-DAI", start.Add(2*time.Second), start.Unix()+2, []*feed.Update{
+		&feed.Update{Price: "320", Size: "0.5"},
+	}, nil)
+
+	result, _, err := store.QuoteAt("ETH-DAI", start.Add(time.Second), feed.SELL_BASE, 0.6)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result != 197.6 {
+		t.Errorf("Expected 197.6 before the update, got %f", result)
+	}
+	result, _, err = store.QuoteAt("ETH-DAI", start.Add(3*time.Second), feed.SELL_BASE, 0.6)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result != 198.6 {
+		t.Errorf("Expected 198.6 after the update, got %f", result)
+	}
+
+	if _, _, err := store.QuoteAt("ETH-DAI", start.Add(-time.Second), feed.SELL_BASE, 0.6); err != ErrNoHistory {
+		t.Errorf("Expected no history before the first keyframe")
+	}
+	if _, _, err := store.QuoteAt("ETH-DAI", start.Add(time.Minute), feed.SELL_BASE, 0.6); err == nil || err.Error() != "Orderbook is stale" {
+		t.Errorf("Expected the book to be stale a minute after the last update, got %v", err)
+	}
+	store.SetStaleTimeout(120)
+	if _, _, err := store.QuoteAt("ETH-DAI", start.Add(time.Minute), feed.SELL_BASE, 0.6); err != nil {
+		t.Errorf("Expected the configured stale timeout to be used, got %v", err)
+	}
+}
+
+func TestKeyframesAndRetention(t *testing.T) {
+	store := NewStore(time.Minute, 2*time.Minute)
+	start := time.Unix(1602449400, 0)
+	if !store.NeedsKeyframe("ETH-DAI", start) {
+		t.Error("Expected a keyframe to be needed for an empty series")
+	}
+
+	for minute := 0; minute < 5; minute++ {
+		at := start.Add(time.Duration(minute) * time.Minute)
+		store.RecordSnapshot(&feed.BookSnapshot{ProductID: "ETH-DAI", Epoch: at.Unix(), Timestamp: at})
+	}
+	if store.NeedsKeyframe("ETH-DAI", start.Add(4*time.Minute+30*time.Second)) {
+		t.Error("Expected no keyframe to be needed within the interval")
+	}
+	if len(store.series["ETH-DAI"]) != 3 {
+		t.Errorf("Expected 3 segments to be retained, got %d", len(store.series["ETH-DAI"]))
+	}
+}
+
+func TestUpdatesAreBounded(t *testing.T) {
+	store := NewStore(time.Hour, 24*time.Hour)
+	store.SetMaxUpdates(3)
+	start := time.Unix(1602449400, 0)
+
+	at := start
+	for idx := 0; idx < 10; idx++ {
+		at = at.Add(time.Second)
+		if store.NeedsKeyframe("ETH-DAI", at) {
+			store.RecordSnapshot(&feed.BookSnapshot{ProductID: "ETH-DAI", Epoch: at.Unix(), Timestamp: at})
+			continue
+		}
+		store.RecordUpdate("ETH-DAI", at, at.Unix(), nil, nil)
+	}
+	// Keyframes are taken every 3 updates, and a segment is dropped once there are more
+	if store.updates["ETH-DAI"] != 1 || len(store.series["ETH-DAI"]) != 1 {
+		t.Errorf("Expected the latest segment only, got %d updates in %d segments", store.updates["ETH-DAI"], len(store.series["ETH-DAI"]))
+	}
+	if _, err := store.BookAt("ETH-DAI", start.Add(8*time.Second)); err != ErrNoHistory {
+		t.Errorf("Expected the oldest segment to be dropped, got %v", err)
+	}
+}
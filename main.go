@@ -2,43 +2,250 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"pirosb3/real_feed/auth"
+	"pirosb3/real_feed/backtest"
+	"pirosb3/real_feed/config"
 	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/gateway"
+	"pirosb3/real_feed/history"
+	"pirosb3/real_feed/paper"
+	"pirosb3/real_feed/persistence"
 	"pirosb3/real_feed/rpc"
+	rpcv2 "pirosb3/real_feed/rpc/v2"
+	"pirosb3/real_feed/wsserver"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
-	market := os.Getenv("MARKET")
-	port := "8000"
-	ctx, _ := context.WithCancel(context.Background())
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	market := cfg.Market
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Start feed controller
-	fc := controller.NewFeedController(ctx, market)
+	fc := controller.NewFeedControllerWithSettings(ctx, market, cfg.ControllerSettings())
+	if cfg.Effective().Level == 3 {
+		fc.EnableLevelThree()
+	}
+	if cfg.History {
+		store := history.NewStore(time.Minute, time.Duration(cfg.HistoryRetentionSecs)*time.Second)
+		store.SetMaxUpdates(int(cfg.HistoryMaxUpdates))
+		fc.EnableHistory(store)
+	}
+	if cfg.SnapshotDir != "" {
+		store, err := persistence.NewFileSnapshotStore(cfg.SnapshotDir, cfg.SnapshotArchive)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		fc.EnablePersistence(store)
+	}
+	var recordFile *os.File
+	if cfg.RecordFile != "" {
+		recordFile, err = os.OpenFile(cfg.RecordFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		fc.EnableRecording(backtest.NewRecorder(recordFile))
+	}
 	fc.Start()
 
+	// Report health of the feed over gRPC and HTTP
+	healthChecker := controller.NewHealthChecker(fc, market, "OrderbookService", "v2.OrderbookService")
+	go healthChecker.Run(ctx)
+
 	// Start prometheus server
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthChecker.Healthz)
+	mux.HandleFunc("/readyz", healthChecker.Readyz)
+	metricsServer := &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		http.ListenAndServe(":2112", nil)
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithField("err", err.Error()).Errorln("Metrics server stopped")
+		}
 	}()
 
+	// Authenticate clients of the gRPC server, the gateway and the websocket server
+	var authenticator *auth.Authenticator
+	if cfg.ClientsFile != "" {
+		authenticator, err = auth.NewAuthenticator(cfg.ClientsFile)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		go authenticator.Run(ctx)
+	}
+
+	// Serve the gRPC server, the gateway and the websocket server using TLS when a certificate is
+	// configured, and mutual TLS when clients certificates are verified as well
+	var tlsConfig *tls.Config
+	if cfg.TLSCertFile != "" {
+		certs, err := auth.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		go certs.Run(ctx)
+		tlsConfig = certs.TLSConfig()
+	}
+
+	// Start websocket server for browsers
+	var streamServer *http.Server
+	if cfg.StreamAddr != "" {
+		fanOut := wsserver.NewServer(ctx, fc, market)
+		if authenticator != nil {
+			fanOut.SetAuthenticator(authenticator)
+		}
+		go fanOut.Run()
+		streamMux := http.NewServeMux()
+		streamMux.Handle("/ws", fanOut)
+		streamServer = &http.Server{Addr: cfg.StreamAddr, Handler: streamMux, TLSConfig: tlsConfig}
+		go func() {
+			if err := listenAndServe(streamServer); err != nil && err != http.ErrServerClosed {
+				log.WithField("err", err.Error()).Errorln("Websocket server stopped")
+			}
+		}()
+	}
+
 	// Create wrapper service
 	orderbookController := controller.NewOrderbookGrpcController(fc, market)
 
 	// Start gRPC server
-	grpcServer := grpc.NewServer()
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(auth.UnaryIdentityInterceptor),
+		grpc.ChainStreamInterceptor(auth.StreamIdentityInterceptor),
+	}
+	if authenticator != nil {
+		serverOptions = append(serverOptions,
+			grpc.ChainUnaryInterceptor(authenticator.UnaryInterceptor),
+			grpc.ChainStreamInterceptor(authenticator.StreamInterceptor),
+		)
+	}
+	if tlsConfig != nil {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 	rpc.RegisterOrderbookServiceServer(grpcServer, *orderbookController)
-	// ... // determine whether to use TLS
-	lis, err := net.Listen("tcp", ":"+port)
+	rpcv2.RegisterOrderbookServiceServer(grpcServer, *controller.NewOrderbookGrpcControllerV2(fc, market))
+	healthpb.RegisterHealthServer(grpcServer, healthChecker.Server())
+	// Allows tools such as grpcurl to query the server without the .proto files
+	reflection.Register(grpcServer)
+
+	// Start HTTP/JSON gateway
+	var gatewayServer *http.Server
+	if cfg.GatewayAddr != "" {
+		restGateway := gateway.NewGateway(*orderbookController)
+		if authenticator != nil {
+			restGateway.SetInterceptor(authenticator.UnaryInterceptor)
+		}
+		gatewayServer = &http.Server{Addr: cfg.GatewayAddr, Handler: restGateway, TLSConfig: tlsConfig}
+		go func() {
+			if err := listenAndServe(gatewayServer); err != nil && err != http.ErrServerClosed {
+				log.WithField("err", err.Error()).Errorln("Gateway stopped")
+			}
+		}()
+	}
+	unsubscribePaperTrading := func() {}
+	if cfg.PaperTrading {
+		engine := paper.NewEngine(market, fc.Orderbook())
+		var events <-chan (*controller.FeedEvent)
+		events, unsubscribePaperTrading = fc.Subscribe()
+		go engine.Run(ctx, events)
+		rpc.RegisterPaperTradingServiceServer(grpcServer, *paper.NewPaperGrpcController(engine))
+	}
+	lis, err := net.Listen("tcp", cfg.GRPCAddr)
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
-	log.WithField("market", market).WithField("port", port).Infoln("Starting gRPC server")
-	grpcServer.Serve(lis)
+	log.WithField("market", market).WithField("addr", cfg.GRPCAddr).WithField("tls", cfg.TLSCertFile != "").WithField("mtls", cfg.TLSClientCAFile != "").Infoln("Starting gRPC server")
+	served := make(chan (error), 1)
+	go func() {
+		served <- grpcServer.Serve(lis)
+	}()
+
+	signals := make(chan (os.Signal), 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		log.WithField("signal", sig.String()).Infoln("Shutting down")
+	case err := <-served:
+		log.WithField("err", err).Errorln("gRPC server stopped")
+	}
+
+	// Fail health checks first, so that load balancers stop routing to this instance
+	healthChecker.Shutdown()
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSecs)*time.Second)
+	defer cancelDeadline()
+	if gatewayServer != nil {
+		if err := gatewayServer.Shutdown(deadline); err != nil {
+			log.WithField("err", err.Error()).Warningln("Gateway did not shut down cleanly")
+		}
+	}
+	shutdown(deadline, grpcServer, fc, cancel)
+	unsubscribePaperTrading()
+	if streamServer != nil {
+		// Websocket connections are hijacked, they were closed with the feed subscriptions
+		streamServer.Shutdown(deadline)
+	}
+	if err := metricsServer.Shutdown(deadline); err != nil {
+		log.WithField("err", err.Error()).Warningln("Metrics server did not shut down cleanly")
+	}
+	if recordFile != nil {
+		recordFile.Close()
+	}
+	log.Infoln("Shutdown complete")
+}
+
+// listenAndServe serves `server` using TLS if it has a configuration for it. Certificates are
+// provided by the configuration, so that they are reloaded.
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// shutdown stops accepting RPCs and ends the streams, then drains the in-flight RPCs before
+// stopping the feed, so that they are answered by a running book. RPCs still running at the
+// deadline are cancelled, and the feed is stopped regardless.
+func shutdown(deadline context.Context, grpcServer *grpc.Server, fc *controller.FeedController, cancel context.CancelFunc) {
+	fc.CloseSubscriptions()
+	drained := make(chan (struct{}))
+	go func() {
+		grpcServer.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-deadline.Done():
+		log.Warningln("Deadline reached before every RPC drained, cancelling the remaining ones")
+		grpcServer.Stop()
+	}
+
+	cancel()
+	if err := fc.Shutdown(deadline); err != nil {
+		log.WithField("err", err.Error()).Warningln("Feed did not shut down cleanly")
+	}
 }
@@ -0,0 +1,564 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"pirosb3/real_feed/clock"
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/feed"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	ORDER_MARKET = "MARKET"
+	ORDER_LIMIT  = "LIMIT"
+
+	STATUS_OPEN      = "OPEN"
+	STATUS_FILLED    = "FILLED"
+	STATUS_CANCELLED = "CANCELLED"
+)
+
+var (
+	ErrAccountExists       = errors.New("Account already exists")
+	ErrAccountNotFound     = errors.New("Account cannot be found")
+	ErrOrderNotFound       = errors.New("Order cannot be found")
+	ErrOrderInvalid        = errors.New("Order is invalid")
+	ErrOrderClosed         = errors.New("Order is no longer open")
+	ErrInsufficientBalance = errors.New("Account balance is insufficient for the order")
+)
+
+var paperFillsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name:      "paperFills",
+	Help:      "Counts simulated fills by side and liquidity",
+	Namespace: "feed",
+}, []string{"market", "side", "liquidity"})
+
+// Book is the view of a live orderbook the engine matches against.
+type Book interface {
+	Ready() error
+	BestBidAsk() (float64, float64, bool)
+	WalkLevels(side string, visit func(key string, price float64, size float64) bool) error
+}
+
+type Fill struct {
+	OrderID string
+	Price   float64
+	Size    float64
+	// Maker is true when a resting limit order was filled, false when the order took liquidity.
+	Maker bool
+	Time  time.Time
+}
+
+type Order struct {
+	ID         string
+	AccountID  string
+	Type       string
+	Side       string
+	Size       float64
+	LimitPrice float64
+	Filled     float64
+	Notional   float64
+	Status     string
+	CreatedAt  time.Time
+	Fills      []Fill
+}
+
+// AveragePrice returns the average price of the fills, or zero if nothing was filled.
+func (o *Order) AveragePrice() float64 {
+	if o.Filled <= 0 {
+		return 0
+	}
+	return o.Notional / o.Filled
+}
+
+func (o *Order) remaining() float64 {
+	return o.Size - o.Filled
+}
+
+// Account holds the balances of the base and quote assets of the engine's product. Balances held
+// by resting limit orders are not available to other orders.
+type Account struct {
+	ID          string
+	Base        float64
+	Quote       float64
+	HeldBase    float64
+	HeldQuote   float64
+	AverageCost float64
+	RealizedPnL float64
+}
+
+// Position returns the base held by the account, including base held by resting orders.
+func (a *Account) Position() float64 {
+	return a.Base + a.HeldBase
+}
+
+// AccountSummary is a copy of an account, with its position marked to the mid price.
+type AccountSummary struct {
+	Account
+	Mid           float64
+	UnrealizedPnL float64
+}
+
+// consumption is the size taken virtually from a price level. It only applies while the level
+// still shows the size it had when it was consumed, any update to the level replaces it.
+type consumption struct {
+	size      float64
+	displayed float64
+}
+
+type level struct {
+	key   string
+	price float64
+	size  float64
+}
+
+// Engine simulates matching orders against a live orderbook without sending them to the exchange.
+// Liquidity taken by simulated orders is deducted from the book until the exchange updates the
+// level, so successive orders see the impact of earlier ones.
+type Engine struct {
+	ProductID   string
+	book        Book
+	lock        sync.Mutex
+	accounts    map[string]*Account
+	orders      map[string]*Order
+	history     []*Order
+	resting     []*Order
+	consumed    map[string]map[string]*consumption
+	nextOrderID int
+	clock       clock.Clock
+}
+
+// NewEngine creates a paper trading engine matching against `book`.
+func NewEngine(productID string, book Book) *Engine {
+	return &Engine{
+		ProductID: productID,
+		book:      book,
+		accounts:  make(map[string]*Account),
+		orders:    make(map[string]*Order),
+		consumed: map[string]map[string]*consumption{
+			feed.BIDS: make(map[string]*consumption),
+			feed.ASKS: make(map[string]*consumption),
+		},
+		clock: clock.NewReal(),
+	}
+}
+
+// SetClock replaces the clock used to timestamp orders and fills.
+func (e *Engine) SetClock(clock clock.Clock) {
+	e.clock = clock
+}
+
+// CreateAccount opens an account funded with `base` and `quote`.
+func (e *Engine) CreateAccount(id string, base float64, quote float64) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if id == "" || base < 0 || quote < 0 {
+		return errors.New("Account is invalid")
+	}
+	if _, ok := e.accounts[id]; ok {
+		return ErrAccountExists
+	}
+	account := &Account{ID: id, Base: base, Quote: quote}
+	if base > 0 {
+		// Deposited base is valued at mid, so that P&L only reflects trading
+		if bestBid, bestAsk, ok := e.book.BestBidAsk(); ok {
+			account.AverageCost = (bestBid + bestAsk) / 2
+		}
+	}
+	e.accounts[id] = account
+	return nil
+}
+
+// GetAccount returns a copy of an account, with unrealized P&L marked to the current mid.
+func (e *Engine) GetAccount(id string) (*AccountSummary, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	account, ok := e.accounts[id]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	summary := &AccountSummary{Account: *account}
+	if bestBid, bestAsk, ok := e.book.BestBidAsk(); ok {
+		summary.Mid = (bestBid + bestAsk) / 2
+		summary.UnrealizedPnL = account.Position() * (summary.Mid - account.AverageCost)
+	}
+	return summary, nil
+}
+
+func copyOrder(order *Order) *Order {
+	orderCopy := *order
+	orderCopy.Fills = append([]Fill(nil), order.Fills...)
+	return &orderCopy
+}
+
+// Orders returns a copy of the orders of an account, oldest first.
+func (e *Engine) Orders(accountID string, openOnly bool) []*Order {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var result []*Order
+	for _, order := range e.history {
+		if order.AccountID != accountID || (openOnly && order.Status != STATUS_OPEN) {
+			continue
+		}
+		result = append(result, copyOrder(order))
+	}
+	return result
+}
+
+// walkLevels calls `visit` with the levels of the side of the book an order takes liquidity from,
+// net of virtual consumption, best first, until it returns false. Only the levels reached are
+// read, and the book is not copied.
+func (e *Engine) walkLevels(side string, visit func(lvl level) bool) error {
+	bookSide := feed.ASKS
+	if side == feed.SELL {
+		bookSide = feed.BIDS
+	}
+	return e.book.WalkLevels(bookSide, func(key string, price float64, size float64) bool {
+		if consumed, ok := e.consumed[bookSide][key]; ok {
+			if consumed.displayed != size {
+				delete(e.consumed[bookSide], key)
+			} else {
+				size -= consumed.size
+			}
+		}
+		if size <= 0 {
+			return true
+		}
+		return visit(level{key: key, price: price, size: size})
+	})
+}
+
+// consume records that `size` was taken from a level, whose displayed size is `displayed`.
+func (e *Engine) consume(side string, key string, size float64, displayed float64) {
+	bookSide := feed.ASKS
+	if side == feed.SELL {
+		bookSide = feed.BIDS
+	}
+	consumed, ok := e.consumed[bookSide][key]
+	if !ok {
+		consumed = &consumption{displayed: displayed}
+		e.consumed[bookSide][key] = consumed
+	}
+	consumed.size += size
+}
+
+// crosses returns true if a level at `price` can fill an order on `side` limited to `limit`.
+func crosses(side string, price float64, limit float64) bool {
+	if limit <= 0 {
+		return true
+	}
+	if side == feed.BUY {
+		return price <= limit
+	}
+	return price >= limit
+}
+
+// taking is the size a fill takes from a level of the book, whose displayed size is `displayed`.
+type taking struct {
+	key       string
+	size      float64
+	displayed float64
+}
+
+// match walks the book for up to `size`, without going through `limit`, in a single pass. It
+// returns the fills at the price of every level reached, and the liquidity they take, which is
+// only consumed once passed to `take`.
+func (e *Engine) match(side string, size float64, limit float64) ([]Fill, []taking, error) {
+	var fills []Fill
+	var takings []taking
+	remaining := size
+	err := e.walkLevels(side, func(lvl level) bool {
+		if remaining <= 0 || !crosses(side, lvl.price, limit) {
+			return false
+		}
+		taken := lvl.size
+		if taken > remaining {
+			taken = remaining
+		}
+		remaining -= taken
+		fills = append(fills, Fill{Price: lvl.price, Size: taken})
+		takings = append(takings, taking{key: lvl.key, size: taken, displayed: e.displayed(side, lvl)})
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return fills, takings, nil
+}
+
+// take consumes the liquidity of fills returned by `match`.
+func (e *Engine) take(side string, takings []taking) {
+	for _, t := range takings {
+		e.consume(side, t.key, t.size, t.displayed)
+	}
+}
+
+// displayed returns the size shown by the exchange for a level, before virtual consumption.
+func (e *Engine) displayed(side string, lvl level) float64 {
+	bookSide := feed.ASKS
+	if side == feed.SELL {
+		bookSide = feed.BIDS
+	}
+	if consumed, ok := e.consumed[bookSide][lvl.key]; ok {
+		return lvl.size + consumed.size
+	}
+	return lvl.size
+}
+
+// applyFill updates the order and its account. Limit orders release the balance held for the
+// filled size.
+func (e *Engine) applyFill(order *Order, fill Fill) {
+	account := e.accounts[order.AccountID]
+	fill.OrderID = order.ID
+	fill.Time = e.clock.Now()
+	order.Fills = append(order.Fills, fill)
+	order.Filled += fill.Size
+	order.Notional += fill.Size * fill.Price
+
+	notional := fill.Size * fill.Price
+	if order.Side == feed.BUY {
+		if order.Type == ORDER_LIMIT {
+			account.HeldQuote -= fill.Size * order.LimitPrice
+			account.Quote += fill.Size*order.LimitPrice - notional
+		} else {
+			account.Quote -= notional
+		}
+		position := account.Position()
+		account.AverageCost = (position*account.AverageCost + notional) / (position + fill.Size)
+		account.Base += fill.Size
+	} else {
+		if order.Type == ORDER_LIMIT {
+			account.HeldBase -= fill.Size
+		} else {
+			account.Base -= fill.Size
+		}
+		account.Quote += notional
+		account.RealizedPnL += fill.Size * (fill.Price - account.AverageCost)
+	}
+
+	if order.remaining() <= 0 {
+		order.Status = STATUS_FILLED
+	}
+	liquidity := "taker"
+	if fill.Maker {
+		liquidity = "maker"
+	}
+	paperFillsCounter.WithLabelValues(e.ProductID, order.Side, liquidity).Inc()
+}
+
+// PlaceOrder submits a market or limit order of `size` base. Market orders fill immediately by
+// walking the book, and are rejected if the book cannot fill them entirely. Limit orders fill
+// what they can immediately and rest until the book or a trade crosses their price.
+func (e *Engine) PlaceOrder(accountID string, orderType string, side string, size float64, limitPrice float64) (*Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	account, ok := e.accounts[accountID]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	if size <= 0 || (side != feed.BUY && side != feed.SELL) ||
+		(orderType == ORDER_LIMIT && limitPrice <= 0) || (orderType != ORDER_LIMIT && orderType != ORDER_MARKET) {
+		return nil, ErrOrderInvalid
+	}
+	if err := e.book.Ready(); err != nil {
+		return nil, err
+	}
+
+	e.nextOrderID++
+	order := &Order{
+		ID:         strconv.Itoa(e.nextOrderID),
+		AccountID:  accountID,
+		Type:       orderType,
+		Side:       side,
+		Size:       size,
+		LimitPrice: limitPrice,
+		Status:     STATUS_OPEN,
+		CreatedAt:  e.clock.Now(),
+	}
+
+	if orderType == ORDER_MARKET {
+		// The balance is checked against the fills of the same walk that commits them, so the
+		// book cannot change in between
+		fills, takings, err := e.match(side, size, 0)
+		if err != nil {
+			return nil, err
+		}
+		filled, notional := 0.0, 0.0
+		for _, fill := range fills {
+			filled += fill.Size
+			notional += fill.Size * fill.Price
+		}
+		if filled < size {
+			return nil, &feed.LiquidityError{Requested: size, Available: filled}
+		}
+		if (side == feed.BUY && notional > account.Quote) || (side == feed.SELL && size > account.Base) {
+			return nil, ErrInsufficientBalance
+		}
+		e.take(side, takings)
+		for _, fill := range fills {
+			e.applyFill(order, fill)
+		}
+		e.addOrder(order)
+		return copyOrder(order), nil
+	}
+
+	// Limit orders hold the balance they need at their limit price until filled or cancelled
+	if side == feed.BUY {
+		if size*limitPrice > account.Quote {
+			return nil, ErrInsufficientBalance
+		}
+		account.Quote -= size * limitPrice
+		account.HeldQuote += size * limitPrice
+	} else {
+		if size > account.Base {
+			return nil, ErrInsufficientBalance
+		}
+		account.Base -= size
+		account.HeldBase += size
+	}
+	e.addOrder(order)
+
+	fills, takings, err := e.match(side, size, limitPrice)
+	if err == nil {
+		e.take(side, takings)
+		for _, fill := range fills {
+			e.applyFill(order, fill)
+		}
+	}
+	if order.Status == STATUS_OPEN {
+		e.resting = append(e.resting, order)
+	}
+	return copyOrder(order), nil
+}
+
+func (e *Engine) addOrder(order *Order) {
+	e.orders[order.ID] = order
+	e.history = append(e.history, order)
+}
+
+// CancelOrder cancels a resting limit order and releases the balance it held.
+func (e *Engine) CancelOrder(accountID string, orderID string) (*Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	order, ok := e.orders[orderID]
+	if !ok || order.AccountID != accountID {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != STATUS_OPEN {
+		return nil, ErrOrderClosed
+	}
+	account := e.accounts[accountID]
+	if order.Side == feed.BUY {
+		account.HeldQuote -= order.remaining() * order.LimitPrice
+		account.Quote += order.remaining() * order.LimitPrice
+	} else {
+		account.HeldBase -= order.remaining()
+		account.Base += order.remaining()
+	}
+	order.Status = STATUS_CANCELLED
+	e.removeResting()
+	return copyOrder(order), nil
+}
+
+// removeResting drops the orders that are no longer open from the resting queue.
+func (e *Engine) removeResting() {
+	resting := e.resting[:0]
+	for _, order := range e.resting {
+		if order.Status == STATUS_OPEN {
+			resting = append(resting, order)
+		}
+	}
+	e.resting = resting
+}
+
+// OnBookUpdate fills resting limit orders the book has moved through. They fill at their limit
+// price, as the exchange would have matched them on the way.
+func (e *Engine) OnBookUpdate() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if len(e.resting) == 0 {
+		return
+	}
+	bestBid, bestAsk, ok := e.book.BestBidAsk()
+	if !ok || e.book.Ready() != nil {
+		return
+	}
+	for _, order := range e.resting {
+		if (order.Side == feed.BUY && bestAsk >= order.LimitPrice) || (order.Side == feed.SELL && bestBid <= order.LimitPrice) {
+			continue
+		}
+		fills, takings, err := e.match(order.Side, order.remaining(), order.LimitPrice)
+		if err != nil {
+			return
+		}
+		e.take(order.Side, takings)
+		for _, fill := range fills {
+			e.applyFill(order, Fill{Price: order.LimitPrice, Size: fill.Size, Maker: true})
+		}
+	}
+	e.removeResting()
+}
+
+// OnTrade fills resting limit orders a trade printed through, in the order they were placed, up
+// to the size of the trade. Only orders on the side the aggressor traded against are filled: a
+// sell fills resting buys, and a buy fills resting sells. A trade exactly at the limit price is
+// not enough, as the order would be queued behind the liquidity already resting at that price.
+func (e *Engine) OnTrade(trade *feed.Trade) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	available := trade.Size
+	for _, order := range e.resting {
+		if available <= 0 {
+			break
+		}
+		if order.Side == trade.Side {
+			continue
+		}
+		if (order.Side == feed.BUY && trade.Price >= order.LimitPrice) || (order.Side == feed.SELL && trade.Price <= order.LimitPrice) {
+			continue
+		}
+		size := order.remaining()
+		if size > available {
+			size = available
+		}
+		available -= size
+		e.applyFill(order, Fill{Price: order.LimitPrice, Size: size, Maker: true})
+	}
+	e.removeResting()
+}
+
+// Run matches resting orders on every book update and trade published by a feed controller,
+// until `ctx` is cancelled or the subscription is closed.
+func (e *Engine) Run(ctx context.Context, events <-chan (*controller.FeedEvent)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case controller.EVENT_BOOK_UPDATE:
+				e.OnBookUpdate()
+			case controller.EVENT_TRADE:
+				if event.Trade != nil {
+					e.OnTrade(event.Trade)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package paper
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/feed"
+)
+
+func newTestBook() *feed.OrderbookFeed {
+	ob := feed.NewOrderbookFeed("ETH-USD")
+	ob.SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "99", Size: "1"},
+		&feed.Update{Price: "98", Size: "2"},
+	}, []*feed.Update{
+		&feed.Update{Price: "101", Size: "1"},
+		&feed.Update{Price: "102", Size: "2"},
+	})
+	return ob
+}
+
+func TestMarketOrdersConsumeLiquidity(t *testing.T) {
+	ob := newTestBook()
+	engine := NewEngine("ETH-USD", ob)
+	engine.CreateAccount("alice", 0, 1000)
+
+	order, err := engine.PlaceOrder("alice", ORDER_MARKET, feed.BUY, 1, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if order.Status != STATUS_FILLED || order.AveragePrice() != 101 {
+		t.Errorf("Expected a fill at 101, got %s at %f", order.Status, order.AveragePrice())
+	}
+
+	// The best ask was consumed by the first order
+	order, _ = engine.PlaceOrder("alice", ORDER_MARKET, feed.BUY, 1, 0)
+	if order.AveragePrice() != 102 {
+		t.Errorf("Expected the second order to fill at 102, got %f", order.AveragePrice())
+	}
+
+	// The exchange replenishing the level resets the virtual consumption
+	ob.WriteUpdate(time.Now().Unix(), nil, []*feed.Update{&feed.Update{Price: "101", Size: "3"}})
+	order, _ = engine.PlaceOrder("alice", ORDER_MARKET, feed.BUY, 1, 0)
+	if order.AveragePrice() != 101 {
+		t.Errorf("Expected the replenished level to fill at 101, got %f", order.AveragePrice())
+	}
+
+	if _, err := engine.PlaceOrder("alice", ORDER_MARKET, feed.BUY, 6, 0); err == nil {
+		t.Error("Expected the order to exceed the balance")
+	}
+
+	account, _ := engine.GetAccount("alice")
+	if account.Base != 3 || account.Quote != 1000-101-102-101 {
+		t.Errorf("Unexpected balances %f %f", account.Base, account.Quote)
+	}
+	if math.Abs(account.AverageCost-304.0/3) > 1e-9 {
+		t.Errorf("Unexpected average cost %f", account.AverageCost)
+	}
+	if math.Abs(account.UnrealizedPnL-3*(100-304.0/3)) > 1e-9 {
+		t.Errorf("Unexpected unrealized P&L %f", account.UnrealizedPnL)
+	}
+}
+
+func TestLimitOrdersRestAndFill(t *testing.T) {
+	ob := newTestBook()
+	engine := NewEngine("ETH-USD", ob)
+	engine.CreateAccount("bob", 2, 1000)
+
+	buy, _ := engine.PlaceOrder("bob", ORDER_LIMIT, feed.BUY, 1, 100)
+	if buy.Status != STATUS_OPEN {
+		t.Fatalf("Expected the order to rest, got %s", buy.Status)
+	}
+	account, _ := engine.GetAccount("bob")
+	if account.HeldQuote != 100 || account.Quote != 900 {
+		t.Errorf("Expected 100 to be held, got %f", account.HeldQuote)
+	}
+
+	// A buyer lifting offers does not trade with a resting buy
+	engine.OnTrade(&feed.Trade{Price: 99.5, Size: 0.4, Side: feed.BUY})
+	if orders := engine.Orders("bob", true); len(orders) != 1 || orders[0].Filled != 0 {
+		t.Fatalf("Expected a buy trade not to fill the order, got %+v", orders)
+	}
+
+	// A trade through the limit fills part of the order at the limit price
+	engine.OnTrade(&feed.Trade{Price: 99.5, Size: 0.4, Side: feed.SELL})
+	orders := engine.Orders("bob", true)
+	if len(orders) != 1 || orders[0].Filled != 0.4 || orders[0].Fills[0].Price != 100 || !orders[0].Fills[0].Maker {
+		t.Fatalf("Expected a maker fill of 0.4 at 100, got %+v", orders)
+	}
+
+	// The book moving through the limit fills the rest
+	ob.WriteUpdate(time.Now().Unix(), []*feed.Update{&feed.Update{Price: "99", Size: "0"}}, []*feed.Update{&feed.Update{Price: "99.8", Size: "5"}})
+	engine.OnBookUpdate()
+	if orders := engine.Orders("bob", true); len(orders) != 0 {
+		t.Errorf("Expected the order to be filled, got %+v", orders)
+	}
+
+	sell, _ := engine.PlaceOrder("bob", ORDER_LIMIT, feed.SELL, 2, 110)
+	account, _ = engine.GetAccount("bob")
+	if account.HeldBase != 2 || account.Base != 1 {
+		t.Errorf("Expected 2 base to be held, got %f", account.HeldBase)
+	}
+	engine.CancelOrder("bob", sell.ID)
+	account, _ = engine.GetAccount("bob")
+	if account.HeldBase != 0 || account.Base != 3 || account.Quote != 900 {
+		t.Errorf("Expected the held base to be released, got %+v", account)
+	}
+
+	// A marketable limit order fills immediately, but never through its price
+	sell, _ = engine.PlaceOrder("bob", ORDER_LIMIT, feed.SELL, 3, 98)
+	if sell.Filled != 2 || sell.Status != STATUS_OPEN {
+		t.Errorf("Expected 2 to fill against the bid at 98, got %f", sell.Filled)
+	}
+	account, _ = engine.GetAccount("bob")
+	if account.RealizedPnL >= 0 {
+		t.Errorf("Expected selling below cost to realize a loss, got %f", account.RealizedPnL)
+	}
+}
+
+// changingBook removes the best ask after every walk, as if the exchange updated the book while
+// an order was being matched.
+type changingBook struct {
+	*feed.OrderbookFeed
+}
+
+func (b *changingBook) WalkLevels(side string, visit func(key string, price float64, size float64) bool) error {
+	err := b.OrderbookFeed.WalkLevels(side, visit)
+	b.OrderbookFeed.WriteUpdate(time.Now().Unix(), nil, []*feed.Update{&feed.Update{Price: "101", Size: "0"}})
+	return err
+}
+
+func TestMarketOrdersFillFromTheWalkTheyAreCheckedWith(t *testing.T) {
+	engine := NewEngine("ETH-USD", &changingBook{newTestBook()})
+	engine.CreateAccount("alice", 0, 101)
+
+	order, err := engine.PlaceOrder("alice", ORDER_MARKET, feed.BUY, 1, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if order.Status != STATUS_FILLED || order.Filled != 1 || order.AveragePrice() != 101 {
+		t.Errorf("Expected a full fill at 101, got %+v", order)
+	}
+	account, _ := engine.GetAccount("alice")
+	if account.Quote != 0 || account.Base != 1 {
+		t.Errorf("Unexpected balances %f %f", account.Base, account.Quote)
+	}
+}
@@ -0,0 +1,111 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+
+	"pirosb3/real_feed/rpc"
+)
+
+type PaperGrpcController struct {
+	rpc.UnimplementedPaperTradingServiceServer
+	engine *Engine
+}
+
+func NewPaperGrpcController(engine *Engine) *PaperGrpcController {
+	return &PaperGrpcController{engine: engine}
+}
+
+func toRPCOrder(order *Order) *rpc.PaperOrder {
+	result := &rpc.PaperOrder{
+		OrderId:      order.ID,
+		AccountId:    order.AccountID,
+		Type:         order.Type,
+		Side:         order.Side,
+		Size:         order.Size,
+		LimitPrice:   order.LimitPrice,
+		Filled:       order.Filled,
+		AveragePrice: order.AveragePrice(),
+		Status:       order.Status,
+		CreatedAt:    order.CreatedAt.UnixNano() / 1e6,
+		Fills:        make([]*rpc.PaperFill, len(order.Fills)),
+	}
+	for idx, fill := range order.Fills {
+		result.Fills[idx] = &rpc.PaperFill{
+			Price:     fill.Price,
+			Size:      fill.Size,
+			Maker:     fill.Maker,
+			Timestamp: fill.Time.UnixNano() / 1e6,
+		}
+	}
+	return result
+}
+
+func (pc PaperGrpcController) accountResponse(accountID string) (*rpc.AccountResponse, error) {
+	summary, err := pc.engine.GetAccount(accountID)
+	if err != nil {
+		return &rpc.AccountResponse{
+			AccountId: accountID,
+			Product:   pc.engine.ProductID,
+			Error:     err.Error(),
+		}, nil
+	}
+	return &rpc.AccountResponse{
+		AccountId:     accountID,
+		Product:       pc.engine.ProductID,
+		Base:          summary.Base,
+		Quote:         summary.Quote,
+		HeldBase:      summary.HeldBase,
+		HeldQuote:     summary.HeldQuote,
+		Position:      summary.Position(),
+		AverageCost:   summary.AverageCost,
+		RealizedPnl:   summary.RealizedPnL,
+		UnrealizedPnl: summary.UnrealizedPnL,
+		Mid:           summary.Mid,
+	}, nil
+}
+
+func (pc PaperGrpcController) CreateAccount(ctx context.Context, in *rpc.CreateAccountRequest) (*rpc.AccountResponse, error) {
+	if err := pc.engine.CreateAccount(in.GetAccountId(), in.GetBase(), in.GetQuote()); err != nil {
+		return &rpc.AccountResponse{
+			AccountId: in.GetAccountId(),
+			Product:   pc.engine.ProductID,
+			Error:     err.Error(),
+		}, nil
+	}
+	return pc.accountResponse(in.GetAccountId())
+}
+
+func (pc PaperGrpcController) GetAccount(ctx context.Context, in *rpc.AccountRequest) (*rpc.AccountResponse, error) {
+	return pc.accountResponse(in.GetAccountId())
+}
+
+func (pc PaperGrpcController) PlaceOrder(ctx context.Context, in *rpc.PlaceOrderRequest) (*rpc.OrderResponse, error) {
+	if in.GetProduct() != pc.engine.ProductID {
+		return &rpc.OrderResponse{
+			Error: fmt.Sprintf("Requested order for feed '%s', but service is serving feed '%s'", in.GetProduct(), pc.engine.ProductID),
+		}, nil
+	}
+	order, err := pc.engine.PlaceOrder(in.GetAccountId(), in.GetType(), in.GetSide(), in.GetSize(), in.GetLimitPrice())
+	if err != nil {
+		return &rpc.OrderResponse{Error: err.Error()}, nil
+	}
+	return &rpc.OrderResponse{Order: toRPCOrder(order)}, nil
+}
+
+func (pc PaperGrpcController) CancelOrder(ctx context.Context, in *rpc.CancelOrderRequest) (*rpc.OrderResponse, error) {
+	order, err := pc.engine.CancelOrder(in.GetAccountId(), in.GetOrderId())
+	if err != nil {
+		return &rpc.OrderResponse{Error: err.Error()}, nil
+	}
+	return &rpc.OrderResponse{Order: toRPCOrder(order)}, nil
+}
+
+func (pc PaperGrpcController) GetOrders(ctx context.Context, in *rpc.OrdersRequest) (*rpc.OrdersResponse, error) {
+	orders := pc.engine.Orders(in.GetAccountId(), in.GetOpenOnly())
+	response := &rpc.OrdersResponse{Orders: make([]*rpc.PaperOrder, len(orders))}
+	for idx, order := range orders {
+		response.Orders[idx] = toRPCOrder(order)
+	}
+	return response, nil
+}
@@ -0,0 +1,91 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirosb3/real_feed/feed"
+)
+
+// SnapshotStore persists orderbook snapshots so that a restarted process can serve provisional
+// quotes before the websocket delivers a fresh snapshot.
+type SnapshotStore interface {
+	// Save persists the snapshot, replacing the latest snapshot for its product.
+	Save(snapshot *feed.BookSnapshot) error
+	// Load returns the latest snapshot for a product, or nil if none was ever saved.
+	Load(product string) (*feed.BookSnapshot, error)
+}
+
+// FileSnapshotStore keeps the latest snapshot of each product as a JSON file in a directory. When
+// archiving is enabled, every snapshot is also kept under `archive/<product>/` for later audits.
+type FileSnapshotStore struct {
+	dir     string
+	archive bool
+}
+
+// NewFileSnapshotStore creates a store writing to `dir`, creating it if it does not exist.
+func NewFileSnapshotStore(dir string, archive bool) (*FileSnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileSnapshotStore{
+		dir:     dir,
+		archive: archive,
+	}, nil
+}
+
+func (fs *FileSnapshotStore) latestPath(product string) string {
+	return filepath.Join(fs.dir, product+".json")
+}
+
+// writeFileAtomic writes to a temporary file first so that a crash never leaves a truncated snapshot.
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func (fs *FileSnapshotStore) Save(snapshot *feed.BookSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if fs.archive {
+		archiveDir := filepath.Join(fs.dir, "archive", snapshot.ProductID)
+		if err := os.MkdirAll(archiveDir, 0755); err != nil {
+			return err
+		}
+		archivePath := filepath.Join(archiveDir, fmt.Sprintf("%d.json", snapshot.Timestamp.UnixNano()))
+		if err := writeFileAtomic(archivePath, data); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(fs.latestPath(snapshot.ProductID), data)
+}
+
+func (fs *FileSnapshotStore) Load(product string) (*feed.BookSnapshot, error) {
+	data, err := os.ReadFile(fs.latestPath(product))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot feed.BookSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,47 @@
+package persistence
+
+import (
+	"os"
+	"path/filepath"
+	"pirosb3/real_feed/feed"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSnapshotStore(dir, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	snapshot, err := store.Load("ETH-DAI")
+	if snapshot != nil || err != nil {
+		t.Fatalf("Expected no snapshot, got %v (%v)", snapshot, err)
+	}
+
+	ob := feed.NewOrderbookFeed("ETH-DAI")
+	ob.SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "333.2", Size: "0.5"},
+		&feed.Update{Price: "320", Size: "0"},
+	}, []*feed.Update{
+		&feed.Update{Price: "335.12", Size: "0.5"},
+	})
+	saved, _ := ob.Snapshot()
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	loaded, err := store.Load("ETH-DAI")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if loaded.Version != saved.Version || loaded.Epoch != saved.Epoch || len(loaded.Bids) != 1 || len(loaded.Asks) != 1 {
+		t.Errorf("Loaded snapshot does not match %+v", loaded)
+	}
+
+	archived, _ := os.ReadDir(filepath.Join(dir, "archive", "ETH-DAI"))
+	if len(archived) != 1 {
+		t.Errorf("Expected 1 archived snapshot, got %d", len(archived))
+	}
+}
@@ -23,6 +23,21 @@ type OrderbookServiceClient interface {
 	BuyQuote(ctx context.Context, in *PricingRequest, opts ...grpc.CallOption) (*PricingResponse, error)
 	SellBase(ctx context.Context, in *PricingRequest, opts ...grpc.CallOption) (*PricingResponse, error)
 	SellQuote(ctx context.Context, in *PricingRequest, opts ...grpc.CallOption) (*PricingResponse, error)
+	GetRecentTrades(ctx context.Context, in *TradesRequest, opts ...grpc.CallOption) (*TradesResponse, error)
+	StreamTrades(ctx context.Context, in *TradesRequest, opts ...grpc.CallOption) (OrderbookService_StreamTradesClient, error)
+	QuoteAt(ctx context.Context, in *HistoricalPricingRequest, opts ...grpc.CallOption) (*PricingResponse, error)
+	GetCandles(ctx context.Context, in *CandlesRequest, opts ...grpc.CallOption) (*CandlesResponse, error)
+	StreamCandles(ctx context.Context, in *CandlesRequest, opts ...grpc.CallOption) (OrderbookService_StreamCandlesClient, error)
+	GetLiquidityProfile(ctx context.Context, in *LiquidityRequest, opts ...grpc.CallOption) (*LiquidityProfileResponse, error)
+	GetImpactCurve(ctx context.Context, in *ImpactCurveRequest, opts ...grpc.CallOption) (*ImpactCurveResponse, error)
+	BuyBaseExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error)
+	BuyQuoteExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error)
+	SellBaseExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error)
+	SellQuoteExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error)
+	RequestQuote(ctx context.Context, in *FirmQuoteRequest, opts ...grpc.CallOption) (*FirmQuoteResponse, error)
+	AcceptQuote(ctx context.Context, in *AcceptQuoteRequest, opts ...grpc.CallOption) (*AcceptQuoteResponse, error)
+	GetTicker(ctx context.Context, in *TickerRequest, opts ...grpc.CallOption) (*TickerResponse, error)
+	GetDepth(ctx context.Context, in *DepthRequest, opts ...grpc.CallOption) (*DepthResponse, error)
 }
 
 type orderbookServiceClient struct {
@@ -69,6 +84,187 @@ func (c *orderbookServiceClient) SellQuote(ctx context.Context, in *PricingReque
 	return out, nil
 }
 
+func (c *orderbookServiceClient) GetRecentTrades(ctx context.Context, in *TradesRequest, opts ...grpc.CallOption) (*TradesResponse, error) {
+	out := new(TradesResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/GetRecentTrades", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) StreamTrades(ctx context.Context, in *TradesRequest, opts ...grpc.CallOption) (OrderbookService_StreamTradesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_OrderbookService_serviceDesc.Streams[0], "/OrderbookService/StreamTrades", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderbookServiceStreamTradesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderbookService_StreamTradesClient interface {
+	Recv() (*Trade, error)
+	grpc.ClientStream
+}
+
+type orderbookServiceStreamTradesClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderbookServiceStreamTradesClient) Recv() (*Trade, error) {
+	m := new(Trade)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderbookServiceClient) QuoteAt(ctx context.Context, in *HistoricalPricingRequest, opts ...grpc.CallOption) (*PricingResponse, error) {
+	out := new(PricingResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/QuoteAt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) GetCandles(ctx context.Context, in *CandlesRequest, opts ...grpc.CallOption) (*CandlesResponse, error) {
+	out := new(CandlesResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/GetCandles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) StreamCandles(ctx context.Context, in *CandlesRequest, opts ...grpc.CallOption) (OrderbookService_StreamCandlesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_OrderbookService_serviceDesc.Streams[1], "/OrderbookService/StreamCandles", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderbookServiceStreamCandlesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderbookService_StreamCandlesClient interface {
+	Recv() (*Candle, error)
+	grpc.ClientStream
+}
+
+type orderbookServiceStreamCandlesClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderbookServiceStreamCandlesClient) Recv() (*Candle, error) {
+	m := new(Candle)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderbookServiceClient) GetLiquidityProfile(ctx context.Context, in *LiquidityRequest, opts ...grpc.CallOption) (*LiquidityProfileResponse, error) {
+	out := new(LiquidityProfileResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/GetLiquidityProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) GetImpactCurve(ctx context.Context, in *ImpactCurveRequest, opts ...grpc.CallOption) (*ImpactCurveResponse, error) {
+	out := new(ImpactCurveResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/GetImpactCurve", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) BuyBaseExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error) {
+	out := new(ExactOutResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/BuyBaseExactOut", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) BuyQuoteExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error) {
+	out := new(ExactOutResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/BuyQuoteExactOut", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) SellBaseExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error) {
+	out := new(ExactOutResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/SellBaseExactOut", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) SellQuoteExactOut(ctx context.Context, in *ExactOutRequest, opts ...grpc.CallOption) (*ExactOutResponse, error) {
+	out := new(ExactOutResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/SellQuoteExactOut", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) RequestQuote(ctx context.Context, in *FirmQuoteRequest, opts ...grpc.CallOption) (*FirmQuoteResponse, error) {
+	out := new(FirmQuoteResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/RequestQuote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) AcceptQuote(ctx context.Context, in *AcceptQuoteRequest, opts ...grpc.CallOption) (*AcceptQuoteResponse, error) {
+	out := new(AcceptQuoteResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/AcceptQuote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) GetTicker(ctx context.Context, in *TickerRequest, opts ...grpc.CallOption) (*TickerResponse, error) {
+	out := new(TickerResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/GetTicker", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) GetDepth(ctx context.Context, in *DepthRequest, opts ...grpc.CallOption) (*DepthResponse, error) {
+	out := new(DepthResponse)
+	err := c.cc.Invoke(ctx, "/OrderbookService/GetDepth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // OrderbookServiceServer is the server API for OrderbookService service.
 // All implementations must embed UnimplementedOrderbookServiceServer
 // for forward compatibility
@@ -78,6 +274,21 @@ type OrderbookServiceServer interface {
 	BuyQuote(context.Context, *PricingRequest) (*PricingResponse, error)
 	SellBase(context.Context, *PricingRequest) (*PricingResponse, error)
 	SellQuote(context.Context, *PricingRequest) (*PricingResponse, error)
+	GetRecentTrades(context.Context, *TradesRequest) (*TradesResponse, error)
+	StreamTrades(*TradesRequest, OrderbookService_StreamTradesServer) error
+	QuoteAt(context.Context, *HistoricalPricingRequest) (*PricingResponse, error)
+	GetCandles(context.Context, *CandlesRequest) (*CandlesResponse, error)
+	StreamCandles(*CandlesRequest, OrderbookService_StreamCandlesServer) error
+	GetLiquidityProfile(context.Context, *LiquidityRequest) (*LiquidityProfileResponse, error)
+	GetImpactCurve(context.Context, *ImpactCurveRequest) (*ImpactCurveResponse, error)
+	BuyBaseExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error)
+	BuyQuoteExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error)
+	SellBaseExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error)
+	SellQuoteExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error)
+	RequestQuote(context.Context, *FirmQuoteRequest) (*FirmQuoteResponse, error)
+	AcceptQuote(context.Context, *AcceptQuoteRequest) (*AcceptQuoteResponse, error)
+	GetTicker(context.Context, *TickerRequest) (*TickerResponse, error)
+	GetDepth(context.Context, *DepthRequest) (*DepthResponse, error)
 	mustEmbedUnimplementedOrderbookServiceServer()
 }
 
@@ -97,6 +308,51 @@ func (UnimplementedOrderbookServiceServer) SellBase(context.Context, *PricingReq
 func (UnimplementedOrderbookServiceServer) SellQuote(context.Context, *PricingRequest) (*PricingResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SellQuote not implemented")
 }
+func (UnimplementedOrderbookServiceServer) GetRecentTrades(context.Context, *TradesRequest) (*TradesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecentTrades not implemented")
+}
+func (UnimplementedOrderbookServiceServer) StreamTrades(*TradesRequest, OrderbookService_StreamTradesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTrades not implemented")
+}
+func (UnimplementedOrderbookServiceServer) QuoteAt(context.Context, *HistoricalPricingRequest) (*PricingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QuoteAt not implemented")
+}
+func (UnimplementedOrderbookServiceServer) GetCandles(context.Context, *CandlesRequest) (*CandlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCandles not implemented")
+}
+func (UnimplementedOrderbookServiceServer) StreamCandles(*CandlesRequest, OrderbookService_StreamCandlesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamCandles not implemented")
+}
+func (UnimplementedOrderbookServiceServer) GetLiquidityProfile(context.Context, *LiquidityRequest) (*LiquidityProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLiquidityProfile not implemented")
+}
+func (UnimplementedOrderbookServiceServer) GetImpactCurve(context.Context, *ImpactCurveRequest) (*ImpactCurveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetImpactCurve not implemented")
+}
+func (UnimplementedOrderbookServiceServer) BuyBaseExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuyBaseExactOut not implemented")
+}
+func (UnimplementedOrderbookServiceServer) BuyQuoteExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuyQuoteExactOut not implemented")
+}
+func (UnimplementedOrderbookServiceServer) SellBaseExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SellBaseExactOut not implemented")
+}
+func (UnimplementedOrderbookServiceServer) SellQuoteExactOut(context.Context, *ExactOutRequest) (*ExactOutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SellQuoteExactOut not implemented")
+}
+func (UnimplementedOrderbookServiceServer) RequestQuote(context.Context, *FirmQuoteRequest) (*FirmQuoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestQuote not implemented")
+}
+func (UnimplementedOrderbookServiceServer) AcceptQuote(context.Context, *AcceptQuoteRequest) (*AcceptQuoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptQuote not implemented")
+}
+func (UnimplementedOrderbookServiceServer) GetTicker(context.Context, *TickerRequest) (*TickerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTicker not implemented")
+}
+func (UnimplementedOrderbookServiceServer) GetDepth(context.Context, *DepthRequest) (*DepthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDepth not implemented")
+}
 func (UnimplementedOrderbookServiceServer) mustEmbedUnimplementedOrderbookServiceServer() {}
 
 // UnsafeOrderbookServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -182,6 +438,282 @@ func _OrderbookService_SellQuote_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrderbookService_GetRecentTrades_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TradesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).GetRecentTrades(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/GetRecentTrades",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).GetRecentTrades(ctx, req.(*TradesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_StreamTrades_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TradesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderbookServiceServer).StreamTrades(m, &orderbookServiceStreamTradesServer{stream})
+}
+
+type OrderbookService_StreamTradesServer interface {
+	Send(*Trade) error
+	grpc.ServerStream
+}
+
+type orderbookServiceStreamTradesServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderbookServiceStreamTradesServer) Send(m *Trade) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OrderbookService_QuoteAt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoricalPricingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).QuoteAt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/QuoteAt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).QuoteAt(ctx, req.(*HistoricalPricingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_GetCandles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CandlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).GetCandles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/GetCandles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).GetCandles(ctx, req.(*CandlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_StreamCandles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CandlesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderbookServiceServer).StreamCandles(m, &orderbookServiceStreamCandlesServer{stream})
+}
+
+type OrderbookService_StreamCandlesServer interface {
+	Send(*Candle) error
+	grpc.ServerStream
+}
+
+type orderbookServiceStreamCandlesServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderbookServiceStreamCandlesServer) Send(m *Candle) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OrderbookService_GetLiquidityProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LiquidityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).GetLiquidityProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/GetLiquidityProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).GetLiquidityProfile(ctx, req.(*LiquidityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_GetImpactCurve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImpactCurveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).GetImpactCurve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/GetImpactCurve",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).GetImpactCurve(ctx, req.(*ImpactCurveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_BuyBaseExactOut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExactOutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).BuyBaseExactOut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/BuyBaseExactOut",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).BuyBaseExactOut(ctx, req.(*ExactOutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_BuyQuoteExactOut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExactOutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).BuyQuoteExactOut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/BuyQuoteExactOut",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).BuyQuoteExactOut(ctx, req.(*ExactOutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_SellBaseExactOut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExactOutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).SellBaseExactOut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/SellBaseExactOut",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).SellBaseExactOut(ctx, req.(*ExactOutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_SellQuoteExactOut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExactOutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).SellQuoteExactOut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/SellQuoteExactOut",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).SellQuoteExactOut(ctx, req.(*ExactOutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_RequestQuote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FirmQuoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).RequestQuote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/RequestQuote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).RequestQuote(ctx, req.(*FirmQuoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_AcceptQuote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptQuoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).AcceptQuote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/AcceptQuote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).AcceptQuote(ctx, req.(*AcceptQuoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_GetTicker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TickerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).GetTicker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/GetTicker",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).GetTicker(ctx, req.(*TickerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_GetDepth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DepthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).GetDepth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/OrderbookService/GetDepth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).GetDepth(ctx, req.(*DepthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _OrderbookService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "OrderbookService",
 	HandlerType: (*OrderbookServiceServer)(nil),
@@ -202,7 +734,70 @@ var _OrderbookService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SellQuote",
 			Handler:    _OrderbookService_SellQuote_Handler,
 		},
+		{
+			MethodName: "GetRecentTrades",
+			Handler:    _OrderbookService_GetRecentTrades_Handler,
+		},
+		{
+			MethodName: "QuoteAt",
+			Handler:    _OrderbookService_QuoteAt_Handler,
+		},
+		{
+			MethodName: "GetCandles",
+			Handler:    _OrderbookService_GetCandles_Handler,
+		},
+		{
+			MethodName: "GetLiquidityProfile",
+			Handler:    _OrderbookService_GetLiquidityProfile_Handler,
+		},
+		{
+			MethodName: "GetImpactCurve",
+			Handler:    _OrderbookService_GetImpactCurve_Handler,
+		},
+		{
+			MethodName: "BuyBaseExactOut",
+			Handler:    _OrderbookService_BuyBaseExactOut_Handler,
+		},
+		{
+			MethodName: "BuyQuoteExactOut",
+			Handler:    _OrderbookService_BuyQuoteExactOut_Handler,
+		},
+		{
+			MethodName: "SellBaseExactOut",
+			Handler:    _OrderbookService_SellBaseExactOut_Handler,
+		},
+		{
+			MethodName: "SellQuoteExactOut",
+			Handler:    _OrderbookService_SellQuoteExactOut_Handler,
+		},
+		{
+			MethodName: "RequestQuote",
+			Handler:    _OrderbookService_RequestQuote_Handler,
+		},
+		{
+			MethodName: "AcceptQuote",
+			Handler:    _OrderbookService_AcceptQuote_Handler,
+		},
+		{
+			MethodName: "GetTicker",
+			Handler:    _OrderbookService_GetTicker_Handler,
+		},
+		{
+			MethodName: "GetDepth",
+			Handler:    _OrderbookService_GetDepth_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTrades",
+			Handler:       _OrderbookService_StreamTrades_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamCandles",
+			Handler:       _OrderbookService_StreamCandles_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "service.proto",
 }
\ No newline at end of file
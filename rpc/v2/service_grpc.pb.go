@@ -0,0 +1,138 @@
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package v2
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// OrderbookServiceClient is the client API for OrderbookService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OrderbookServiceClient interface {
+	Quote(ctx context.Context, in *QuoteRequest, opts ...grpc.CallOption) (*QuoteResponse, error)
+	// BatchQuote prices every quote against a single point-in-time view of each book. A quote
+	// that fails does not fail the others, its error is returned in its result instead.
+	BatchQuote(ctx context.Context, in *BatchQuoteRequest, opts ...grpc.CallOption) (*BatchQuoteResponse, error)
+}
+
+type orderbookServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOrderbookServiceClient(cc grpc.ClientConnInterface) OrderbookServiceClient {
+	return &orderbookServiceClient{cc}
+}
+
+func (c *orderbookServiceClient) Quote(ctx context.Context, in *QuoteRequest, opts ...grpc.CallOption) (*QuoteResponse, error) {
+	out := new(QuoteResponse)
+	err := c.cc.Invoke(ctx, "/v2.OrderbookService/Quote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderbookServiceClient) BatchQuote(ctx context.Context, in *BatchQuoteRequest, opts ...grpc.CallOption) (*BatchQuoteResponse, error) {
+	out := new(BatchQuoteResponse)
+	err := c.cc.Invoke(ctx, "/v2.OrderbookService/BatchQuote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OrderbookServiceServer is the server API for OrderbookService service.
+// All implementations must embed UnimplementedOrderbookServiceServer
+// for forward compatibility
+type OrderbookServiceServer interface {
+	Quote(context.Context, *QuoteRequest) (*QuoteResponse, error)
+	// BatchQuote prices every quote against a single point-in-time view of each book. A quote
+	// that fails does not fail the others, its error is returned in its result instead.
+	BatchQuote(context.Context, *BatchQuoteRequest) (*BatchQuoteResponse, error)
+	mustEmbedUnimplementedOrderbookServiceServer()
+}
+
+// UnimplementedOrderbookServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedOrderbookServiceServer struct {
+}
+
+func (UnimplementedOrderbookServiceServer) Quote(context.Context, *QuoteRequest) (*QuoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Quote not implemented")
+}
+func (UnimplementedOrderbookServiceServer) BatchQuote(context.Context, *BatchQuoteRequest) (*BatchQuoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchQuote not implemented")
+}
+func (UnimplementedOrderbookServiceServer) mustEmbedUnimplementedOrderbookServiceServer() {}
+
+// UnsafeOrderbookServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OrderbookServiceServer will
+// result in compilation errors.
+type UnsafeOrderbookServiceServer interface {
+	mustEmbedUnimplementedOrderbookServiceServer()
+}
+
+func RegisterOrderbookServiceServer(s *grpc.Server, srv OrderbookServiceServer) {
+	s.RegisterService(&_OrderbookService_serviceDesc, srv)
+}
+
+func _OrderbookService_Quote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).Quote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v2.OrderbookService/Quote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).Quote(ctx, req.(*QuoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderbookService_BatchQuote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchQuoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderbookServiceServer).BatchQuote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v2.OrderbookService/BatchQuote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderbookServiceServer).BatchQuote(ctx, req.(*BatchQuoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _OrderbookService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "v2.OrderbookService",
+	HandlerType: (*OrderbookServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Quote",
+			Handler:    _OrderbookService_Quote_Handler,
+		},
+		{
+			MethodName: "BatchQuote",
+			Handler:    _OrderbookService_BatchQuote_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "v2/service.proto",
+}
\ No newline at end of file
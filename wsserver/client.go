@@ -0,0 +1,306 @@
+package wsserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pirosb3/real_feed/feed"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	WRITE_TIMEOUT    = 5 * time.Second
+	PONG_TIMEOUT     = 60 * time.Second
+	PING_INTERVAL    = 30 * time.Second
+	MAX_MESSAGE_SIZE = 4096
+	// MAX_CONTROL_MESSAGES bounds the replies queued for a client that does not read them.
+	MAX_CONTROL_MESSAGES = 32
+)
+
+// clientMessage is sent by clients to change their subscriptions.
+type clientMessage struct {
+	Type     string    `json:"type"`
+	Product  string    `json:"product"`
+	Channels []string  `json:"channels"`
+	Sizes    []float64 `json:"sizes,omitempty"`
+}
+
+// controlMessage acknowledges a subscription change or reports an error.
+type controlMessage struct {
+	Type     string   `json:"type"`
+	Product  string   `json:"product,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+type subscription struct {
+	// key identifies the rendered message, subscriptions with the same key receive the same data
+	key     string
+	channel string
+	levels  int
+	sizes   []float64
+}
+
+// parseSubscription parses a channel name: ticker, depth-N or quotes, which uses `sizes`.
+func parseSubscription(name string, sizes []float64) (subscription, error) {
+	switch {
+	case name == CHANNEL_TICKER:
+		return subscription{key: name, channel: CHANNEL_TICKER}, nil
+	case strings.HasPrefix(name, CHANNEL_DEPTH+"-"):
+		levels, err := strconv.Atoi(strings.TrimPrefix(name, CHANNEL_DEPTH+"-"))
+		if err != nil || levels <= 0 || levels > MAX_DEPTH_LEVELS {
+			return subscription{}, fmt.Errorf("Depth must be between 1 and %d levels", MAX_DEPTH_LEVELS)
+		}
+		return subscription{key: name, channel: CHANNEL_DEPTH, levels: levels}, nil
+	case name == CHANNEL_QUOTES:
+		if len(sizes) == 0 || len(sizes) > MAX_QUOTE_SIZES {
+			return subscription{}, fmt.Errorf("Quotes need between 1 and %d sizes", MAX_QUOTE_SIZES)
+		}
+		sorted := append([]float64(nil), sizes...)
+		sort.Float64s(sorted)
+		parts := make([]string, len(sorted))
+		for idx, size := range sorted {
+			if size <= 0 {
+				return subscription{}, feed.ErrInvalidAmount
+			}
+			parts[idx] = strconv.FormatFloat(size, 'f', -1, 64)
+		}
+		return subscription{key: CHANNEL_QUOTES + ":" + strings.Join(parts, ","), channel: CHANNEL_QUOTES, sizes: sorted}, nil
+	}
+	return subscription{}, errors.New("Unsupported channel: " + name)
+}
+
+// client is a connected browser. Updates are conflated: only the latest message of every
+// subscription is kept until the client is ready to receive it.
+type client struct {
+	server *Server
+	// ctx carries the credentials of the client, subscriptions are admitted with them
+	ctx           context.Context
+	conn          *websocket.Conn
+	lock          sync.Mutex
+	subscriptions map[string]subscription
+	// added holds the names of subscriptions which did not receive the current state yet
+	added        map[string]bool
+	pending      map[string][]byte
+	pendingOrder []string
+	control      [][]byte
+	ready        chan (struct{})
+	done         chan (struct{})
+	closeOnce    sync.Once
+}
+
+func newClient(server *Server, conn *websocket.Conn) *client {
+	return &client{
+		server:        server,
+		conn:          conn,
+		subscriptions: make(map[string]subscription),
+		added:         make(map[string]bool),
+		pending:       make(map[string][]byte),
+		ready:         make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *client) notify() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// takeSubscriptions returns the subscriptions to render: all of them if `all` is set, otherwise
+// the ones added since it was last called.
+func (c *client) takeSubscriptions(all bool) []subscription {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := make([]subscription, 0, len(c.subscriptions))
+	for name, sub := range c.subscriptions {
+		if all || c.added[name] {
+			result = append(result, sub)
+		}
+	}
+	c.added = make(map[string]bool)
+	return result
+}
+
+// enqueue replaces any message of the subscription the client did not receive yet.
+func (c *client) enqueue(sub subscription, data []byte) {
+	c.lock.Lock()
+	if _, ok := c.pending[sub.key]; ok {
+		conflatedMessagesCounter.WithLabelValues(c.server.product, sub.channel).Inc()
+	} else {
+		c.pendingOrder = append(c.pendingOrder, sub.key)
+	}
+	c.pending[sub.key] = data
+	c.lock.Unlock()
+	c.notify()
+}
+
+// reply queues a control message. The client is disconnected if it stopped reading them.
+func (c *client) reply(message controlMessage) {
+	data, _ := json.Marshal(message)
+	c.lock.Lock()
+	overrun := len(c.control) >= MAX_CONTROL_MESSAGES
+	if !overrun {
+		c.control = append(c.control, data)
+	}
+	c.lock.Unlock()
+	if overrun {
+		c.close()
+		return
+	}
+	c.notify()
+}
+
+// takePending returns the queued control messages followed by the latest update of every
+// subscription, in the order they were first queued.
+func (c *client) takePending() [][]byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	messages := c.control
+	for _, key := range c.pendingOrder {
+		messages = append(messages, c.pending[key])
+	}
+	c.control = nil
+	c.pending = make(map[string][]byte)
+	c.pendingOrder = nil
+	return messages
+}
+
+func (c *client) write(messageType int, data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+func (c *client) writeLoop() {
+	defer c.close()
+	ping := time.NewTicker(PING_INTERVAL)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ping.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.ready:
+			for _, data := range c.takePending() {
+				if err := c.write(websocket.TextMessage, data); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *client) readLoop() {
+	defer c.close()
+	c.conn.SetReadLimit(MAX_MESSAGE_SIZE)
+	c.conn.SetReadDeadline(time.Now().Add(PONG_TIMEOUT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(PONG_TIMEOUT))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var message clientMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.reply(controlMessage{Type: "error", Message: "Message is not valid JSON"})
+			continue
+		}
+		c.handle(message)
+	}
+}
+
+func (c *client) handle(message clientMessage) {
+	if message.Product != c.server.product {
+		c.reply(controlMessage{Type: "error", Product: message.Product, Message: "Product is not served: " + message.Product})
+		return
+	}
+	switch message.Type {
+	case "subscribe":
+		if c.server.authenticator != nil {
+			amount := 0.0
+			for _, size := range message.Sizes {
+				amount = math.Max(amount, size)
+			}
+			if _, err := c.server.authenticator.Admit(c.ctx, SUBSCRIBE_METHOD, amount); err != nil {
+				c.reply(controlMessage{Type: "error", Product: message.Product, Message: status.Convert(err).Message()})
+				return
+			}
+		}
+		added := make([]subscription, 0, len(message.Channels))
+		for _, name := range message.Channels {
+			sub, err := parseSubscription(name, message.Sizes)
+			if err != nil {
+				c.reply(controlMessage{Type: "error", Product: message.Product, Message: err.Error()})
+				return
+			}
+			added = append(added, sub)
+		}
+		c.lock.Lock()
+		previous := make(map[string]subscription)
+		for _, sub := range added {
+			if existing, ok := c.subscriptions[sub.channelName()]; ok {
+				previous[sub.channelName()] = existing
+			}
+			c.subscriptions[sub.channelName()] = sub
+		}
+		overflow := len(c.subscriptions) > MAX_CLIENT_SUBSCRIPTIONS
+		for _, sub := range added {
+			name := sub.channelName()
+			if !overflow {
+				c.added[name] = true
+			} else if existing, ok := previous[name]; ok {
+				c.subscriptions[name] = existing
+			} else {
+				delete(c.subscriptions, name)
+			}
+		}
+		c.lock.Unlock()
+		if overflow {
+			c.reply(controlMessage{Type: "error", Product: message.Product, Message: fmt.Sprintf("A client cannot hold more than %d subscriptions", MAX_CLIENT_SUBSCRIPTIONS)})
+			return
+		}
+		// New subscriptions receive the current state with the next broadcast, without waiting
+		// for the book to change
+		c.reply(controlMessage{Type: "subscribed", Product: message.Product, Channels: message.Channels})
+	case "unsubscribe":
+		c.lock.Lock()
+		for _, name := range message.Channels {
+			delete(c.subscriptions, name)
+		}
+		c.lock.Unlock()
+		c.reply(controlMessage{Type: "unsubscribed", Product: message.Product, Channels: message.Channels})
+	default:
+		c.reply(controlMessage{Type: "error", Message: "Unsupported message type: " + message.Type})
+	}
+}
+
+// channelName is the name the subscription was made with, which unsubscribing refers to.
+func (sub subscription) channelName() string {
+	if sub.channel == CHANNEL_DEPTH {
+		return CHANNEL_DEPTH + "-" + strconv.Itoa(sub.levels)
+	}
+	return sub.channel
+}
@@ -0,0 +1,343 @@
+package wsserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"pirosb3/real_feed/auth"
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/feed"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	CHANNEL_TICKER = "ticker"
+	CHANNEL_DEPTH  = "depth"
+	CHANNEL_QUOTES = "quotes"
+
+	MAX_DEPTH_LEVELS = 50
+	// A client holds a single quotes subscription, with at most MAX_QUOTE_SIZES sizes
+	MAX_QUOTE_SIZES          = 20
+	MAX_CLIENT_SUBSCRIPTIONS = 20
+	MAX_CLIENTS              = 1000
+
+	// BROADCAST_INTERVAL conflates the book updates rendered for clients. Every interval, changes
+	// are rendered once against a single copy of the book.
+	BROADCAST_INTERVAL = 100 * time.Millisecond
+
+	// Methods clients are admitted for by the authenticator
+	CONNECT_METHOD   = "/ws"
+	SUBSCRIBE_METHOD = "/ws/subscribe"
+)
+
+var (
+	connectedClientsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "websocketClients",
+		Help:      "Number of browser clients connected to the websocket server",
+		Namespace: "feed",
+	}, []string{"market"})
+
+	conflatedMessagesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "conflatedMessages",
+		Help:      "Counts updates replaced by a newer one before a slow client could receive them",
+		Namespace: "feed",
+	}, []string{"market", "channel"})
+)
+
+// Server fans out book updates to browser clients over websockets. Clients subscribe to the
+// ticker, the top N levels of the book (depth-N) or quotes for a set of sizes, and receive a JSON
+// message at most every BROADCAST_INTERVAL while the book changes. A client that cannot keep up
+// only receives the latest message of each subscription.
+type Server struct {
+	feedController *controller.FeedController
+	product        string
+	upgrader       websocket.Upgrader
+	lock           sync.Mutex
+	clients        map[*client]bool
+	authenticator  *auth.Authenticator
+	ctx            context.Context
+}
+
+// NewServer creates a websocket server for the product of `feedController`. Updates are only
+// sent once `.Run()` is called.
+func NewServer(ctx context.Context, feedController *controller.FeedController, product string) *Server {
+	return &Server{
+		feedController: feedController,
+		product:        product,
+		upgrader: websocket.Upgrader{
+			// The server only publishes public market data, so any origin may read it
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		clients: make(map[*client]bool),
+		ctx:     ctx,
+	}
+}
+
+// SetAuthenticator requires clients to authenticate when connecting, and applies their limits to
+// connections and subscriptions. It must be called before serving clients.
+func (s *Server) SetAuthenticator(authenticator *auth.Authenticator) {
+	s.authenticator = authenticator
+}
+
+// admit returns the context of a client allowed to connect, or writes why it is not.
+func (s *Server) admit(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	ctx := auth.IncomingContext(r)
+	if s.authenticator == nil {
+		return ctx, true
+	}
+	ctx, err := s.authenticator.Admit(ctx, CONNECT_METHOD, 0)
+	if err == nil {
+		return ctx, true
+	}
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unauthenticated:
+		http.Error(w, st.Message(), http.StatusUnauthorized)
+	case codes.ResourceExhausted:
+		http.Error(w, st.Message(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, st.Message(), http.StatusForbidden)
+	}
+	return nil, false
+}
+
+// ServeHTTP upgrades the request to a websocket and serves the client until it disconnects.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := s.admit(w, r)
+	if !ok {
+		return
+	}
+	s.lock.Lock()
+	full := len(s.clients) >= MAX_CLIENTS
+	s.lock.Unlock()
+	if full {
+		http.Error(w, "Too many clients are connected", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := newClient(s, conn)
+	c.ctx = ctx
+	s.lock.Lock()
+	full = len(s.clients) >= MAX_CLIENTS
+	if !full {
+		s.clients[c] = true
+	}
+	s.lock.Unlock()
+	if full {
+		c.close()
+		return
+	}
+	connectedClientsGauge.WithLabelValues(s.product).Inc()
+
+	go c.writeLoop()
+	c.readLoop()
+
+	s.lock.Lock()
+	delete(s.clients, c)
+	s.lock.Unlock()
+	connectedClientsGauge.WithLabelValues(s.product).Dec()
+}
+
+// Run sends updates to the clients every BROADCAST_INTERVAL in which the book changed, until the
+// context passed to the server is cancelled or the feed controller shuts down. Clients are
+// disconnected afterwards.
+func (s *Server) Run() {
+	events, cancel := s.feedController.Subscribe()
+	defer cancel()
+	defer s.closeClients()
+	ticker := time.NewTicker(BROADCAST_INTERVAL)
+	defer ticker.Stop()
+
+	changed := false
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == controller.EVENT_BOOK_UPDATE {
+				changed = true
+			}
+		case <-ticker.C:
+			s.broadcast(changed)
+			changed = false
+		}
+	}
+}
+
+func (s *Server) connectedClients() []*client {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+func (s *Server) closeClients() {
+	for _, c := range s.connectedClients() {
+		c.close()
+	}
+}
+
+// broadcast renders every subscription once, against a single copy of the book, and queues the
+// result for the clients subscribed to it. Unless the book `changed`, only subscriptions made
+// since the last broadcast are rendered. The book is not copied when nothing is rendered.
+func (s *Server) broadcast(changed bool) {
+	var book *feed.OrderbookFeed
+	rendered := make(map[string][]byte)
+	for _, c := range s.connectedClients() {
+		for _, sub := range c.takeSubscriptions(changed) {
+			data, ok := rendered[sub.key]
+			if !ok {
+				if book == nil {
+					book = s.feedController.Orderbook().Clone()
+				}
+				data = s.render(book, sub)
+				rendered[sub.key] = data
+			}
+			if data != nil {
+				c.enqueue(sub, data)
+			}
+		}
+	}
+}
+
+type tickerMessage struct {
+	Type           string  `json:"type"`
+	Product        string  `json:"product"`
+	BestBid        float64 `json:"bestBid"`
+	BestBidSize    float64 `json:"bestBidSize"`
+	BestAsk        float64 `json:"bestAsk"`
+	BestAskSize    float64 `json:"bestAskSize"`
+	Mid            float64 `json:"mid"`
+	SpreadBps      float64 `json:"spreadBps"`
+	LastTradePrice float64 `json:"lastTradePrice"`
+	LastUpdated    int64   `json:"lastUpdated"`
+	Version        uint64  `json:"version"`
+}
+
+type depthMessage struct {
+	Type        string       `json:"type"`
+	Product     string       `json:"product"`
+	Levels      int          `json:"levels"`
+	Bids        [][2]float64 `json:"bids"`
+	Asks        [][2]float64 `json:"asks"`
+	LastUpdated int64        `json:"lastUpdated"`
+	Version     uint64       `json:"version"`
+}
+
+type quotePoint struct {
+	Size      float64 `json:"size"`
+	BuyPrice  float64 `json:"buyPrice,omitempty"`
+	SellPrice float64 `json:"sellPrice,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type quotesMessage struct {
+	Type        string       `json:"type"`
+	Product     string       `json:"product"`
+	Quotes      []quotePoint `json:"quotes"`
+	LastUpdated int64        `json:"lastUpdated"`
+	Version     uint64       `json:"version"`
+}
+
+func toPairs(levels []feed.DepthLevel) [][2]float64 {
+	result := make([][2]float64, len(levels))
+	for idx, level := range levels {
+		result[idx] = [2]float64{level.Price, level.Size}
+	}
+	return result
+}
+
+// render returns the message of a subscription, or nil if the book cannot be quoted.
+func (s *Server) render(book *feed.OrderbookFeed, sub subscription) []byte {
+	var message interface{}
+	switch sub.channel {
+	case CHANNEL_TICKER:
+		depth, err := book.Depth(1)
+		if err != nil || len(depth.Bids) == 0 || len(depth.Asks) == 0 {
+			return nil
+		}
+		bestBid, bestAsk := depth.Bids[0], depth.Asks[0]
+		mid := (bestBid.Price + bestAsk.Price) / 2
+		lastPrice, _ := s.feedController.TradeStats()
+		message = tickerMessage{
+			Type:           CHANNEL_TICKER,
+			Product:        s.product,
+			BestBid:        bestBid.Price,
+			BestBidSize:    bestBid.Size,
+			BestAsk:        bestAsk.Price,
+			BestAskSize:    bestAsk.Size,
+			Mid:            mid,
+			SpreadBps:      (bestAsk.Price - bestBid.Price) / mid * 10000,
+			LastTradePrice: lastPrice,
+			LastUpdated:    depth.LastUpdated,
+			Version:        depth.Version,
+		}
+	case CHANNEL_DEPTH:
+		depth, err := book.Depth(sub.levels)
+		if err != nil {
+			return nil
+		}
+		message = depthMessage{
+			Type:        CHANNEL_DEPTH,
+			Product:     s.product,
+			Levels:      sub.levels,
+			Bids:        toPairs(depth.Bids),
+			Asks:        toPairs(depth.Asks),
+			LastUpdated: depth.LastUpdated,
+			Version:     depth.Version,
+		}
+	case CHANNEL_QUOTES:
+		if book.Ready() != nil {
+			return nil
+		}
+		quotes := quotesMessage{
+			Type:    CHANNEL_QUOTES,
+			Product: s.product,
+			Quotes:  make([]quotePoint, len(sub.sizes)),
+			Version: book.GetVersion(),
+		}
+		for idx, size := range sub.sizes {
+			point := quotePoint{Size: size}
+			paid, lastUpdated, buyErr := book.BuyBase(size)
+			received, _, sellErr := book.SellBase(size)
+			quotes.LastUpdated = lastUpdated
+			if buyErr == nil {
+				point.BuyPrice = paid / size
+			}
+			if sellErr == nil {
+				point.SellPrice = received / size
+			}
+			if buyErr != nil {
+				point.Error = buyErr.Error()
+			} else if sellErr != nil {
+				point.Error = sellErr.Error()
+			}
+			quotes.Quotes[idx] = point
+		}
+		message = quotes
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.WithField("err", err.Error()).Errorln("Unable to encode websocket update")
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,193 @@
+package wsserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pirosb3/real_feed/auth"
+	"pirosb3/real_feed/controller"
+	"pirosb3/real_feed/feed"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestServer() (*Server, *httptest.Server) {
+	fc := controller.NewFeedController(context.Background(), "ETH-DAI")
+	fc.Orderbook().SetSnapshot(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "333.2", Size: "0.5"},
+		&feed.Update{Price: "320", Size: "0.5"},
+	}, []*feed.Update{
+		&feed.Update{Price: "335.12", Size: "0.5"},
+	})
+	server := NewServer(context.Background(), fc, "ETH-DAI")
+	return server, httptest.NewServer(server)
+}
+
+func dial(t *testing.T, httpServer *httptest.Server) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	message := make(map[string]interface{})
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("Unable to read message: %s", err)
+	}
+	return message
+}
+
+func TestSubscribeReceivesUpdates(t *testing.T) {
+	server, httpServer := newTestServer()
+	defer httpServer.Close()
+	conn := dial(t, httpServer)
+	defer conn.Close()
+
+	conn.WriteJSON(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: []string{"depth-1"}})
+	if message := readMessage(t, conn); message["type"] != "subscribed" {
+		t.Fatalf("Expected an acknowledgement, got %v", message)
+	}
+	server.broadcast(false)
+	message := readMessage(t, conn)
+	if message["type"] != CHANNEL_DEPTH || message["bids"].([]interface{})[0].([]interface{})[0].(float64) != 333.2 {
+		t.Errorf("Unexpected initial depth %v", message)
+	}
+
+	server.feedController.Orderbook().WriteUpdate(time.Now().Unix(), []*feed.Update{
+		&feed.Update{Price: "334", Size: "1"},
+	}, nil)
+	server.broadcast(true)
+	message = readMessage(t, conn)
+	if message["bids"].([]interface{})[0].([]interface{})[0].(float64) != 334 {
+		t.Errorf("Expected the new best bid, got %v", message)
+	}
+}
+
+func TestSubscribeRejectsUnknownProductsAndChannels(t *testing.T) {
+	_, httpServer := newTestServer()
+	defer httpServer.Close()
+	conn := dial(t, httpServer)
+	defer conn.Close()
+
+	conn.WriteJSON(clientMessage{Type: "subscribe", Product: "BTC-USD", Channels: []string{"ticker"}})
+	if message := readMessage(t, conn); message["type"] != "error" {
+		t.Errorf("Expected an error for an unknown product, got %v", message)
+	}
+	conn.WriteJSON(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: []string{"depth-500"}})
+	if message := readMessage(t, conn); message["type"] != "error" {
+		t.Errorf("Expected an error for too many levels, got %v", message)
+	}
+}
+
+func TestSlowClientsOnlyReceiveLatestUpdate(t *testing.T) {
+	server, _ := newTestServer()
+	c := newClient(server, nil)
+	sub, _ := parseSubscription("quotes", []float64{10, 1})
+	ticker, _ := parseSubscription("ticker", nil)
+	c.enqueue(sub, []byte("first"))
+	c.enqueue(ticker, []byte("ticker"))
+	c.enqueue(sub, []byte("second"))
+
+	messages := c.takePending()
+	if len(messages) != 2 || string(messages[0]) != "second" || string(messages[1]) != "ticker" {
+		t.Errorf("Expected the latest quotes then the ticker, got %q", messages)
+	}
+	if sub.key != "quotes:1,10" {
+		t.Errorf("Expected sizes to be sorted in the key, got %s", sub.key)
+	}
+	if len(c.takePending()) != 0 {
+		t.Errorf("Expected no pending messages")
+	}
+}
+
+func TestQuotesRender(t *testing.T) {
+	server, _ := newTestServer()
+	sub, _ := parseSubscription("quotes", []float64{0.5, 5})
+	message := quotesMessage{}
+	json.Unmarshal(server.render(server.feedController.Orderbook(), sub), &message)
+	if len(message.Quotes) != 2 || message.Quotes[0].SellPrice != 333.2 || message.Quotes[0].BuyPrice != 335.12 {
+		t.Errorf("Unexpected quotes %+v", message.Quotes)
+	}
+	if message.Quotes[1].Error != feed.ErrInsufficientLiquidity.Error() {
+		t.Errorf("Expected insufficient liquidity for a large size, got %+v", message.Quotes[1])
+	}
+}
+
+func TestClientsAreAuthenticated(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "clients")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clients.yaml")
+	os.WriteFile(path, []byte("clients:\n  - name: bot\n    apiKeySHA256: "+auth.HashAPIKey("secret")+"\n    maxQuoteAmount: 1\n"), 0600)
+	authenticator, err := auth.NewAuthenticator(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	server, httpServer := newTestServer()
+	defer httpServer.Close()
+	server.SetAuthenticator(authenticator)
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+	if _, response, err := websocket.DefaultDialer.Dial(url, nil); err == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected a client without a key to be rejected, got %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?"+auth.API_KEY_QUERY_PARAMETER+"=secret", nil)
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: []string{"quotes"}, Sizes: []float64{5}})
+	if message := readMessage(t, conn); message["type"] != "error" {
+		t.Errorf("Expected a quote above the limit to be rejected, got %v", message)
+	}
+}
+
+func TestBroadcastOnlyRendersNewSubscriptionsUntilTheBookChanges(t *testing.T) {
+	server, _ := newTestServer()
+	// Without clients, nothing is rendered
+	server.broadcast(true)
+
+	c := newClient(server, nil)
+	server.clients[c] = true
+	c.handle(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: []string{"ticker"}})
+	c.takePending()
+	server.broadcast(false)
+	if messages := c.takePending(); len(messages) != 1 {
+		t.Fatalf("Expected the new subscription to receive the current state, got %q", messages)
+	}
+	server.broadcast(false)
+	if messages := c.takePending(); len(messages) != 0 {
+		t.Errorf("Expected nothing to be sent while the book is unchanged, got %q", messages)
+	}
+	server.broadcast(true)
+	if messages := c.takePending(); len(messages) != 1 {
+		t.Errorf("Expected the ticker after the book changed, got %q", messages)
+	}
+}
+
+func TestSubscriptionsAreLimited(t *testing.T) {
+	server, _ := newTestServer()
+	c := newClient(server, nil)
+	c.handle(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: []string{"quotes"}, Sizes: []float64{1}})
+	c.handle(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: []string{"quotes"}, Sizes: []float64{2, 3}})
+	channels := make([]string, 0, MAX_CLIENT_SUBSCRIPTIONS)
+	for levels := 1; levels <= MAX_CLIENT_SUBSCRIPTIONS; levels++ {
+		channels = append(channels, CHANNEL_DEPTH+"-"+strconv.Itoa(levels))
+	}
+	c.handle(clientMessage{Type: "subscribe", Product: "ETH-DAI", Channels: channels})
+
+	subscriptions := c.takeSubscriptions(true)
+	if len(subscriptions) != 1 || subscriptions[0].key != "quotes:2,3" {
+		t.Errorf("Expected a single quotes subscription, with the latest sizes, got %+v", subscriptions)
+	}
+}